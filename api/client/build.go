@@ -19,6 +19,7 @@ import (
 
 	"github.com/docker/docker/api"
 	"github.com/docker/docker/graph/tags"
+	"github.com/docker/docker/opts"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/fileutils"
 	"github.com/docker/docker/pkg/jsonmessage"
@@ -59,6 +60,9 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	flCPUSetCpus := cmd.String([]string{"-cpuset-cpus"}, "", "CPUs in which to allow execution (0-3, 0,1)")
 	flCPUSetMems := cmd.String([]string{"-cpuset-mems"}, "", "MEMs in which to allow execution (0-3, 0,1)")
 	flCgroupParent := cmd.String([]string{"-cgroup-parent"}, "", "Optional parent cgroup for the container")
+	flTarget := cmd.String([]string{"-target"}, "", "Set the target build stage to build")
+	flBuildArg := opts.NewListOpts(opts.ValidateEnv)
+	cmd.Var(&flBuildArg, []string{"-build-arg"}, "Set build-time variables")
 
 	cmd.Require(flag.Exact, 1)
 	cmd.ParseFlags(args, true)
@@ -279,6 +283,26 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	v.Set("memory", strconv.FormatInt(memory, 10))
 	v.Set("memswap", strconv.FormatInt(memorySwap, 10))
 	v.Set("cgroupparent", *flCgroupParent)
+	if *flTarget != "" {
+		v.Set("target", *flTarget)
+	}
+
+	if flBuildArg.Len() > 0 {
+		buildArgs := make(map[string]string)
+		for _, arg := range flBuildArg.GetAll() {
+			kv := strings.SplitN(arg, "=", 2)
+			if len(kv) == 2 {
+				buildArgs[kv[0]] = kv[1]
+			} else {
+				buildArgs[kv[0]] = ""
+			}
+		}
+		buf, err := json.Marshal(buildArgs)
+		if err != nil {
+			return err
+		}
+		v.Set("buildargs", string(buf))
+	}
 
 	v.Set("dockerfile", *dockerfileName)
 
@@ -1,8 +1,15 @@
 package client
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/url"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/opts"
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/pkg/parsers/filters"
@@ -49,12 +56,55 @@ func (cli *DockerCli) CmdEvents(args ...string) error {
 		}
 		v.Set("filters", filterJSON)
 	}
-	sopts := &streamOpts{
-		rawTerminal: true,
-		out:         cli.out,
-	}
-	if err := cli.stream("GET", "/events?"+v.Encode(), sopts); err != nil {
+	stream, _, err := cli.call("GET", "/events?"+v.Encode(), nil, nil)
+	if err != nil {
 		return err
 	}
-	return nil
+	defer stream.Close()
+	dec := json.NewDecoder(stream)
+	for {
+		var ev types.Event
+		if err := dec.Decode(&ev); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		printEvent(cli.out, &ev)
+	}
+}
+
+// printEvent prints the events of containers and images as they always
+// were, and the others with their type and the attributes of their actor:
+//
+//	2015-07-06T15:53:50.543246000+02:00 4386fb97867d: (from busybox) start
+//	2015-07-06T15:53:52.112364000+02:00 volume create data (driver=local)
+func printEvent(out io.Writer, ev *types.Event) {
+	t := time.Unix(ev.Time, 0)
+	if ev.TimeNano != 0 {
+		t = time.Unix(0, ev.TimeNano)
+	}
+	fmt.Fprintf(out, "%s ", t.Format(timeutils.RFC3339NanoFixed))
+
+	if ev.Status != "" {
+		if ev.ID != "" {
+			fmt.Fprintf(out, "%s: ", ev.ID)
+		}
+		if ev.From != "" {
+			fmt.Fprintf(out, "(from %s) ", ev.From)
+		}
+		fmt.Fprintf(out, "%s\n", ev.Status)
+		return
+	}
+
+	fmt.Fprintf(out, "%s %s %s", ev.Type, ev.Action, ev.Actor.ID)
+	if len(ev.Actor.Attributes) > 0 {
+		var attributes []string
+		for k, v := range ev.Actor.Attributes {
+			attributes = append(attributes, k+"="+v)
+		}
+		sort.Strings(attributes)
+		fmt.Fprintf(out, " (%s)", strings.Join(attributes, ", "))
+	}
+	fmt.Fprintln(out)
 }
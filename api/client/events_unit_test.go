@@ -0,0 +1,56 @@
+package client
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/timeutils"
+)
+
+func TestPrintEvent(t *testing.T) {
+	now := time.Unix(1436188430, 543246000)
+	prefix := now.Format(timeutils.RFC3339NanoFixed) + " "
+	for _, c := range []struct {
+		ev       types.Event
+		expected string
+	}{
+		{
+			types.Event{Status: "start", ID: "4386fb97867d", From: "busybox", Time: now.Unix(), TimeNano: now.UnixNano()},
+			"4386fb97867d: (from busybox) start\n",
+		},
+		{
+			types.Event{Status: "delete", ID: "4386fb97867d", Time: now.Unix(), TimeNano: now.UnixNano()},
+			"4386fb97867d: delete\n",
+		},
+		{
+			types.Event{
+				Type:     types.VolumeEventType,
+				Action:   "create",
+				Actor:    types.EventActor{ID: "data", Attributes: map[string]string{"name": "data", "driver": "local"}},
+				Time:     now.Unix(),
+				TimeNano: now.UnixNano(),
+			},
+			"volume create data (driver=local, name=data)\n",
+		},
+		{
+			types.Event{Type: types.DaemonEventType, Action: "reload", Actor: types.EventActor{ID: "ABCD"}, Time: now.Unix(), TimeNano: now.UnixNano()},
+			"daemon reload ABCD\n",
+		},
+	} {
+		var b bytes.Buffer
+		printEvent(&b, &c.ev)
+		if !strings.HasPrefix(b.String(), prefix) || b.String()[len(prefix):] != c.expected {
+			t.Fatalf("Expected %q, got %q", prefix+c.expected, b.String())
+		}
+	}
+
+	// Daemons from before the nanoseconds
+	var b bytes.Buffer
+	printEvent(&b, &types.Event{Status: "start", ID: "4386fb97867d", Time: now.Unix()})
+	if expected := time.Unix(now.Unix(), 0).Format(timeutils.RFC3339NanoFixed) + " 4386fb97867d: start\n"; b.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, b.String())
+	}
+}
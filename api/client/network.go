@@ -0,0 +1,228 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/docker/docker/api/types"
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/stringid"
+)
+
+// CmdNetwork is the parent subcommand for all network commands.
+//
+// Usage: docker network <COMMAND> [OPTIONS]
+func (cli *DockerCli) CmdNetwork(args ...string) error {
+	description := "Manage Docker networks\n\nCommands:\n"
+	commands := [][]string{
+		{"connect", "Connect a container to a network"},
+		{"create", "Create a network"},
+		{"disconnect", "Disconnect a container from a network"},
+		{"inspect", "Return low-level information on a network"},
+		{"ls", "List networks"},
+		{"rm", "Remove a network"},
+	}
+
+	for _, cmd := range commands {
+		description += fmt.Sprintf("  %-25.25s%s\n", cmd[0], cmd[1])
+	}
+
+	description += "\nRun 'docker network COMMAND --help' for more information on a command."
+	cmd := cli.Subcmd("network", "[COMMAND]", description, true)
+	cmd.Require(flag.Exact, 0)
+	cmd.ParseFlags(args, true)
+	cmd.Usage()
+	return nil
+}
+
+// CmdNetworkCreate creates a new user-defined network.
+//
+// Usage: docker network create [OPTIONS] NETWORK
+func (cli *DockerCli) CmdNetworkCreate(args ...string) error {
+	cmd := cli.Subcmd("network create", "NETWORK", "Create a network", true)
+	flDriver := cmd.String([]string{"d", "-driver"}, "bridge", "Driver to manage the network")
+	flSubnet := cmd.String([]string{"-subnet"}, "", "Subnet in CIDR format for the network")
+	flGateway := cmd.String([]string{"-gateway"}, "", "Gateway for the subnet")
+	cmd.Require(flag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	req := &types.NetworkCreateRequest{
+		Name:    cmd.Arg(0),
+		Driver:  *flDriver,
+		Subnet:  *flSubnet,
+		Gateway: *flGateway,
+	}
+	stream, _, err := cli.call("POST", "/networks/create", req, nil)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	var resp types.NetworkCreateResponse
+	if err := json.NewDecoder(stream).Decode(&resp); err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.out, "%s\n", resp.ID)
+	return nil
+}
+
+// CmdNetworkLs lists the networks known to the daemon.
+//
+// Usage: docker network ls [OPTIONS]
+func (cli *DockerCli) CmdNetworkLs(args ...string) error {
+	cmd := cli.Subcmd("network ls", "", "List networks", true)
+	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Only display network IDs")
+	noTrunc := cmd.Bool([]string{"-no-trunc"}, false, "Don't truncate the output")
+	cmd.Require(flag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	stream, _, err := cli.call("GET", "/networks", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	var networks []*types.NetworkResource
+	if err := json.NewDecoder(stream).Decode(&networks); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
+	if !*quiet {
+		fmt.Fprintln(w, "NETWORK ID\tNAME\tDRIVER\tSUBNET")
+	}
+	for _, n := range networks {
+		id := n.ID
+		if !*noTrunc {
+			id = stringid.TruncateID(id)
+		}
+		if *quiet {
+			fmt.Fprintln(w, id)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", id, n.Name, n.Driver, n.Subnet)
+	}
+	w.Flush()
+	return nil
+}
+
+// CmdNetworkInspect displays low-level information on one or more networks.
+//
+// Usage: docker network inspect [OPTIONS] NETWORK [NETWORK...]
+func (cli *DockerCli) CmdNetworkInspect(args ...string) error {
+	cmd := cli.Subcmd("network inspect", "NETWORK [NETWORK...]", "Return low-level information on a network", true)
+	tmplStr := cmd.String([]string{"f", "-format"}, "", "Format the output using the given go template")
+	cmd.Require(flag.Min, 1)
+	cmd.ParseFlags(args, true)
+
+	var tmpl *template.Template
+	if *tmplStr != "" {
+		var err error
+		if tmpl, err = template.New("").Funcs(funcMap).Parse(*tmplStr); err != nil {
+			return StatusError{StatusCode: 64,
+				Status: "Template parsing error: " + err.Error()}
+		}
+	}
+
+	var (
+		status   = 0
+		indented = new(bytes.Buffer)
+		networks []*types.NetworkResource
+	)
+	for _, name := range cmd.Args() {
+		obj, _, err := readBody(cli.call("GET", "/networks/"+name, nil, nil))
+		if err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			status = 1
+			continue
+		}
+
+		var n types.NetworkResource
+		if err := json.Unmarshal(obj, &n); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			status = 1
+			continue
+		}
+
+		if tmpl != nil {
+			if err := tmpl.Execute(cli.out, n); err != nil {
+				return err
+			}
+			cli.out.Write([]byte{'\n'})
+			continue
+		}
+		networks = append(networks, &n)
+	}
+
+	if tmpl == nil {
+		b, err := json.Marshal(networks)
+		if err != nil {
+			return err
+		}
+		if err := json.Indent(indented, b, "", "    "); err != nil {
+			return err
+		}
+		indented.WriteString("\n")
+		if _, err := io.Copy(cli.out, indented); err != nil {
+			return err
+		}
+	}
+
+	if status != 0 {
+		return StatusError{StatusCode: status}
+	}
+	return nil
+}
+
+// CmdNetworkRm removes one or more networks.
+//
+// Usage: docker network rm NETWORK [NETWORK...]
+func (cli *DockerCli) CmdNetworkRm(args ...string) error {
+	cmd := cli.Subcmd("network rm", "NETWORK [NETWORK...]", "Remove a network", true)
+	cmd.Require(flag.Min, 1)
+	cmd.ParseFlags(args, true)
+
+	var errNames []string
+	for _, name := range cmd.Args() {
+		if _, _, err := readBody(cli.call("DELETE", "/networks/"+name, nil, nil)); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			errNames = append(errNames, name)
+		} else {
+			fmt.Fprintf(cli.out, "%s\n", name)
+		}
+	}
+	if len(errNames) > 0 {
+		return fmt.Errorf("Error: failed to remove networks: %v", errNames)
+	}
+	return nil
+}
+
+// CmdNetworkConnect connects a stopped container to an additional network.
+//
+// Usage: docker network connect NETWORK CONTAINER
+func (cli *DockerCli) CmdNetworkConnect(args ...string) error {
+	cmd := cli.Subcmd("network connect", "NETWORK CONTAINER", "Connect a container to a network", true)
+	cmd.Require(flag.Exact, 2)
+	cmd.ParseFlags(args, true)
+
+	req := &types.NetworkConnectRequest{Container: cmd.Arg(1)}
+	_, _, err := readBody(cli.call("POST", "/networks/"+cmd.Arg(0)+"/connect", req, nil))
+	return err
+}
+
+// CmdNetworkDisconnect disconnects a stopped container from a network.
+//
+// Usage: docker network disconnect NETWORK CONTAINER
+func (cli *DockerCli) CmdNetworkDisconnect(args ...string) error {
+	cmd := cli.Subcmd("network disconnect", "NETWORK CONTAINER", "Disconnect a container from a network", true)
+	cmd.Require(flag.Exact, 2)
+	cmd.ParseFlags(args, true)
+
+	req := &types.NetworkConnectRequest{Container: cmd.Arg(1)}
+	_, _, err := readBody(cli.call("POST", "/networks/"+cmd.Arg(0)+"/disconnect", req, nil))
+	return err
+}
@@ -15,7 +15,7 @@ func (cli *DockerCli) CmdRm(args ...string) error {
 	cmd := cli.Subcmd("rm", "CONTAINER [CONTAINER...]", "Remove one or more containers", true)
 	v := cmd.Bool([]string{"v", "-volumes"}, false, "Remove the volumes associated with the container")
 	link := cmd.Bool([]string{"l", "#link", "-link"}, false, "Remove the specified link")
-	force := cmd.Bool([]string{"f", "-force"}, false, "Force the removal of a running container (uses SIGKILL)")
+	force := cmd.Bool([]string{"f", "-force"}, false, "Force the removal of a running container (uses the stop signal, then SIGKILL)")
 	cmd.Require(flag.Min, 1)
 
 	cmd.ParseFlags(args, true)
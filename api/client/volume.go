@@ -0,0 +1,188 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/docker/docker/api/types"
+	flag "github.com/docker/docker/pkg/mflag"
+)
+
+// CmdVolume is the parent subcommand for all volume commands.
+//
+// Usage: docker volume <COMMAND> [OPTIONS]
+func (cli *DockerCli) CmdVolume(args ...string) error {
+	description := "Manage Docker volumes\n\nCommands:\n"
+	commands := [][]string{
+		{"create", "Create a volume"},
+		{"inspect", "Return low-level information on a volume"},
+		{"ls", "List volumes"},
+		{"rm", "Remove a volume"},
+	}
+
+	for _, cmd := range commands {
+		description += fmt.Sprintf("  %-25.25s%s\n", cmd[0], cmd[1])
+	}
+
+	description += "\nRun 'docker volume COMMAND --help' for more information on a command."
+	cmd := cli.Subcmd("volume", "[COMMAND]", description, true)
+	cmd.Require(flag.Exact, 0)
+	cmd.ParseFlags(args, true)
+	cmd.Usage()
+	return nil
+}
+
+// CmdVolumeCreate creates a new named volume.
+//
+// Usage: docker volume create [OPTIONS]
+func (cli *DockerCli) CmdVolumeCreate(args ...string) error {
+	cmd := cli.Subcmd("volume create", "", "Create a volume", true)
+	flName := cmd.String([]string{"-name"}, "", "Specify volume name")
+	flDriver := cmd.String([]string{"d", "-driver"}, "local", "Specify volume driver name")
+	cmd.Require(flag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	req := &types.VolumeCreateRequest{Name: *flName, Driver: *flDriver}
+	stream, _, err := cli.call("POST", "/volumes/create", req, nil)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	var vol types.Volume
+	if err := json.NewDecoder(stream).Decode(&vol); err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.out, "%s\n", vol.Name)
+	return nil
+}
+
+// CmdVolumeLs lists the volumes known to the daemon.
+//
+// Usage: docker volume ls [OPTIONS]
+func (cli *DockerCli) CmdVolumeLs(args ...string) error {
+	cmd := cli.Subcmd("volume ls", "", "List volumes", true)
+	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Only display volume names")
+	cmd.Require(flag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	stream, _, err := cli.call("GET", "/volumes", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	var volumes types.VolumesListResponse
+	if err := json.NewDecoder(stream).Decode(&volumes); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
+	if !*quiet {
+		fmt.Fprintln(w, "DRIVER\tVOLUME NAME\tMOUNTPOINT")
+	}
+	for _, vol := range volumes.Volumes {
+		if *quiet {
+			fmt.Fprintln(w, vol.Name)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", vol.Driver, vol.Name, vol.Mountpoint)
+	}
+	w.Flush()
+	return nil
+}
+
+// CmdVolumeInspect displays low-level information on one or more volumes.
+//
+// Usage: docker volume inspect [OPTIONS] VOLUME [VOLUME...]
+func (cli *DockerCli) CmdVolumeInspect(args ...string) error {
+	cmd := cli.Subcmd("volume inspect", "VOLUME [VOLUME...]", "Return low-level information on a volume", true)
+	tmplStr := cmd.String([]string{"f", "-format"}, "", "Format the output using the given go template")
+	cmd.Require(flag.Min, 1)
+	cmd.ParseFlags(args, true)
+
+	var tmpl *template.Template
+	if *tmplStr != "" {
+		var err error
+		if tmpl, err = template.New("").Funcs(funcMap).Parse(*tmplStr); err != nil {
+			return StatusError{StatusCode: 64,
+				Status: "Template parsing error: " + err.Error()}
+		}
+	}
+
+	var (
+		status   = 0
+		indented = new(bytes.Buffer)
+		vols     []*types.Volume
+	)
+	for _, name := range cmd.Args() {
+		obj, _, err := readBody(cli.call("GET", "/volumes/"+name, nil, nil))
+		if err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			status = 1
+			continue
+		}
+
+		var vol types.Volume
+		if err := json.Unmarshal(obj, &vol); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			status = 1
+			continue
+		}
+
+		if tmpl != nil {
+			if err := tmpl.Execute(cli.out, vol); err != nil {
+				return err
+			}
+			cli.out.Write([]byte{'\n'})
+			continue
+		}
+		vols = append(vols, &vol)
+	}
+
+	if tmpl == nil {
+		b, err := json.Marshal(vols)
+		if err != nil {
+			return err
+		}
+		if err := json.Indent(indented, b, "", "    "); err != nil {
+			return err
+		}
+		indented.WriteString("\n")
+		if _, err := io.Copy(cli.out, indented); err != nil {
+			return err
+		}
+	}
+
+	if status != 0 {
+		return StatusError{StatusCode: status}
+	}
+	return nil
+}
+
+// CmdVolumeRm removes one or more volumes.
+//
+// Usage: docker volume rm VOLUME [VOLUME...]
+func (cli *DockerCli) CmdVolumeRm(args ...string) error {
+	cmd := cli.Subcmd("volume rm", "VOLUME [VOLUME...]", "Remove a volume", true)
+	cmd.Require(flag.Min, 1)
+	cmd.ParseFlags(args, true)
+
+	var errNames []string
+	for _, name := range cmd.Args() {
+		if _, _, err := readBody(cli.call("DELETE", "/volumes/"+name, nil, nil)); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			errNames = append(errNames, name)
+		} else {
+			fmt.Fprintf(cli.out, "%s\n", name)
+		}
+	}
+	if len(errNames) > 0 {
+		return fmt.Errorf("Error: failed to remove volumes: %v", errNames)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func boolValue(r *http.Request, k string) bool {
@@ -18,3 +19,25 @@ func int64ValueOrZero(r *http.Request, k string) int64 {
 	}
 	return val
 }
+
+// parseEventTime parses the since and until parameters of the events, a
+// number of seconds since the epoch with an optional fraction, and returns
+// it in nanoseconds.
+func parseEventTime(value string) (int64, error) {
+	parts := strings.SplitN(value, ".", 2)
+	sec, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	var nsec uint64
+	if len(parts) == 2 {
+		frac := parts[1]
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		if nsec, err = strconv.ParseUint(frac+strings.Repeat("0", 9-len(frac)), 10, 64); err != nil {
+			return 0, err
+		}
+	}
+	return time.Unix(sec, int64(nsec)).UnixNano(), nil
+}
@@ -53,3 +53,29 @@ func TestInt64ValueOrZero(t *testing.T) {
 		}
 	}
 }
+
+func TestParseEventTime(t *testing.T) {
+	cases := map[string]int64{
+		"0":                     0,
+		"1436188430":            1436188430000000000,
+		"1436188430.5":          1436188430500000000,
+		"1436188430.543246":     1436188430543246000,
+		"1436188430.5432461234": 1436188430543246123,
+	}
+
+	for c, e := range cases {
+		a, err := parseEventTime(c)
+		if err != nil {
+			t.Fatalf("Value: %s, unexpected error: %v", c, err)
+		}
+		if a != e {
+			t.Fatalf("Value: %s, expected: %v, actual: %v", c, e, a)
+		}
+	}
+
+	for _, c := range []string{"", "asdf", "1436188430.x", "1436188430.-5"} {
+		if _, err := parseEventTime(c); err == nil {
+			t.Fatalf("Value: %s, expected an error", c)
+		}
+	}
+}
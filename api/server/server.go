@@ -26,7 +26,6 @@ import (
 	"github.com/docker/docker/daemon/networkdriver/bridge"
 	"github.com/docker/docker/graph"
 	"github.com/docker/docker/pkg/ioutils"
-	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/parsers/filters"
 	"github.com/docker/docker/pkg/parsers/kernel"
@@ -171,7 +170,7 @@ func checkForJson(r *http.Request) error {
 	return fmt.Errorf("Content-Type specified (%s) must be 'application/json'", ct)
 }
 
-//If we don't do this, POST method without Content-type (even with empty body) will fail
+// If we don't do this, POST method without Content-type (even with empty body) will fail
 func parseForm(r *http.Request) error {
 	if r == nil {
 		return nil
@@ -368,13 +367,17 @@ func (s *Server) getInfo(version version.Version, w http.ResponseWriter, r *http
 	return writeJSON(w, http.StatusOK, info)
 }
 
+func (s *Server) getOrphans(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return writeJSON(w, http.StatusOK, s.daemon.Orphans())
+}
+
 func (s *Server) getEvents(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := parseForm(r); err != nil {
 		return err
 	}
 	var since int64 = -1
 	if r.Form.Get("since") != "" {
-		s, err := strconv.ParseInt(r.Form.Get("since"), 10, 64)
+		s, err := parseEventTime(r.Form.Get("since"))
 		if err != nil {
 			return err
 		}
@@ -383,7 +386,7 @@ func (s *Server) getEvents(version version.Version, w http.ResponseWriter, r *ht
 
 	var until int64 = -1
 	if r.Form.Get("until") != "" {
-		u, err := strconv.ParseInt(r.Form.Get("until"), 10, 64)
+		u, err := parseEventTime(r.Form.Get("until"))
 		if err != nil {
 			return err
 		}
@@ -392,7 +395,7 @@ func (s *Server) getEvents(version version.Version, w http.ResponseWriter, r *ht
 	timer := time.NewTimer(0)
 	timer.Stop()
 	if until > 0 {
-		dur := time.Unix(until, 0).Sub(time.Now())
+		dur := time.Unix(0, until).Sub(time.Now())
 		timer = time.NewTimer(dur)
 	}
 
@@ -432,24 +435,38 @@ func (s *Server) getEvents(version version.Version, w http.ResponseWriter, r *ht
 		return c.ID
 	}
 
-	sendEvent := func(ev *jsonmessage.JSONMessage) error {
+	sendEvent := func(ev *types.Event) error {
+		// The clients from before the types of events only know the events
+		// of containers and images
+		if ev.Status == "" && version.LessThan("1.20") {
+			return nil
+		}
+
 		//incoming container filter can be name,id or partial id, convert and replace as a full container id
 		for i, cn := range ef["container"] {
 			ef["container"][i] = getContainerId(cn)
 		}
 
-		if isFiltered(ev.Status, ef["event"]) || isFiltered(ev.From, ef["image"]) ||
-			isFiltered(ev.ID, ef["container"]) {
+		if isFiltered(ev.Type, ef["type"]) || isFiltered(ev.Action, ef["event"]) ||
+			isFiltered(ev.From, ef["image"]) || isFiltered(ev.ID, ef["container"]) {
 			return nil
 		}
 
 		return enc.Encode(ev)
 	}
 
-	current, l := es.Subscribe()
+	var (
+		current []*types.Event
+		l       chan interface{}
+	)
+	if since == -1 {
+		current, l = es.Subscribe()
+	} else if current, l, err = es.SubscribeSince(since); err != nil {
+		return err
+	}
 	defer es.Evict(l)
 	for _, ev := range current {
-		if ev.Time < since {
+		if until > 0 && ev.TimeNano > until {
 			continue
 		}
 		if err := sendEvent(ev); err != nil {
@@ -459,7 +476,7 @@ func (s *Server) getEvents(version version.Version, w http.ResponseWriter, r *ht
 	for {
 		select {
 		case ev := <-l:
-			jev, ok := ev.(*jsonmessage.JSONMessage)
+			jev, ok := ev.(*types.Event)
 			if !ok {
 				continue
 			}
@@ -611,7 +628,7 @@ func (s *Server) postImagesTag(version version.Version, w http.ResponseWriter, r
 	if err := s.daemon.Repositories().Tag(repo, tag, name, force); err != nil {
 		return err
 	}
-	s.daemon.EventsService.Log("tag", utils.ImageReference(repo, tag), "")
+	s.daemon.EventsService.Log("tag", types.ImageEventType, types.EventActor{ID: utils.ImageReference(repo, tag)})
 	w.WriteHeader(http.StatusCreated)
 	return nil
 }
@@ -896,7 +913,10 @@ func (s *Server) postContainersRestart(version version.Version, w http.ResponseW
 		return fmt.Errorf("Missing parameter")
 	}
 
-	timeout, _ := strconv.Atoi(r.Form.Get("t"))
+	timeout := -1
+	if t := r.Form.Get("t"); t != "" {
+		timeout, _ = strconv.Atoi(t)
+	}
 
 	if err := s.daemon.ContainerRestart(vars["name"], timeout); err != nil {
 		return err
@@ -924,6 +944,31 @@ func (s *Server) postContainerRename(version version.Version, w http.ResponseWri
 	return nil
 }
 
+func (s *Server) postContainerUpdate(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	if err := checkForJson(r); err != nil {
+		return err
+	}
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+
+	var config runconfig.UpdateConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		return err
+	}
+
+	warnings, err := s.daemon.ContainerUpdate(vars["name"], &config)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, &types.ContainerUpdateResponse{
+		Warnings: warnings,
+	})
+}
+
 func (s *Server) deleteContainers(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := parseForm(r); err != nil {
 		return err
@@ -1016,7 +1061,10 @@ func (s *Server) postContainersStop(version version.Version, w http.ResponseWrit
 		return fmt.Errorf("Missing parameter")
 	}
 
-	seconds, _ := strconv.Atoi(r.Form.Get("t"))
+	seconds := -1
+	if t := r.Form.Get("t"); t != "" {
+		seconds, _ = strconv.Atoi(t)
+	}
 
 	if err := s.daemon.ContainerStop(vars["name"], seconds); err != nil {
 		if err.Error() == "Container already stopped" {
@@ -1219,6 +1267,13 @@ func (s *Server) postBuild(version version.Version, w http.ResponseWriter, r *ht
 	buildConfig.CpuSetCpus = r.FormValue("cpusetcpus")
 	buildConfig.CpuSetMems = r.FormValue("cpusetmems")
 	buildConfig.CgroupParent = r.FormValue("cgroupparent")
+	buildConfig.Target = r.FormValue("target")
+
+	if buildArgsJSON := r.FormValue("buildargs"); buildArgsJSON != "" {
+		if err := json.Unmarshal([]byte(buildArgsJSON), &buildConfig.BuildArgs); err != nil {
+			return fmt.Errorf("Bad parameter buildargs: %v", err)
+		}
+	}
 
 	// Job cancellation. Note: not all job types support this.
 	if closeNotifier, ok := w.(http.CloseNotifier); ok {
@@ -1246,6 +1301,139 @@ func (s *Server) postBuild(version version.Version, w http.ResponseWriter, r *ht
 	return nil
 }
 
+func (s *Server) getVolumesList(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return writeJSON(w, http.StatusOK, &types.VolumesListResponse{Volumes: s.daemon.Volumes()})
+}
+
+func (s *Server) getVolumeByName(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+
+	v, err := s.daemon.VolumeInspect(vars["name"])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, v)
+}
+
+func (s *Server) postVolumesCreate(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	if err := checkForJson(r); err != nil {
+		return err
+	}
+
+	var req types.VolumeCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	v, err := s.daemon.VolumeCreate(req.Name, req.Driver)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, v)
+}
+
+func (s *Server) deleteVolumes(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+
+	if err := s.daemon.VolumeRm(vars["name"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) getNetworksList(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return writeJSON(w, http.StatusOK, s.daemon.Networks())
+}
+
+func (s *Server) getNetworkByName(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+
+	n, err := s.daemon.NetworkInspect(vars["name"])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, n)
+}
+
+func (s *Server) postNetworksCreate(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	if err := checkForJson(r); err != nil {
+		return err
+	}
+
+	var req types.NetworkCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	id, err := s.daemon.NetworkCreate(req.Name, req.Driver, req.Subnet, req.Gateway)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, &types.NetworkCreateResponse{ID: id})
+}
+
+func (s *Server) postNetworkConnect(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return s.networkConnect(s.daemon.NetworkConnect, w, r, vars)
+}
+
+func (s *Server) postNetworkDisconnect(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return s.networkConnect(s.daemon.NetworkDisconnect, w, r, vars)
+}
+
+func (s *Server) networkConnect(fn func(network, container string) error, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	if err := checkForJson(r); err != nil {
+		return err
+	}
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+
+	var req types.NetworkConnectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	if err := fn(vars["name"], req.Container); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) deleteNetworks(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+
+	if err := s.daemon.NetworkRm(vars["name"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
 func (s *Server) postContainersCopy(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if vars == nil {
 		return fmt.Errorf("Missing parameter")
@@ -1448,6 +1636,7 @@ func createRouter(s *Server) *mux.Router {
 			"/_ping":                          s.ping,
 			"/events":                         s.getEvents,
 			"/info":                           s.getInfo,
+			"/orphans":                        s.getOrphans,
 			"/version":                        s.getVersion,
 			"/images/json":                    s.getImagesJSON,
 			"/images/search":                  s.getImagesSearch,
@@ -1465,34 +1654,45 @@ func createRouter(s *Server) *mux.Router {
 			"/containers/{name:.*}/stats":     s.getContainersStats,
 			"/containers/{name:.*}/attach/ws": s.wsContainersAttach,
 			"/exec/{id:.*}/json":              s.getExecByID,
+			"/volumes":                        s.getVolumesList,
+			"/volumes/{name:.*}":              s.getVolumeByName,
+			"/networks":                       s.getNetworksList,
+			"/networks/{name:.*}":             s.getNetworkByName,
 		},
 		"POST": {
-			"/auth":                         s.postAuth,
-			"/commit":                       s.postCommit,
-			"/build":                        s.postBuild,
-			"/images/create":                s.postImagesCreate,
-			"/images/load":                  s.postImagesLoad,
-			"/images/{name:.*}/push":        s.postImagesPush,
-			"/images/{name:.*}/tag":         s.postImagesTag,
-			"/containers/create":            s.postContainersCreate,
-			"/containers/{name:.*}/kill":    s.postContainersKill,
-			"/containers/{name:.*}/pause":   s.postContainersPause,
-			"/containers/{name:.*}/unpause": s.postContainersUnpause,
-			"/containers/{name:.*}/restart": s.postContainersRestart,
-			"/containers/{name:.*}/start":   s.postContainersStart,
-			"/containers/{name:.*}/stop":    s.postContainersStop,
-			"/containers/{name:.*}/wait":    s.postContainersWait,
-			"/containers/{name:.*}/resize":  s.postContainersResize,
-			"/containers/{name:.*}/attach":  s.postContainersAttach,
-			"/containers/{name:.*}/copy":    s.postContainersCopy,
-			"/containers/{name:.*}/exec":    s.postContainerExecCreate,
-			"/exec/{name:.*}/start":         s.postContainerExecStart,
-			"/exec/{name:.*}/resize":        s.postContainerExecResize,
-			"/containers/{name:.*}/rename":  s.postContainerRename,
+			"/auth":                          s.postAuth,
+			"/commit":                        s.postCommit,
+			"/build":                         s.postBuild,
+			"/images/create":                 s.postImagesCreate,
+			"/images/load":                   s.postImagesLoad,
+			"/images/{name:.*}/push":         s.postImagesPush,
+			"/images/{name:.*}/tag":          s.postImagesTag,
+			"/containers/create":             s.postContainersCreate,
+			"/containers/{name:.*}/kill":     s.postContainersKill,
+			"/containers/{name:.*}/pause":    s.postContainersPause,
+			"/containers/{name:.*}/unpause":  s.postContainersUnpause,
+			"/containers/{name:.*}/restart":  s.postContainersRestart,
+			"/containers/{name:.*}/start":    s.postContainersStart,
+			"/containers/{name:.*}/stop":     s.postContainersStop,
+			"/containers/{name:.*}/wait":     s.postContainersWait,
+			"/containers/{name:.*}/resize":   s.postContainersResize,
+			"/containers/{name:.*}/attach":   s.postContainersAttach,
+			"/containers/{name:.*}/copy":     s.postContainersCopy,
+			"/containers/{name:.*}/exec":     s.postContainerExecCreate,
+			"/exec/{name:.*}/start":          s.postContainerExecStart,
+			"/exec/{name:.*}/resize":         s.postContainerExecResize,
+			"/containers/{name:.*}/rename":   s.postContainerRename,
+			"/containers/{name:.*}/update":   s.postContainerUpdate,
+			"/volumes/create":                s.postVolumesCreate,
+			"/networks/create":               s.postNetworksCreate,
+			"/networks/{name:.*}/connect":    s.postNetworkConnect,
+			"/networks/{name:.*}/disconnect": s.postNetworkDisconnect,
 		},
 		"DELETE": {
 			"/containers/{name:.*}": s.deleteContainers,
 			"/images/{name:.*}":     s.deleteImages,
+			"/volumes/{name:.*}":    s.deleteVolumes,
+			"/networks/{name:.*}":   s.deleteNetworks,
 		},
 		"OPTIONS": {
 			"": s.optionsHandler,
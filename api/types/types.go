@@ -18,6 +18,13 @@ type ContainerCreateResponse struct {
 	Warnings []string `json:"Warnings"`
 }
 
+// ContainerUpdateResponse contains the information returned to a client on
+// the update of a container.
+type ContainerUpdateResponse struct {
+	// Warnings are any warnings encountered during the update of the container.
+	Warnings []string `json:"Warnings"`
+}
+
 // POST /containers/{name:.*}/exec
 type ContainerExecCreateResponse struct {
 	// ID is the exec ID.
@@ -179,6 +186,29 @@ type ExecStartCheck struct {
 	Tty bool
 }
 
+// Health states
+const (
+	NoHealthcheck = "none"      // Indicates there is no healthcheck
+	Starting      = "starting"  // Starting indicates that the container is not yet ready
+	Healthy       = "healthy"   // Healthy indicates that the container is running correctly
+	Unhealthy     = "unhealthy" // Unhealthy indicates that the container has a problem
+)
+
+// HealthcheckResult stores information about a single run of a healthcheck probe
+type HealthcheckResult struct {
+	Start    time.Time // Start is the time this check started
+	End      time.Time // End is the time this check ended
+	ExitCode int       // ExitCode meanings: 0=healthy, 1=unhealthy
+	Output   string    // Output from last check
+}
+
+// Health stores information about the container's healthcheck results
+type Health struct {
+	Status        string               // Status is one of Starting, Healthy or Unhealthy
+	FailingStreak int                  // FailingStreak is the number of consecutive failures
+	Log           []*HealthcheckResult // Log contains the last few results (oldest first)
+}
+
 type ContainerState struct {
 	Running    bool
 	Paused     bool
@@ -190,6 +220,7 @@ type ContainerState struct {
 	Error      string
 	StartedAt  time.Time
 	FinishedAt time.Time
+	Health     *Health `json:",omitempty"`
 }
 
 // GET "/containers/{name:.*}/json"
@@ -208,6 +239,7 @@ type ContainerJSON struct {
 	LogPath         string
 	Name            string
 	RestartCount    int
+	LastRestartedAt time.Time
 	Driver          string
 	ExecDriver      string
 	MountLabel      string
@@ -217,4 +249,114 @@ type ContainerJSON struct {
 	AppArmorProfile string
 	ExecIDs         []string
 	HostConfig      *runconfig.HostConfig
+	Storage         *ContainerStorage `json:",omitempty"`
+}
+
+// ContainerStorage is the disk usage of the layer of a container, if the
+// storage driver reports it.
+type ContainerStorage struct {
+	Quota uint64 // Quota is the maximum size in bytes, 0 without a limit
+	Used  uint64 // Used is the space used in bytes
+}
+
+// GET "/orphans"
+// OrphansReport lists what the daemon found left behind by a crash when it
+// started: the names, the containers of the exec driver, the mounts and
+// the layers of containers and images which do not exist or do not run.
+type OrphansReport struct {
+	// DryRun is set if the orphans were only listed, not removed.
+	DryRun     bool
+	Names      []string
+	Containers []string
+	Mounts     []string
+	Layers     []string
+	Errors     []string
+}
+
+// GET "/volumes/{name:.*}"
+type Volume struct {
+	Name       string
+	Driver     string
+	Mountpoint string
+	Containers []string `json:",omitempty"`
+}
+
+// GET "/volumes"
+type VolumesListResponse struct {
+	Volumes []*Volume
+}
+
+// POST "/volumes/create"
+type VolumeCreateRequest struct {
+	Name   string
+	Driver string
+}
+
+// GET "/networks/{name:.*}"
+type NetworkResource struct {
+	Name       string
+	ID         string `json:"Id"`
+	Driver     string
+	Subnet     string                      `json:",omitempty"`
+	Gateway    string                      `json:",omitempty"`
+	Containers map[string]EndpointResource `json:",omitempty"`
+}
+
+// EndpointResource describes a container attached to a network
+type EndpointResource struct {
+	Name        string
+	IPAddress   string `json:",omitempty"`
+	IPPrefixLen int    `json:",omitempty"`
+	MacAddress  string `json:",omitempty"`
+}
+
+// POST "/networks/create"
+type NetworkCreateRequest struct {
+	Name    string
+	Driver  string
+	Subnet  string
+	Gateway string
+}
+
+// POST "/networks/create"
+type NetworkCreateResponse struct {
+	ID string `json:"Id"`
+}
+
+// POST "/networks/{name:.*}/connect" and "/networks/{name:.*}/disconnect"
+type NetworkConnectRequest struct {
+	Container string
+}
+
+// Types of the objects events are about
+const (
+	ContainerEventType = "container"
+	ImageEventType     = "image"
+	VolumeEventType    = "volume"
+	NetworkEventType   = "network"
+	DaemonEventType    = "daemon"
+)
+
+// EventActor is the object an event is about: its ID, and attributes
+// describing it such as the name and labels of a container.
+type EventActor struct {
+	ID         string
+	Attributes map[string]string `json:",omitempty"`
+}
+
+// GET "/events"
+// Event is what happened to an object of the daemon. Status, ID and From are
+// the fields of the events of containers and images from before Type and
+// Actor, kept for the existing clients.
+type Event struct {
+	Status string `json:"status,omitempty"`
+	ID     string `json:"id,omitempty"`
+	From   string `json:"from,omitempty"`
+
+	Type   string
+	Action string
+	Actor  EventActor
+
+	Time     int64 `json:"time,omitempty"`
+	TimeNano int64 `json:"timeNano,omitempty"`
 }
@@ -14,11 +14,14 @@ import (
 	"regexp"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/nat"
 	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/runconfig"
 )
 
@@ -167,33 +170,48 @@ func add(b *Builder, args []string, attributes map[string]bool, original string)
 
 // COPY foo /path
 //
-// Same as 'ADD' but without the tar and remote url handling.
+// Same as 'ADD' but without the tar and remote url handling. With --from,
+// the files are copied from an earlier build stage or from an image
+// instead of the context.
 //
 func dispatchCopy(b *Builder, args []string, attributes map[string]bool, original string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("COPY requires at least two arguments")
 	}
 
+	flFrom := b.BuilderFlags.AddString("from", "")
+
 	if err := b.BuilderFlags.Parse(); err != nil {
 		return err
 	}
 
+	if flFrom.Value != "" {
+		return b.runCopyFrom(args, flFrom.Value)
+	}
+
 	return b.runContextCommand(args, false, false, "COPY")
 }
 
-// FROM imagename
+// FROM imagename [AS name]
 //
-// This sets the image the dockerfile will build on top of.
+// This sets the image the dockerfile will build on top of. Every FROM
+// starts a new build stage, which can be named so that later stages can
+// use it as their base or copy files from it.
 //
 func from(b *Builder, args []string, attributes map[string]bool, original string) error {
-	if len(args) != 1 {
-		return fmt.Errorf("FROM requires one argument")
+	stageName, err := parseStageName(args)
+	if err != nil {
+		return err
 	}
 
 	if err := b.BuilderFlags.Parse(); err != nil {
 		return err
 	}
 
+	if err := b.startStage(stageName); err != nil {
+		return err
+	}
+
 	name := args[0]
 
 	if name == NoBaseImageSpecifier {
@@ -202,23 +220,17 @@ func from(b *Builder, args []string, attributes map[string]bool, original string
 		return nil
 	}
 
-	image, err := b.Daemon.Repositories().LookupImage(name)
-	if b.Pull {
-		image, err = b.pullImage(name)
+	if id, ok := b.stageImages[strings.ToLower(name)]; ok {
+		image, err := b.Daemon.Graph().Get(id)
 		if err != nil {
 			return err
 		}
+		return b.processImageFrom(image)
 	}
-	if err != nil {
-		if b.Daemon.Graph().IsNotExist(err, name) {
-			image, err = b.pullImage(name)
-		}
 
-		// note that the top level err will still be !nil here if IsNotExist is
-		// not the error. This approach just simplifies the logic a bit.
-		if err != nil {
-			return err
-		}
+	image, err := b.lookupImage(name)
+	if err != nil {
+		return err
 	}
 
 	return b.processImageFrom(image)
@@ -325,6 +337,18 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 
 	defer func(cmd *runconfig.Command) { b.Config.Cmd = cmd }(cmd)
 
+	// The build args are set in the environment of the container running
+	// the command but not in the config of the committed image. As the
+	// container's config is what a cached image is looked up with, a
+	// different value of a build arg is a cache miss.
+	imageConfig := b.Config
+	if buildArgsEnv := b.buildArgsEnv(); len(buildArgsEnv) > 0 {
+		runConfig := *b.Config
+		runConfig.Env = append(append([]string{}, b.Config.Env...), buildArgsEnv...)
+		b.Config = &runConfig
+	}
+	defer func() { b.Config = imageConfig }()
+
 	logrus.Debugf("[BUILDER] Command to be executed: %v", b.Config.Cmd)
 
 	hit, err := b.probeCache()
@@ -349,6 +373,7 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 	if err != nil {
 		return err
 	}
+	b.Config = imageConfig
 	if err := b.commit(c.ID, cmd, "run"); err != nil {
 		return err
 	}
@@ -536,3 +561,162 @@ func volume(b *Builder, args []string, attributes map[string]bool, original stri
 	}
 	return nil
 }
+
+// HEALTHCHECK foo
+//
+// Set the default healthcheck command to run in the container (which may be empty).
+// Argument handling is the same as RUN.
+//
+func healthcheck(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("HEALTHCHECK requires an argument")
+	}
+	typ := strings.ToUpper(args[0])
+	args = args[1:]
+	if typ == "NONE" {
+		if len(args) != 0 {
+			return fmt.Errorf("HEALTHCHECK NONE takes no arguments")
+		}
+		if err := b.BuilderFlags.Parse(); err != nil {
+			return err
+		}
+		b.Config.Healthcheck = &runconfig.HealthConfig{
+			Test: []string{typ},
+		}
+		return b.commit("", b.Config.Cmd, "HEALTHCHECK NONE")
+	}
+
+	if typ != "CMD" {
+		return fmt.Errorf("Unknown type %#v in HEALTHCHECK (try CMD)", typ)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("Missing command after HEALTHCHECK CMD")
+	}
+
+	flInterval := b.BuilderFlags.AddString("interval", "")
+	flTimeout := b.BuilderFlags.AddString("timeout", "")
+	flRetries := b.BuilderFlags.AddString("retries", "")
+
+	if err := b.BuilderFlags.Parse(); err != nil {
+		return err
+	}
+
+	healthcheck := &runconfig.HealthConfig{}
+
+	if attributes["json"] {
+		healthcheck.Test = append([]string{"CMD"}, args...)
+	} else {
+		healthcheck.Test = []string{"CMD-SHELL", strings.Join(args, " ")}
+	}
+
+	interval, err := parseOptInterval(flInterval)
+	if err != nil {
+		return err
+	}
+	healthcheck.Interval = interval
+
+	timeout, err := parseOptInterval(flTimeout)
+	if err != nil {
+		return err
+	}
+	healthcheck.Timeout = timeout
+
+	if flRetries.Value != "" {
+		retries, err := strconv.Atoi(flRetries.Value)
+		if err != nil {
+			return err
+		}
+		if retries < 1 {
+			return fmt.Errorf("--retries must be at least 1 (not %d)", retries)
+		}
+		healthcheck.Retries = retries
+	}
+
+	b.Config.Healthcheck = healthcheck
+	return b.commit("", b.Config.Cmd, fmt.Sprintf("HEALTHCHECK %q", healthcheck.Test))
+}
+
+// ARG name[=value]
+//
+// Declares a build-time variable which can be set with --build-arg, with an
+// optional default value. The variable can be used in the instructions
+// which follow, where it is overridden by an ENV of the same name, and is
+// set in the environment of RUN. It is not persisted in the image.
+//
+func arg(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("ARG requires exactly one argument definition")
+	}
+
+	if err := b.BuilderFlags.Parse(); err != nil {
+		return err
+	}
+
+	var (
+		name       string
+		value      string
+		hasDefault bool
+	)
+
+	// Unlike ENV, the value is optional so the name and value are split
+	// here rather than by the parser.
+	arg := args[0]
+	if strings.Contains(arg, "=") {
+		parts := strings.SplitN(arg, "=", 2)
+		name = parts[0]
+		value = parts[1]
+		hasDefault = true
+	} else {
+		name = arg
+	}
+	if name == "" {
+		return fmt.Errorf("ARG requires a name")
+	}
+
+	b.allowedBuildArgs[name] = true
+	b.consumedBuildArgs[name] = true
+	if hasDefault {
+		b.buildArgDefaults[name] = value
+	}
+
+	return b.commit("", b.Config.Cmd, fmt.Sprintf("ARG %s", arg))
+}
+
+// STOPSIGNAL signal
+//
+// Set the signal that will be used to stop the container, by number or by
+// name with or without the SIG prefix.
+//
+func stopSignal(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("STOPSIGNAL requires exactly one argument")
+	}
+
+	if err := b.BuilderFlags.Parse(); err != nil {
+		return err
+	}
+
+	sig := args[0]
+	if _, err := signal.ParseSignal(sig); err != nil {
+		return err
+	}
+
+	b.Config.StopSignal = sig
+	return b.commit("", b.Config.Cmd, fmt.Sprintf("STOPSIGNAL %v", args))
+}
+
+// parseOptInterval parses the duration of a HEALTHCHECK flag. An empty
+// value means to use the default.
+func parseOptInterval(f *Flag) (time.Duration, error) {
+	if f.Value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(f.Value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("--%s must be positive (not %s)", f.name, f.Value)
+	}
+	return d, nil
+}
@@ -0,0 +1,77 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/runconfig"
+)
+
+func newTestBuilder(buildArgs map[string]string) *Builder {
+	return &Builder{
+		Config:            &runconfig.Config{},
+		BuilderFlags:      NewBuilderFlags(),
+		disableCommit:     true,
+		buildArgs:         buildArgs,
+		allowedBuildArgs:  make(map[string]bool),
+		buildArgDefaults:  make(map[string]string),
+		consumedBuildArgs: make(map[string]bool),
+	}
+}
+
+func TestArg(t *testing.T) {
+	b := newTestBuilder(map[string]string{"http_proxy": "http://proxy:3128"})
+
+	if err := arg(b, []string{"http_proxy=http://default:3128"}, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := arg(b, []string{"version=1.0"}, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := arg(b, []string{"unset"}, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"http_proxy=http://proxy:3128", "version=1.0"}
+	if env := b.buildArgsEnv(); !reflect.DeepEqual(env, expected) {
+		t.Fatalf("Expected %v, got %v", expected, env)
+	}
+
+	// ENV takes precedence over ARG
+	b.Config.Env = []string{"version=2.0"}
+	if env := b.buildArgsEnv(); !reflect.DeepEqual(env, expected[:1]) {
+		t.Fatalf("Expected %v, got %v", expected[:1], env)
+	}
+	word, err := ProcessWord("$version-$http_proxy", b.buildEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if word != "2.0-http://proxy:3128" {
+		t.Fatalf("Unexpected expansion %q", word)
+	}
+
+	if err := arg(b, []string{"a", "b"}, nil, ""); err == nil {
+		t.Fatal("Expected an error for more than one argument")
+	}
+	if err := arg(b, []string{"=value"}, nil, ""); err == nil {
+		t.Fatal("Expected an error for an empty name")
+	}
+}
+
+func TestStopSignal(t *testing.T) {
+	b := newTestBuilder(nil)
+
+	if err := stopSignal(b, []string{"SIGQUIT"}, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if b.Config.StopSignal != "SIGQUIT" {
+		t.Fatalf("Expected stop signal SIGQUIT, got %q", b.Config.StopSignal)
+	}
+
+	if err := stopSignal(b, []string{"SIGNOPE"}, nil, ""); err == nil {
+		t.Fatal("Expected an error for an invalid signal")
+	}
+	if b.Config.StopSignal != "SIGQUIT" {
+		t.Fatalf("Expected the stop signal to be unchanged, got %q", b.Config.StopSignal)
+	}
+}
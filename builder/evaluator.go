@@ -24,6 +24,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/Sirupsen/logrus"
@@ -43,34 +44,38 @@ import (
 
 // Environment variable interpolation will happen on these statements only.
 var replaceEnvAllowed = map[string]struct{}{
-	command.Env:     {},
-	command.Label:   {},
-	command.Add:     {},
-	command.Copy:    {},
-	command.Workdir: {},
-	command.Expose:  {},
-	command.Volume:  {},
-	command.User:    {},
+	command.Env:        {},
+	command.Label:      {},
+	command.Add:        {},
+	command.Copy:       {},
+	command.Workdir:    {},
+	command.Expose:     {},
+	command.Volume:     {},
+	command.User:       {},
+	command.StopSignal: {},
 }
 
 var evaluateTable map[string]func(*Builder, []string, map[string]bool, string) error
 
 func init() {
 	evaluateTable = map[string]func(*Builder, []string, map[string]bool, string) error{
-		command.Env:        env,
-		command.Label:      label,
-		command.Maintainer: maintainer,
-		command.Add:        add,
-		command.Copy:       dispatchCopy, // copy() is a go builtin
-		command.From:       from,
-		command.Onbuild:    onbuild,
-		command.Workdir:    workdir,
-		command.Run:        run,
-		command.Cmd:        cmd,
-		command.Entrypoint: entrypoint,
-		command.Expose:     expose,
-		command.Volume:     volume,
-		command.User:       user,
+		command.Env:         env,
+		command.Label:       label,
+		command.Maintainer:  maintainer,
+		command.Add:         add,
+		command.Copy:        dispatchCopy, // copy() is a go builtin
+		command.From:        from,
+		command.Onbuild:     onbuild,
+		command.Workdir:     workdir,
+		command.Run:         run,
+		command.Cmd:         cmd,
+		command.Entrypoint:  entrypoint,
+		command.Expose:      expose,
+		command.Volume:      volume,
+		command.User:        user,
+		command.Healthcheck: healthcheck,
+		command.Arg:         arg,
+		command.StopSignal:  stopSignal,
 	}
 }
 
@@ -130,6 +135,19 @@ type Builder struct {
 	memory       int64
 	memorySwap   int64
 
+	buildArgs         map[string]string // build-time variables given with --build-arg
+	allowedBuildArgs  map[string]bool   // build-time variables declared with ARG in the current stage
+	buildArgDefaults  map[string]string // default values of the ARGs of the current stage
+	consumedBuildArgs map[string]bool   // build-time variables declared with ARG in any stage
+
+	// A Dockerfile with several FROM instructions is built in stages. Each
+	// stage starts from a clean state, and the image of the last stage, or
+	// of the target stage, is the result of the build.
+	target      string            // name of the stage to stop the build at
+	stageIndex  int               // index of the current stage
+	stageName   string            // name of the current stage, set with FROM ... AS
+	stageImages map[string]string // images of the completed stages, by index and name
+
 	cancelled <-chan struct{} // When closed, job was cancelled.
 }
 
@@ -164,8 +182,24 @@ func (b *Builder) Run(context io.Reader) (string, error) {
 	b.Config = &runconfig.Config{}
 
 	b.TmpContainers = map[string]struct{}{}
+	b.allowedBuildArgs = make(map[string]bool)
+	b.buildArgDefaults = make(map[string]string)
+	b.consumedBuildArgs = make(map[string]bool)
+	b.stageIndex = -1
+	b.stageImages = make(map[string]string)
+
+	if b.target != "" {
+		if err := checkTarget(b.dockerfile, b.target); err != nil {
+			return "", err
+		}
+	}
 
 	for i, n := range b.dockerfile.Children {
+		// The build stops at the FROM following the target stage.
+		if n.Value == command.From && b.target != "" && b.stageName == b.target {
+			break
+		}
+
 		select {
 		case <-b.cancelled:
 			logrus.Debug("Builder: build cancelled!")
@@ -190,6 +224,19 @@ func (b *Builder) Run(context io.Reader) (string, error) {
 		return "", fmt.Errorf("No image was generated. Is your Dockerfile empty?")
 	}
 
+	// Fail the build if a --build-arg was not declared with ARG, as it is
+	// most likely a typo.
+	var unused []string
+	for name := range b.buildArgs {
+		if !b.consumedBuildArgs[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return "", fmt.Errorf("One or more build-args %v were not consumed, failing build.", unused)
+	}
+
 	fmt.Fprintf(b.OutStream, "Successfully built %s\n", stringid.TruncateID(b.image))
 	return b.image, nil
 }
@@ -321,7 +368,7 @@ func (b *Builder) dispatch(stepN int, ast *parser.Node) error {
 		str = ast.Value
 		if _, ok := replaceEnvAllowed[cmd]; ok {
 			var err error
-			str, err = ProcessWord(ast.Value, b.Config.Env)
+			str, err = ProcessWord(ast.Value, b.buildEnv())
 			if err != nil {
 				return err
 			}
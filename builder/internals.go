@@ -233,17 +233,7 @@ func calcCopyInfo(b *Builder, cmdName string, cInfos *[]*copyInfo, origPath stri
 	}
 	origPath = strings.TrimPrefix(origPath, "./")
 
-	// Twiddle the destPath when its a relative path - meaning, make it
-	// relative to the WORKINGDIR
-	if !filepath.IsAbs(destPath) {
-		hasSlash := strings.HasSuffix(destPath, "/")
-		destPath = filepath.Join("/", b.Config.WorkingDir, destPath)
-
-		// Make sure we preserve any trailing slash
-		if hasSlash {
-			destPath += "/"
-		}
-	}
+	destPath = b.absDestPath(destPath)
 
 	// In the remote/URL case, download it and gen its hashcode
 	if urlutil.IsURL(origPath) {
@@ -428,6 +418,22 @@ func calcCopyInfo(b *Builder, cmdName string, cInfos *[]*copyInfo, origPath stri
 	return nil
 }
 
+// absDestPath makes a relative destination path of ADD or COPY relative to
+// the WORKDIR.
+func (b *Builder) absDestPath(destPath string) string {
+	if filepath.IsAbs(destPath) {
+		return destPath
+	}
+	hasSlash := strings.HasSuffix(destPath, "/")
+	destPath = filepath.Join("/", b.Config.WorkingDir, destPath)
+
+	// Make sure we preserve any trailing slash
+	if hasSlash {
+		destPath += "/"
+	}
+	return destPath
+}
+
 func ContainsWildcards(name string) bool {
 	for i := 0; i < len(name); i++ {
 		ch := name[i]
@@ -474,6 +480,30 @@ func (b *Builder) pullImage(name string) (*imagepkg.Image, error) {
 	return image, nil
 }
 
+// lookupImage returns the image name refers to, pulling it if it does not
+// exist locally or if the build was asked to always pull.
+func (b *Builder) lookupImage(name string) (*imagepkg.Image, error) {
+	image, err := b.Daemon.Repositories().LookupImage(name)
+	if b.Pull {
+		image, err = b.pullImage(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		if b.Daemon.Graph().IsNotExist(err, name) {
+			image, err = b.pullImage(name)
+		}
+
+		// note that the top level err will still be !nil here if IsNotExist is
+		// not the error. This approach just simplifies the logic a bit.
+		if err != nil {
+			return nil, err
+		}
+	}
+	return image, nil
+}
+
 func (b *Builder) processImageFrom(img *imagepkg.Image) error {
 	b.image = img.ID
 
@@ -520,6 +550,42 @@ func (b *Builder) processImageFrom(img *imagepkg.Image) error {
 	return nil
 }
 
+// buildArgsEnv returns the build args declared so far in the stage which
+// have a value, given with --build-arg or defaulted by ARG, as environment
+// variables. The variables already set with ENV are left out as they take
+// precedence. The result is sorted so that it can be compared with the
+// config of a cached image.
+func (b *Builder) buildArgsEnv() []string {
+	var env []string
+	for name := range b.allowedBuildArgs {
+		value, ok := b.buildArgs[name]
+		if !ok {
+			value, ok = b.buildArgDefaults[name]
+		}
+		if !ok || hasEnv(b.Config.Env, name) {
+			continue
+		}
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// buildEnv returns the environment used to expand the variables of an
+// instruction: the ENV variables, followed by the build args.
+func (b *Builder) buildEnv() []string {
+	return append(append([]string{}, b.Config.Env...), b.buildArgsEnv()...)
+}
+
+func hasEnv(env []string, name string) bool {
+	for _, e := range env {
+		if strings.SplitN(e, "=", 2)[0] == name {
+			return true
+		}
+	}
+	return false
+}
+
 // probeCache checks to see if image-caching is enabled (`b.UtilizeCache`)
 // and if so attempts to look up the current `b.image` and `b.Config` pair
 // in the current server `b.Daemon`. If an image is found, probeCache returns
@@ -651,10 +717,15 @@ func (b *Builder) checkPathForAddition(orig string) error {
 }
 
 func (b *Builder) addContext(container *daemon.Container, orig, dest string, decompress bool) error {
+	return addPath(container, path.Join(b.contextPath, orig), orig, dest, decompress)
+}
+
+// addPath copies origPath, a path on the host named orig in the messages,
+// to dest in the container.
+func addPath(container *daemon.Container, origPath, orig, dest string, decompress bool) error {
 	var (
 		err        error
 		destExists = true
-		origPath   = path.Join(b.contextPath, orig)
 		destPath   string
 	)
 
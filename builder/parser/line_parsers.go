@@ -278,6 +278,40 @@ func parseMaybeJSON(rest string) (*Node, map[string]bool, error) {
 	return node, nil, nil
 }
 
+// parseHealthConfig parses the HEALTHCHECK instruction, the first word of
+// which is its type (CMD or NONE) followed by an optional command handled
+// like the arguments of CMD.
+//
+// HEALTHCHECK CMD curl -f http://localhost/ -> (healthcheck "CMD" "curl -f http://localhost/")
+//
+func parseHealthConfig(rest string) (*Node, map[string]bool, error) {
+	// Find end of first argument
+	var sep int
+	for ; sep < len(rest); sep++ {
+		if unicode.IsSpace(rune(rest[sep])) {
+			break
+		}
+	}
+	next := sep
+	for ; next < len(rest); next++ {
+		if !unicode.IsSpace(rune(rest[next])) {
+			break
+		}
+	}
+
+	if sep == 0 {
+		return nil, nil, nil
+	}
+
+	typ := rest[:sep]
+	cmd, attrs, err := parseMaybeJSON(rest[next:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Node{Value: typ, Next: cmd}, attrs, err
+}
+
 // parseMaybeJSONToList determines if the argument appears to be a JSON array. If
 // so, passes to parseJSON; if not, attempts to parse it as a whitespace
 // delimited string.
@@ -0,0 +1,209 @@
+package builder
+
+// This file contains the support for multi-stage builds: Dockerfiles with
+// several FROM instructions, each of them starting a new stage which can
+// copy files from the images of the earlier ones with COPY --from.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/builder/command"
+	"github.com/docker/docker/builder/parser"
+	"github.com/docker/docker/pkg/symlink"
+	"github.com/docker/docker/runconfig"
+)
+
+var validStageName = regexp.MustCompile(`^[a-z][a-z0-9_.-]*$`)
+
+// parseStageName returns the name of the stage given with the arguments of
+// FROM, or "" if the stage is not named.
+func parseStageName(args []string) (string, error) {
+	switch {
+	case len(args) == 1:
+		return "", nil
+	case len(args) == 3 && strings.EqualFold(args[1], "AS"):
+		name := strings.ToLower(args[2])
+		if !validStageName.MatchString(name) {
+			return "", fmt.Errorf("invalid name for build stage: %q, name can't start with a number or contain symbols", args[2])
+		}
+		return name, nil
+	}
+	return "", fmt.Errorf("FROM requires either one or three arguments")
+}
+
+// checkTarget returns an error if the Dockerfile has no stage named target.
+func checkTarget(dockerfile *parser.Node, target string) error {
+	for _, n := range dockerfile.Children {
+		if n.Value != command.From {
+			continue
+		}
+		var args []string
+		for next := n.Next; next != nil; next = next.Next {
+			args = append(args, next.Value)
+		}
+		if name, err := parseStageName(args); err == nil && name == strings.ToLower(target) {
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to reach build target %s in Dockerfile", target)
+}
+
+// startStage records the image of the current stage, if any, so that later
+// stages can refer to it, and resets the builder for a new stage.
+func (b *Builder) startStage(name string) error {
+	if b.stageIndex >= 0 {
+		b.stageImages[strconv.Itoa(b.stageIndex)] = b.image
+		if b.stageName != "" {
+			b.stageImages[b.stageName] = b.image
+		}
+	}
+	if name != "" {
+		if _, exists := b.stageImages[name]; exists {
+			return fmt.Errorf("duplicate name for build stage: %q", name)
+		}
+	}
+
+	b.stageIndex++
+	b.stageName = name
+
+	if b.stageIndex > 0 {
+		b.Config = &runconfig.Config{}
+		b.image = ""
+		b.noBaseImage = false
+		b.maintainer = ""
+		b.cmdSet = false
+		b.cacheBusted = false
+		b.allowedBuildArgs = make(map[string]bool)
+		b.buildArgDefaults = make(map[string]string)
+	}
+	return nil
+}
+
+// stageImage returns the ID of the image of the completed stage referred to
+// by name or index, or else of the image called name.
+func (b *Builder) stageImage(name string) (string, error) {
+	if id, ok := b.stageImages[strings.ToLower(name)]; ok {
+		if id == "" {
+			return "", fmt.Errorf("Build stage %s has no image to copy from", name)
+		}
+		return id, nil
+	}
+	if b.stageName != "" && strings.EqualFold(name, b.stageName) {
+		return "", fmt.Errorf("Build stage %s can't copy from itself", name)
+	}
+	img, err := b.lookupImage(name)
+	if err != nil {
+		return "", err
+	}
+	return img.ID, nil
+}
+
+// runCopyFrom copies files from the root filesystem of an earlier stage, or
+// of an image, into the image being built. The sources are paths in that
+// filesystem and may contain wildcards.
+func (b *Builder) runCopyFrom(args []string, from string) error {
+	imageID, err := b.stageImage(from)
+	if err != nil {
+		return err
+	}
+
+	driver := b.Daemon.GraphDriver()
+	rootfs, err := driver.Get(imageID, "")
+	if err != nil {
+		return err
+	}
+	defer driver.Put(imageID)
+
+	dest := b.absDestPath(args[len(args)-1])
+
+	var origs, origPaths []string
+	for _, orig := range args[:len(args)-1] {
+		orig = filepath.Join("/", orig)
+
+		matches := []string{orig}
+		if ContainsWildcards(orig) {
+			globbed, err := filepath.Glob(filepath.Join(rootfs, orig))
+			if err != nil {
+				return err
+			}
+			matches = matches[:0]
+			for _, m := range globbed {
+				rel, err := filepath.Rel(rootfs, m)
+				if err != nil {
+					return err
+				}
+				matches = append(matches, filepath.Join("/", rel))
+			}
+		}
+
+		for _, m := range matches {
+			origPath, err := symlink.FollowSymlinkInScope(filepath.Join(rootfs, m), rootfs)
+			if err != nil {
+				return err
+			}
+			if _, err := os.Stat(origPath); err != nil {
+				if os.IsNotExist(err) {
+					return fmt.Errorf("%s: no such file or directory", m)
+				}
+				return err
+			}
+			origs = append(origs, m)
+			origPaths = append(origPaths, origPath)
+		}
+	}
+
+	if len(origs) == 0 {
+		return fmt.Errorf("No source files were specified")
+	}
+
+	if len(origs) > 1 && !strings.HasSuffix(dest, "/") {
+		return fmt.Errorf("When using COPY with more than one source file, the destination must be a directory and end with a /")
+	}
+
+	// The image of a stage only changes when the stage is rebuilt, so its
+	// ID and the copied paths make up the cache look-up string.
+	hasher := sha256.New()
+	hasher.Write([]byte(imageID + ":" + strings.Join(origs, ",")))
+	srcHash := "from:" + hex.EncodeToString(hasher.Sum(nil))
+
+	b.Config.Image = b.image
+
+	cmd := b.Config.Cmd
+	b.Config.Cmd = runconfig.NewCommand("/bin/sh", "-c", fmt.Sprintf("#(nop) COPY %s in %s", srcHash, dest))
+	defer func(cmd *runconfig.Command) { b.Config.Cmd = cmd }(cmd)
+
+	hit, err := b.probeCache()
+	if err != nil {
+		return err
+	}
+
+	if hit {
+		return nil
+	}
+
+	container, _, err := b.Daemon.Create(b.Config, nil, "")
+	if err != nil {
+		return err
+	}
+	b.TmpContainers[container.ID] = struct{}{}
+
+	if err := container.Mount(); err != nil {
+		return err
+	}
+	defer container.Unmount()
+
+	for i, origPath := range origPaths {
+		if err := addPath(container, origPath, origs[i], dest, false); err != nil {
+			return err
+		}
+	}
+
+	return b.commit(container.ID, cmd, fmt.Sprintf("COPY --from=%s %s in %s", from, strings.Join(origs, " "), dest))
+}
@@ -0,0 +1,84 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/builder/parser"
+)
+
+func TestParseStageName(t *testing.T) {
+	valid := map[string][]string{
+		"":        {"busybox"},
+		"builder": {"golang:1.4", "AS", "Builder"},
+		"test.1":  {"busybox", "as", "test.1"},
+	}
+	for expected, args := range valid {
+		name, err := parseStageName(args)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %s", args, err)
+		}
+		if name != expected {
+			t.Fatalf("Expected stage name %q for %v, got %q", expected, args, name)
+		}
+	}
+
+	invalid := [][]string{
+		{"busybox", "builder"},
+		{"busybox", "IS", "builder"},
+		{"busybox", "AS", "1st"},
+		{"busybox", "AS", "a$b"},
+	}
+	for _, args := range invalid {
+		if _, err := parseStageName(args); err == nil {
+			t.Fatalf("Expected an error for %v", args)
+		}
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	dockerfile, err := parser.Parse(strings.NewReader("FROM busybox AS build\nRUN true\nFROM busybox\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkTarget(dockerfile, "BUILD"); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkTarget(dockerfile, "test"); err == nil {
+		t.Fatal("Expected an error for a missing target")
+	}
+}
+
+func TestStartStage(t *testing.T) {
+	b := newTestBuilder(nil)
+	b.stageIndex = -1
+	b.stageImages = make(map[string]string)
+
+	if err := b.startStage("build"); err != nil {
+		t.Fatal(err)
+	}
+	b.image = "abc"
+	b.Config.Env = []string{"GOPATH=/go"}
+	b.allowedBuildArgs["version"] = true
+
+	if err := b.startStage(""); err != nil {
+		t.Fatal(err)
+	}
+	if b.stageImages["0"] != "abc" || b.stageImages["build"] != "abc" {
+		t.Fatalf("Expected the image of the first stage to be recorded, got %v", b.stageImages)
+	}
+	if b.image != "" || len(b.Config.Env) != 0 || len(b.allowedBuildArgs) != 0 {
+		t.Fatal("Expected the state of the builder to be reset for the new stage")
+	}
+
+	if id, err := b.stageImage("Build"); err != nil || id != "abc" {
+		t.Fatalf("Expected the image of stage build, got %q (%v)", id, err)
+	}
+	if id, err := b.stageImage("0"); err != nil || id != "abc" {
+		t.Fatalf("Expected the image of stage 0, got %q (%v)", id, err)
+	}
+
+	if err := b.startStage("build"); err == nil {
+		t.Fatal("Expected an error for a duplicate stage name")
+	}
+}
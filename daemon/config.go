@@ -28,8 +28,10 @@ type CommonConfig struct {
 	ExecRoot       string
 	GraphDriver    string
 	Labels         []string
+	LiveRestore    bool
 	LogConfig      runconfig.LogConfig
 	Mtu            int
+	OrphansDryRun  bool
 	Pidfile        string
 	Root           string
 	TrustKeyPath   string
@@ -69,6 +71,9 @@ func (config *Config) InstallCommonFlags() {
 	flag.StringVar(&config.LogConfig.Type, []string{"-log-driver"}, "json-file", "Default driver for container logs")
 	opts.LogOptsVar(config.LogConfig.Config, []string{"-log-opt"}, "Set log driver options")
 	flag.BoolVar(&config.Bridge.EnableUserlandProxy, []string{"-userland-proxy"}, true, "Use userland proxy for loopback traffic")
+	flag.BoolVar(&config.LiveRestore, []string{"-live-restore"}, false, "Keep containers running when the daemon exits")
+	flag.BoolVar(&config.OrphansDryRun, []string{"-orphans-dry-run"}, false, "Only list what a crash left behind on start, do not remove it")
+	flag.StringVar(&config.Bridge.PortForwarder, []string{"-port-forwarder"}, bridge.DefaultPortForwarder, "Port forwarding backend (iptables, pf or userland)")
 
 }
 
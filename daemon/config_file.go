@@ -0,0 +1,319 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/opts"
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/runconfig"
+)
+
+// The configuration file of the daemon is a JSON object whose keys are the
+// long names of the flags of the daemon, without dashes. A value is a
+// string, a number or a boolean, a list of them for the flags which can be
+// repeated, or an object for the key=value flags such as log-opt:
+//
+//	{
+//		"label": ["env=prod"],
+//		"log-driver": "syslog",
+//		"storage-driver": "zfs"
+//	}
+
+// ReadConfigFile reads the configuration file path.
+func ReadConfigFile(path string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var options map[string]interface{}
+	if err := json.Unmarshal(data, &options); err != nil {
+		return nil, fmt.Errorf("Unable to parse the configuration file %s: %v", path, err)
+	}
+	return options, nil
+}
+
+// optionValues returns the values of the option name of the configuration
+// file, as they are given to its flag on the command line.
+func optionValues(name string, value interface{}) ([]string, error) {
+	scalar := func(v interface{}) (string, bool) {
+		switch v := v.(type) {
+		case string:
+			return v, true
+		case bool:
+			return strconv.FormatBool(v), true
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), true
+		}
+		return "", false
+	}
+
+	if s, ok := scalar(value); ok {
+		return []string{s}, nil
+	}
+	var values []string
+	switch value := value.(type) {
+	case []interface{}:
+		for _, v := range value {
+			s, ok := scalar(v)
+			if !ok {
+				return nil, fmt.Errorf("Invalid value %v of %s in the configuration file", v, name)
+			}
+			values = append(values, s)
+		}
+	case map[string]interface{}:
+		var keys []string
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s, ok := scalar(value[k])
+			if !ok {
+				return nil, fmt.Errorf("Invalid value %v of %s in the configuration file", value[k], name)
+			}
+			values = append(values, k+"="+s)
+		}
+	default:
+		return nil, fmt.Errorf("Invalid value %v of %s in the configuration file", value, name)
+	}
+	return values, nil
+}
+
+// isSet returns whether the flag f was set on the command line of cmd,
+// under any of its names.
+func isSet(cmd *flag.FlagSet, f *flag.Flag) bool {
+	for _, name := range f.Names {
+		if cmd.IsSet(strings.TrimPrefix(name, "#")) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkConfigFile returns an error if an option of the configuration file
+// is not a flag of cmd, or is set on the command line too.
+func checkConfigFile(cmd *flag.FlagSet, options map[string]interface{}) error {
+	var conflicts []string
+	for name := range options {
+		f := cmd.Lookup("-" + name)
+		if f == nil || name == "config-file" {
+			return fmt.Errorf("Unknown option %s in the configuration file", name)
+		}
+		if isSet(cmd, f) {
+			conflicts = append(conflicts, name)
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("The following options are set both as flags and in the configuration file: %s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// setOptions sets the flags of cmd known to have the options of the
+// configuration file. They are not marked as set, so that the flags set on
+// the command line can still be told apart.
+func setOptions(cmd *flag.FlagSet, options map[string]interface{}) error {
+	var names []string
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := cmd.Lookup("-" + name)
+		if f == nil {
+			continue
+		}
+		values, err := optionValues(name, options[name])
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			if err := f.Value.Set(v); err != nil {
+				return fmt.Errorf("Invalid value %s of %s in the configuration file: %v", v, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// MergeConfigFile sets the flags of cmd, parsed from the command line, to
+// the options of the configuration file. An option can not be set in both.
+func MergeConfigFile(cmd *flag.FlagSet, options map[string]interface{}) error {
+	if err := checkConfigFile(cmd, options); err != nil {
+		return err
+	}
+	return setOptions(cmd, options)
+}
+
+// ReloadConfig is the part of the configuration of the daemon which can
+// change while it runs.
+type ReloadConfig struct {
+	Labels             []string
+	LogLevel           string
+	LogConfig          runconfig.LogConfig
+	Mirrors            []string
+	InsecureRegistries []string
+}
+
+// reloadableFlags are the names of the flags of the ReloadConfig.
+var reloadableFlags = []string{"label", "log-level", "log-driver", "log-opt", "registry-mirror", "insecure-registry"}
+
+// ParseReloadConfig returns the ReloadConfig of the options of the
+// configuration file. The options set on the command line of cmd keep
+// their current value, the ones missing from the file get their default.
+func ParseReloadConfig(cmd *flag.FlagSet, options map[string]interface{}, current *ReloadConfig) (*ReloadConfig, error) {
+	if err := checkConfigFile(cmd, options); err != nil {
+		return nil, err
+	}
+
+	var (
+		labels     = opts.NewListOpts(opts.ValidateLabel)
+		mirrors    = opts.NewListOpts(registry.ValidateMirror)
+		insecure   = opts.NewListOpts(registry.ValidateIndexName)
+		config     = &ReloadConfig{LogConfig: runconfig.LogConfig{Config: make(map[string]string)}}
+		reloadable = flag.NewFlagSet("config-file", flag.ContinueOnError)
+	)
+	reloadable.Var(&labels, []string{"-label"}, "")
+	reloadable.StringVar(&config.LogLevel, []string{"-log-level"}, "info", "")
+	reloadable.StringVar(&config.LogConfig.Type, []string{"-log-driver"}, "json-file", "")
+	reloadable.Var(opts.NewMapOpts(config.LogConfig.Config, opts.ValidateLogOpts), []string{"-log-opt"}, "")
+	reloadable.Var(&mirrors, []string{"-registry-mirror"}, "")
+	reloadable.Var(&insecure, []string{"-insecure-registry"}, "")
+	if err := setOptions(reloadable, options); err != nil {
+		return nil, err
+	}
+	config.Labels = labels.GetAll()
+	config.Mirrors = mirrors.GetAll()
+	config.InsecureRegistries = insecure.GetAll()
+
+	if _, err := logrus.ParseLevel(config.LogLevel); err != nil {
+		return nil, fmt.Errorf("Invalid log-level in the configuration file: %v", err)
+	}
+
+	set := func(name string) bool {
+		f := cmd.Lookup("-" + name)
+		return f != nil && isSet(cmd, f)
+	}
+	if set("label") {
+		config.Labels = current.Labels
+	}
+	// The debug mode sets the log level
+	if set("log-level") || set("debug") {
+		config.LogLevel = current.LogLevel
+	}
+	if set("log-driver") {
+		config.LogConfig.Type = current.LogConfig.Type
+	}
+	if set("log-opt") {
+		config.LogConfig.Config = current.LogConfig.Config
+	}
+	if set("registry-mirror") {
+		config.Mirrors = current.Mirrors
+	}
+	if set("insecure-registry") {
+		config.InsecureRegistries = current.InsecureRegistries
+	}
+
+	// As when the daemon starts
+	if config.LogConfig.Type != "none" {
+		if _, err := logger.GetLogDriver(config.LogConfig.Type); err != nil {
+			return nil, fmt.Errorf("Invalid log-driver in the configuration file: %v", err)
+		}
+	}
+	if err := logger.ValidateLogOpts(config.LogConfig.Type, config.LogConfig.Config); err != nil {
+		return nil, fmt.Errorf("Invalid log-opt in the configuration file: %v", err)
+	}
+	return config, nil
+}
+
+// IgnoredOptions returns the options of the configuration file which
+// changed from before to after but can not be reloaded.
+func IgnoredOptions(before, after map[string]interface{}) []string {
+	names := make(map[string]bool)
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+	for _, name := range reloadableFlags {
+		delete(names, name)
+	}
+
+	var ignored []string
+	for name := range names {
+		if !reflect.DeepEqual(before[name], after[name]) {
+			ignored = append(ignored, name)
+		}
+	}
+	sort.Strings(ignored)
+	return ignored
+}
+
+// Changes returns what changed from c to config, as "name: old -> new".
+func (c *ReloadConfig) Changes(config *ReloadConfig) []string {
+	var changes []string
+	add := func(name string, old, new interface{}) {
+		if !reflect.DeepEqual(old, new) {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", name, old, new))
+		}
+	}
+	add("labels", c.Labels, config.Labels)
+	add("log level", c.LogLevel, config.LogLevel)
+	add("log driver", c.LogConfig.Type, config.LogConfig.Type)
+	add("log options", c.LogConfig.Config, config.LogConfig.Config)
+	add("registry mirrors", c.Mirrors, config.Mirrors)
+	add("insecure registries", c.InsecureRegistries, config.InsecureRegistries)
+	return changes
+}
+
+// Reload applies config to the daemon. The log level is left to the
+// caller, it is not specific to the daemon.
+func (daemon *Daemon) Reload(config *ReloadConfig) {
+	daemon.reloadLock.Lock()
+	daemon.config.Labels = config.Labels
+	daemon.config.LogConfig = config.LogConfig
+	daemon.defaultLogConfig = config.LogConfig
+	daemon.reloadLock.Unlock()
+
+	mirrors := opts.NewListOpts(nil)
+	for _, m := range config.Mirrors {
+		mirrors.Set(m)
+	}
+	insecure := opts.NewListOpts(nil)
+	for _, r := range config.InsecureRegistries {
+		insecure.Set(r)
+	}
+	daemon.RegistryService.SetConfig(registry.NewServiceConfig(&registry.Options{
+		Mirrors:            mirrors,
+		InsecureRegistries: insecure,
+	}))
+
+	daemon.EventsService.Log("reload", types.DaemonEventType, types.EventActor{ID: daemon.ID})
+}
+
+// labels returns the labels of the daemon, which Reload may change.
+func (daemon *Daemon) labels() []string {
+	daemon.reloadLock.Lock()
+	defer daemon.reloadLock.Unlock()
+	return daemon.config.Labels
+}
+
+// getDefaultLogConfig returns the log configuration of the containers
+// created without one, which Reload may change.
+func (daemon *Daemon) getDefaultLogConfig() runconfig.LogConfig {
+	daemon.reloadLock.Lock()
+	defer daemon.reloadLock.Unlock()
+	return daemon.defaultLogConfig
+}
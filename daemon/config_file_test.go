@@ -0,0 +1,220 @@
+package daemon
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/opts"
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/runconfig"
+)
+
+// testFlags are flags of the daemon parsed from a command line.
+type testFlags struct {
+	cmd           *flag.FlagSet
+	labels        opts.ListOpts
+	mirrors       opts.ListOpts
+	logDriver     string
+	logLevel      string
+	liveRestore   bool
+	storageDriver string
+}
+
+func newTestFlags(t *testing.T, args ...string) *testFlags {
+	f := &testFlags{
+		cmd:     flag.NewFlagSet("docker", flag.ContinueOnError),
+		labels:  opts.NewListOpts(opts.ValidateLabel),
+		mirrors: opts.NewListOpts(nil),
+	}
+	f.cmd.Var(&f.labels, []string{"-label"}, "")
+	f.cmd.Var(&f.mirrors, []string{"-registry-mirror"}, "")
+	f.cmd.StringVar(&f.logDriver, []string{"-log-driver"}, "json-file", "")
+	f.cmd.StringVar(&f.logLevel, []string{"l", "-log-level"}, "info", "")
+	f.cmd.BoolVar(&f.liveRestore, []string{"-live-restore"}, false, "")
+	f.cmd.StringVar(&f.storageDriver, []string{"s", "-storage-driver"}, "", "")
+	if err := f.cmd.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestOptionValues(t *testing.T) {
+	for _, test := range []struct {
+		value    interface{}
+		expected []string
+	}{
+		{"zfs", []string{"zfs"}},
+		{true, []string{"true"}},
+		{float64(1500), []string{"1500"}},
+		{[]interface{}{"a=b", "c=d"}, []string{"a=b", "c=d"}},
+		{map[string]interface{}{"max-size": "10m", "max-file": float64(3)}, []string{"max-file=3", "max-size=10m"}},
+	} {
+		values, err := optionValues("opt", test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(values, test.expected) {
+			t.Fatalf("Expected %v for %v, got %v", test.expected, test.value, values)
+		}
+	}
+
+	for _, value := range []interface{}{nil, []interface{}{[]interface{}{"a"}}, map[string]interface{}{"a": nil}} {
+		if _, err := optionValues("opt", value); err == nil {
+			t.Fatalf("Expected an error for %v", value)
+		}
+	}
+}
+
+func TestMergeConfigFile(t *testing.T) {
+	f := newTestFlags(t, "--label=env=prod")
+	options := map[string]interface{}{
+		"log-driver":   "syslog",
+		"live-restore": true,
+	}
+	if err := MergeConfigFile(f.cmd, options); err != nil {
+		t.Fatal(err)
+	}
+	if f.logDriver != "syslog" || !f.liveRestore {
+		t.Fatalf("Expected the options of the file, got %s and %v", f.logDriver, f.liveRestore)
+	}
+	if f.cmd.IsSet("-log-driver") {
+		t.Fatal("Expected the options of the file not to be set on the command line")
+	}
+}
+
+func TestMergeConfigFileConflicts(t *testing.T) {
+	f := newTestFlags(t, "--label=env=prod", "-l", "debug", "-s", "zfs")
+	options := map[string]interface{}{
+		"label":          []interface{}{"env=test"},
+		"log-level":      "info",
+		"storage-driver": "zfs",
+		"log-driver":     "syslog",
+	}
+	err := MergeConfigFile(f.cmd, options)
+	if err == nil || !strings.HasSuffix(err.Error(), ": label, log-level, storage-driver") {
+		t.Fatalf("Expected the conflicting options, got %v", err)
+	}
+	if f.logDriver != "json-file" {
+		t.Fatalf("Expected no option to be set, got log driver %s", f.logDriver)
+	}
+}
+
+func TestMergeConfigFileInvalid(t *testing.T) {
+	for _, options := range []map[string]interface{}{
+		{"unknown": "x"},
+		{"config-file": "/etc/docker/daemon.json"},
+		{"label": []interface{}{"invalid"}},
+	} {
+		if err := MergeConfigFile(newTestFlags(t).cmd, options); err == nil {
+			t.Fatalf("Expected an error for %v", options)
+		}
+	}
+}
+
+func TestParseReloadConfig(t *testing.T) {
+	f := newTestFlags(t, "--log-driver=none")
+	current := &ReloadConfig{
+		Labels:    []string{"env=prod"},
+		LogLevel:  "info",
+		LogConfig: runconfig.LogConfig{Type: "none", Config: map[string]string{}},
+		Mirrors:   []string{"https://old.example.com/v1/"},
+	}
+	options := map[string]interface{}{
+		"label":           []interface{}{"env=test"},
+		"log-level":       "debug",
+		"registry-mirror": []interface{}{"https://mirror.example.com"},
+		"storage-driver":  "zfs",
+	}
+	config, err := ParseReloadConfig(f.cmd, options, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ReloadConfig{
+		Labels:    []string{"env=test"},
+		LogLevel:  "debug",
+		LogConfig: runconfig.LogConfig{Type: "none", Config: map[string]string{}},
+		Mirrors:   []string{"https://mirror.example.com/v1/"},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, config)
+	}
+
+	// The options removed from the file get their default
+	config, err = ParseReloadConfig(f.cmd, map[string]interface{}{}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Labels != nil || config.LogLevel != "info" || config.Mirrors != nil || config.LogConfig.Type != "none" {
+		t.Fatalf("Expected the defaults, got %+v", config)
+	}
+}
+
+func TestParseReloadConfigInvalid(t *testing.T) {
+	f := newTestFlags(t, "--log-driver=none")
+	current := &ReloadConfig{LogLevel: "info"}
+	for _, options := range []map[string]interface{}{
+		{"log-driver": "syslog"},
+		{"log-level": "verbose"},
+		{"registry-mirror": "ftp://mirror.example.com"},
+	} {
+		if _, err := ParseReloadConfig(f.cmd, options, current); err == nil {
+			t.Fatalf("Expected an error for %v", options)
+		}
+	}
+}
+
+func TestParseReloadConfigInvalidLogConfig(t *testing.T) {
+	f := newTestFlags(t)
+	current := &ReloadConfig{LogLevel: "info"}
+	for _, options := range []map[string]interface{}{
+		{"log-driver": "unknown"},
+		{"log-driver": "json-file", "log-opt": map[string]interface{}{"max-size": "huge"}},
+	} {
+		if _, err := ParseReloadConfig(f.cmd, options, current); err == nil {
+			t.Fatalf("Expected an error for %v", options)
+		}
+	}
+}
+
+func TestIgnoredOptions(t *testing.T) {
+	before := map[string]interface{}{
+		"label":          []interface{}{"env=prod"},
+		"storage-driver": "zfs",
+		"mtu":            float64(1500),
+	}
+	after := map[string]interface{}{
+		"label":        []interface{}{"env=test"},
+		"mtu":          float64(1500),
+		"live-restore": true,
+	}
+	expected := []string{"live-restore", "storage-driver"}
+	if ignored := IgnoredOptions(before, after); !reflect.DeepEqual(ignored, expected) {
+		t.Fatalf("Expected %v, got %v", expected, ignored)
+	}
+}
+
+func TestReloadConfigChanges(t *testing.T) {
+	before := &ReloadConfig{
+		Labels:    []string{"env=prod"},
+		LogLevel:  "info",
+		LogConfig: runconfig.LogConfig{Type: "json-file", Config: map[string]string{}},
+	}
+	after := &ReloadConfig{
+		Labels:             []string{"env=prod"},
+		LogLevel:           "debug",
+		LogConfig:          runconfig.LogConfig{Type: "syslog", Config: map[string]string{}},
+		InsecureRegistries: []string{"registry.example.com"},
+	}
+	expected := []string{
+		"log level: info -> debug",
+		"log driver: json-file -> syslog",
+		"insecure registries: [] -> [registry.example.com]",
+	}
+	if changes := before.Changes(after); !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("Expected %v, got %v", expected, changes)
+	}
+	if changes := after.Changes(after); len(changes) != 0 {
+		t.Fatalf("Expected no change, got %v", changes)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -20,6 +21,7 @@ import (
 	"github.com/docker/libcontainer/label"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/daemon/execdriver"
 	"github.com/docker/docker/daemon/logger"
 	"github.com/docker/docker/daemon/logger/jsonfilelog"
@@ -36,15 +38,21 @@ import (
 	"github.com/docker/docker/pkg/jsonlog"
 	"github.com/docker/docker/pkg/promise"
 	"github.com/docker/docker/pkg/resolvconf"
+	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/pkg/stringid"
 	"github.com/docker/docker/pkg/symlink"
 	"github.com/docker/docker/pkg/ulimit"
 	"github.com/docker/docker/runconfig"
 	"github.com/docker/docker/utils"
+	"github.com/docker/docker/volumes"
 )
 
 const DefaultPathEnv = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
 
+// DefaultStopTimeout is the number of seconds a container is given to stop
+// when neither the client nor its configuration set a timeout.
+const DefaultStopTimeout = 10
+
 var (
 	ErrNotATTY               = errors.New("The PTY is not a file")
 	ErrNoTTY                 = errors.New("No PTY found")
@@ -91,6 +99,9 @@ type Container struct {
 	MountLabel, ProcessLabel string
 	AppArmorProfile          string
 	RestartCount             int
+	LastRestartedAt          time.Time // Time of the last restart by the restart policy
+	HasBeenManuallyStopped   bool      // Whether the container was stopped by a user, for the unless-stopped policy
+	LiveRestore              bool      // Whether the container was started to keep running when the daemon exits
 	UpdateDns                bool
 
 	// Maps container paths to volume paths.  The key in this is the path to which
@@ -109,6 +120,8 @@ type Container struct {
 	logDriver          logger.Logger
 	logCopier          *logger.Copier
 	AppliedVolumesFrom map[string]struct{}
+	// pluginVolumes are the plugin volumes mounted for the container
+	pluginVolumes []*volumes.Volume
 }
 
 func (container *Container) FromDisk() error {
@@ -200,13 +213,33 @@ func (container *Container) WriteHostConfig() error {
 	return ioutil.WriteFile(pth, data, 0666)
 }
 
+// LogEvent logs the event action of the container, with its labels, name
+// and image as attributes.
 func (container *Container) LogEvent(action string) {
-	d := container.daemon
-	d.EventsService.Log(
-		action,
-		container.ID,
-		container.Config.Image,
-	)
+	container.LogEventWithAttributes(action, nil)
+}
+
+// LogEventWithAttributes logs the event action of the container like
+// LogEvent, with the extra attributes.
+func (container *Container) LogEventWithAttributes(action string, extra map[string]string) {
+	attributes := make(map[string]string)
+	for k, v := range container.Config.Labels {
+		attributes[k] = v
+	}
+	attributes["name"] = strings.TrimPrefix(container.Name, "/")
+	attributes["image"] = container.Config.Image
+	for k, v := range extra {
+		attributes[k] = v
+	}
+	container.daemon.EventsService.Log(action, types.ContainerEventType, types.EventActor{
+		ID:         container.ID,
+		Attributes: attributes,
+	})
+}
+
+// logDieEvent logs the die event of the container, with its exit code.
+func (container *Container) logDieEvent(exitCode int) {
+	container.LogEventWithAttributes("die", map[string]string{"exitCode": strconv.Itoa(exitCode)})
 }
 
 // Evaluates `path` in the scope of the container's basefs, with proper path
@@ -294,7 +327,18 @@ func populateCommand(c *Container, env []string) error {
 	case "none":
 	case "host":
 		en.HostNetworking = true
-	case "bridge", "": // empty string to support existing containers
+	case "container":
+		nc, err := c.getNetworkedContainer()
+		if err != nil {
+			return err
+		}
+		en.ContainerID = nc.ID
+	default:
+		// "bridge", the empty string to support existing containers, or
+		// the name of a user-defined network
+		if len(parts) > 1 || !(c.hostConfig.NetworkMode.IsDefault() || runconfig.ValidNetworkName(parts[0])) {
+			return fmt.Errorf("invalid network mode: %s", c.hostConfig.NetworkMode)
+		}
 		if !c.Config.NetworkDisabled {
 			network := c.NetworkSettings
 			en.Interface = &execdriver.NetworkInterface{
@@ -309,15 +353,15 @@ func populateCommand(c *Container, env []string) error {
 				IPv6Gateway:          network.IPv6Gateway,
 				HairpinMode:          network.HairpinMode,
 			}
+			for _, ep := range c.extraInterfaces() {
+				en.Interfaces = append(en.Interfaces, &execdriver.NetworkInterface{
+					Bridge:      ep.Bridge,
+					IPAddress:   ep.IPAddress,
+					IPPrefixLen: ep.IPPrefixLen,
+					MacAddress:  ep.MacAddress,
+				})
+			}
 		}
-	case "container":
-		nc, err := c.getNetworkedContainer()
-		if err != nil {
-			return err
-		}
-		en.ContainerID = nc.ID
-	default:
-		return fmt.Errorf("invalid network mode: %s", c.hostConfig.NetworkMode)
 	}
 
 	ipc := &execdriver.Ipc{}
@@ -426,6 +470,9 @@ func populateCommand(c *Container, env []string) error {
 		LxcConfig:          lxcConfig,
 		AppArmorProfile:    c.AppArmorProfile,
 		CgroupParent:       c.hostConfig.CgroupParent,
+		// The standard input and the terminal of the container are
+		// streams of the daemon, which can not outlive it
+		LiveRestore: c.daemon.config.LiveRestore && !c.Config.Tty && !c.Config.OpenStdin,
 	}
 
 	return nil
@@ -442,6 +489,7 @@ func (container *Container) Start() (err error) {
 	if container.removalInProgress || container.Dead {
 		return fmt.Errorf("Container is marked for removal and cannot be started.")
 	}
+	container.HasBeenManuallyStopped = false
 
 	// if we encounter an error during start we need to ensure that any other
 	// setup has been cleaned up properly
@@ -491,6 +539,51 @@ func (container *Container) Start() (err error) {
 	return container.waitForStart()
 }
 
+// restore re-attaches to the container left running in live restore mode
+// by the previous daemon: its resources are allocated again as they were,
+// and a monitor waits for it to exit. The container is stopped if it can
+// not be re-attached to.
+func (container *Container) restore() (err error) {
+	container.Lock()
+	defer container.Unlock()
+
+	defer func() {
+		if err != nil {
+			container.daemon.execDriver.Terminate(&execdriver.Command{ID: container.ID})
+			container.setStopped(&execdriver.ExitStatus{ExitCode: -1})
+			container.setError(err)
+			container.toDisk()
+			container.cleanup()
+		}
+	}()
+
+	if err := container.Mount(); err != nil {
+		return err
+	}
+	if err := container.RestoreNetwork(); err != nil {
+		return err
+	}
+	if err := container.prepareVolumes(); err != nil {
+		return err
+	}
+	linkedEnv, err := container.setupLinkedContainers()
+	if err != nil {
+		return err
+	}
+	env := container.createDaemonEnvironment(linkedEnv)
+	if err := populateCommand(container, env); err != nil {
+		return err
+	}
+	if err := container.setupMounts(); err != nil {
+		return err
+	}
+
+	container.monitor = newContainerMonitor(container, container.hostConfig.RestartPolicy)
+	container.monitor.restoring = true
+	go container.monitor.Start()
+	return nil
+}
+
 func (container *Container) Run() error {
 	if err := container.Start(); err != nil {
 		return err
@@ -608,9 +701,15 @@ func (container *Container) AllocateNetwork() error {
 		return nil
 	}
 
-	var err error
-
-	networkSettings, err := bridge.Allocate(container.ID, container.Config.MacAddress, "", "")
+	var (
+		networkSettings *network.Settings
+		err             error
+	)
+	if mode.IsUserDefined() {
+		networkSettings, err = container.allocateUserNetwork(mode.NetworkName(), container.Config.MacAddress, "")
+	} else {
+		networkSettings, err = bridge.Allocate(container.ID, container.Config.MacAddress, "", "")
+	}
 	if err != nil {
 		return err
 	}
@@ -621,12 +720,12 @@ func (container *Container) AllocateNetwork() error {
 
 	if container.Config.PortSpecs != nil {
 		if err = migratePortMappings(container.Config, container.hostConfig); err != nil {
-			bridge.Release(container.ID)
+			container.releaseInterfaces(networkSettings)
 			return err
 		}
 		container.Config.PortSpecs = nil
 		if err = container.WriteHostConfig(); err != nil {
-			bridge.Release(container.ID)
+			container.releaseInterfaces(networkSettings)
 			return err
 		}
 	}
@@ -663,12 +762,17 @@ func (container *Container) AllocateNetwork() error {
 	nat.SortPortMap(ports, bindings)
 	for _, port := range ports {
 		if err = container.allocatePort(port, bindings); err != nil {
-			bridge.Release(container.ID)
+			container.releaseInterfaces(networkSettings)
 			return err
 		}
 	}
 	container.WriteHostConfig()
 
+	if err = container.allocateEndpoints(networkSettings, container.NetworkSettings.Networks); err != nil {
+		container.releaseInterfaces(networkSettings)
+		return err
+	}
+
 	networkSettings.Ports = bindings
 	container.NetworkSettings = networkSettings
 
@@ -680,9 +784,18 @@ func (container *Container) ReleaseNetwork() {
 		return
 	}
 
-	bridge.Release(container.ID)
+	container.releaseInterfaces(container.NetworkSettings)
 
-	container.NetworkSettings = &network.Settings{}
+	// Remember the networks the container is connected to so it is
+	// attached to them again on the next start.
+	var networks map[string]*network.EndpointSettings
+	for name, ep := range container.NetworkSettings.Networks {
+		if networks == nil {
+			networks = make(map[string]*network.EndpointSettings)
+		}
+		networks[name] = &network.EndpointSettings{NetworkID: ep.NetworkID}
+	}
+	container.NetworkSettings = &network.Settings{Networks: networks}
 }
 
 func (container *Container) isNetworkAllocated() bool {
@@ -698,8 +811,17 @@ func (container *Container) RestoreNetwork() error {
 		return nil
 	}
 
-	// Re-allocate the interface with the same IP and MAC address.
-	if _, err := bridge.Allocate(container.ID, container.NetworkSettings.MacAddress, container.NetworkSettings.IPAddress, ""); err != nil {
+	// Re-allocate the interfaces with the same IP and MAC addresses.
+	restored := &network.Settings{}
+	if mode.IsUserDefined() {
+		var err error
+		if restored, err = container.allocateUserNetwork(mode.NetworkName(), container.NetworkSettings.MacAddress, container.NetworkSettings.IPAddress); err != nil {
+			return err
+		}
+	} else if _, err := bridge.Allocate(container.ID, container.NetworkSettings.MacAddress, container.NetworkSettings.IPAddress, ""); err != nil {
+		return err
+	}
+	if err := container.allocateEndpoints(restored, container.NetworkSettings.Networks); err != nil {
 		return err
 	}
 
@@ -716,6 +838,7 @@ func (container *Container) RestoreNetwork() error {
 // around how containers are linked together.  It also unmounts the container's root filesystem.
 func (container *Container) cleanup() {
 	container.ReleaseNetwork()
+	container.releaseVolumes()
 
 	// Disable all active links
 	if container.activeLinks != nil {
@@ -761,6 +884,19 @@ func (container *Container) KillSig(sig int) error {
 	return container.daemon.Kill(container, sig)
 }
 
+// setManuallyStopped records that a user stopped the container, for the
+// daemon not to start it again if its restart policy is unless-stopped. It
+// is saved right away, as the container may already have been saved when
+// it exited.
+func (container *Container) setManuallyStopped() {
+	container.Lock()
+	defer container.Unlock()
+	container.HasBeenManuallyStopped = true
+	if err := container.toDisk(); err != nil {
+		logrus.Errorf("Error saving container %s: %s", container.ID, err)
+	}
+}
+
 // Wrapper aroung KillSig() suppressing "no such process" error.
 func (container *Container) killPossiblyDeadProcess(sig int) error {
 	err := container.KillSig(sig)
@@ -813,6 +949,43 @@ func (container *Container) Unpause() error {
 	return nil
 }
 
+// Update applies config to the host config of the container, with the lock
+// of the container held so that concurrent updates are not lost. The
+// resources and the restart policy of a running container are updated in
+// place.
+func (container *Container) Update(config *runconfig.UpdateConfig) ([]string, error) {
+	container.Lock()
+	defer container.Unlock()
+
+	hostConfig := config.Apply(container.hostConfig)
+	warnings, err := container.daemon.verifyHostConfig(hostConfig)
+	if err != nil {
+		return warnings, err
+	}
+
+	if container.Running && container.command != nil {
+		old := container.command.Resources
+		resources := *old
+		resources.Memory = hostConfig.Memory
+		resources.MemorySwap = hostConfig.MemorySwap
+		resources.CpuShares = hostConfig.CpuShares
+		resources.CpuQuota = hostConfig.CpuQuota
+		resources.CpusetCpus = hostConfig.CpusetCpus
+		resources.BlkioWeight = hostConfig.BlkioWeight
+		container.command.Resources = &resources
+		if err := container.daemon.execDriver.Update(container.command); err != nil {
+			container.command.Resources = old
+			return warnings, err
+		}
+	}
+	if container.monitor != nil {
+		container.monitor.setRestartPolicy(hostConfig.RestartPolicy)
+	}
+
+	container.hostConfig = hostConfig
+	return warnings, container.WriteHostConfig()
+}
+
 func (container *Container) Kill() error {
 	if !container.IsRunning() {
 		return nil
@@ -841,14 +1014,37 @@ func (container *Container) Kill() error {
 	return nil
 }
 
+// StopSignal returns the signal which stops the container: the one set with
+// --stop-signal or STOPSIGNAL, or else SIGTERM.
+func (container *Container) StopSignal() int {
+	if container.Config != nil && container.Config.StopSignal != "" {
+		if sig, err := signal.ParseSignal(container.Config.StopSignal); err == nil {
+			return int(sig)
+		}
+		logrus.Warnf("Invalid stop signal %q for container %s, using SIGTERM", container.Config.StopSignal, container.ID)
+	}
+	return int(syscall.SIGTERM)
+}
+
+// StopTimeout returns the number of seconds Stop waits for the container to
+// exit before killing it: the one set with --stop-timeout, or else
+// DefaultStopTimeout.
+func (container *Container) StopTimeout() int {
+	if container.Config != nil && container.Config.StopTimeout != nil {
+		return *container.Config.StopTimeout
+	}
+	return DefaultStopTimeout
+}
+
 func (container *Container) Stop(seconds int) error {
 	if !container.IsRunning() {
 		return nil
 	}
 
-	// 1. Send a SIGTERM
-	if err := container.killPossiblyDeadProcess(15); err != nil {
-		logrus.Infof("Failed to send SIGTERM to the process, force killing")
+	// 1. Send the stop signal
+	stopSignal := container.StopSignal()
+	if err := container.killPossiblyDeadProcess(stopSignal); err != nil {
+		logrus.Infof("Failed to send signal %d to the process, force killing", stopSignal)
 		if err := container.killPossiblyDeadProcess(9); err != nil {
 			return err
 		}
@@ -856,7 +1052,7 @@ func (container *Container) Stop(seconds int) error {
 
 	// 2. Wait for the process to exit on its own
 	if _, err := container.WaitStop(time.Duration(seconds) * time.Second); err != nil {
-		logrus.Infof("Container %v failed to exit within %d seconds of SIGTERM - using the force", container.ID, seconds)
+		logrus.Infof("Container %v failed to exit within %d seconds of signal %d - using the force", container.ID, seconds, stopSignal)
 		// 3. If it doesn't, then send SIGKILL
 		if err := container.Kill(); err != nil {
 			container.WaitStop(-1 * time.Second)
@@ -1127,7 +1323,26 @@ func (container *Container) setupContainerDns() error {
 		return err
 	}
 
-	if config.NetworkMode.IsBridge() || config.NetworkMode.IsNone() {
+	if config.NetworkMode.IsUserDefined() {
+		// Containers on user-defined networks resolve names through the
+		// DNS server the daemon runs on the network's gateway.
+		n, err := daemon.networks.Get(config.NetworkMode.NetworkName())
+		if err != nil {
+			return err
+		}
+		if daemon.hasResolver(n) {
+			dnsSearch := resolvconf.GetSearchDomains(resolvConf)
+			if len(config.DnsSearch) > 0 {
+				dnsSearch = config.DnsSearch
+			} else if len(daemon.config.DnsSearch) > 0 {
+				dnsSearch = daemon.config.DnsSearch
+			}
+			return resolvconf.Build(container.ResolvConfPath, []string{n.Gateway}, dnsSearch)
+		}
+		logrus.Warnf("No DNS server on network %s, container %s uses the nameservers of the host", n.Name, container.ID)
+	}
+
+	if config.NetworkMode.IsBridge() || config.NetworkMode.IsNone() || config.NetworkMode.IsUserDefined() {
 		// check configurations for any container/daemon dns settings
 		if len(config.Dns) > 0 || len(daemon.config.Dns) > 0 || len(config.DnsSearch) > 0 || len(daemon.config.DnsSearch) > 0 {
 			var (
@@ -1442,7 +1657,7 @@ func (container *Container) getLogConfig() runconfig.LogConfig {
 		return cfg
 	}
 	// Use daemon's default log config for containers
-	return container.daemon.defaultLogConfig
+	return container.daemon.getDefaultLogConfig()
 }
 
 func (container *Container) getLogger() (logger.Logger, error) {
@@ -1452,9 +1667,12 @@ func (container *Container) getLogger() (logger.Logger, error) {
 		return nil, fmt.Errorf("Failed to get logging factory: %v", err)
 	}
 	ctx := logger.Context{
-		Config:        cfg.Config,
-		ContainerID:   container.ID,
-		ContainerName: container.Name,
+		Config:             cfg.Config,
+		ContainerID:        container.ID,
+		ContainerName:      container.Name,
+		ContainerImageID:   container.ImageID,
+		ContainerImageName: container.Config.Image,
+		ContainerLabels:    container.Config.Labels,
 	}
 
 	// Set logging file for "json-logger"
@@ -1584,7 +1802,7 @@ func (c *Container) LogDriverType() string {
 	c.Lock()
 	defer c.Unlock()
 	if c.hostConfig.LogConfig.Type == "" {
-		return c.daemon.defaultLogConfig.Type
+		return c.daemon.getDefaultLogConfig().Type
 	}
 	return c.hostConfig.LogConfig.Type
 }
@@ -1600,6 +1818,9 @@ func (container *Container) Exec(execConfig *execConfig) error {
 	waitStart := make(chan struct{})
 
 	callback := func(processConfig *execdriver.ProcessConfig, pid int) {
+		execConfig.Lock()
+		execConfig.pid = pid
+		execConfig.Unlock()
 		if processConfig.Tty {
 			// The callback is called after the process Start()
 			// so we are in the parent process. In TTY mode, stdin/out/err is the PtySlave
@@ -1665,13 +1886,20 @@ func (c *Container) Attach(stdin io.ReadCloser, stdout io.Writer, stderr io.Writ
 func (c *Container) AttachWithLogs(stdin io.ReadCloser, stdout, stderr io.Writer, logs, stream bool) error {
 	if logs {
 		logDriver, err := c.getLogger()
-		cLog, err := logDriver.GetReader()
+		var cLog io.Reader
+		if err == nil {
+			defer logDriver.Close()
+			cLog, err = logDriver.GetReader()
+		}
 
 		if err != nil {
 			logrus.Errorf("Error reading logs: %s", err)
 		} else if c.LogDriverType() != jsonfilelog.Name {
 			logrus.Errorf("Reading logs not implemented for driver %s", c.LogDriverType())
 		} else {
+			if closer, ok := cLog.(io.Closer); ok {
+				defer closer.Close()
+			}
 			dec := json.NewDecoder(cLog)
 			for {
 				l := &jsonlog.JSONLog{}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"path/filepath"
 
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/graphdriver"
 	"github.com/docker/docker/graph"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/parsers"
@@ -43,7 +45,7 @@ func (daemon *Daemon) ContainerCreate(name string, config *runconfig.Config, hos
 }
 
 // Create creates a new container from the given configuration with a given name.
-func (daemon *Daemon) Create(config *runconfig.Config, hostConfig *runconfig.HostConfig, name string) (*Container, []string, error) {
+func (daemon *Daemon) Create(config *runconfig.Config, hostConfig *runconfig.HostConfig, name string) (_ *Container, _ []string, retErr error) {
 	var (
 		container *Container
 		warnings  []string
@@ -78,13 +80,24 @@ func (daemon *Daemon) Create(config *runconfig.Config, hostConfig *runconfig.Hos
 			return nil, nil, err
 		}
 	}
+	if err := graphdriver.ValidateStorageOpts(daemon.driver, hostConfig.StorageOpt); err != nil {
+		return nil, nil, err
+	}
 	if container, err = daemon.newContainer(name, config, imgID); err != nil {
 		return nil, nil, err
 	}
 	if err := daemon.Register(container); err != nil {
 		return nil, nil, err
 	}
-	if err := daemon.createRootfs(container); err != nil {
+	// Do not leave a container which cannot be used behind
+	defer func() {
+		if retErr != nil {
+			if err := daemon.ForceRm(container); err != nil {
+				logrus.Errorf("Unable to remove container %s after it failed to be created: %v", container.ID, err)
+			}
+		}
+	}()
+	if err := daemon.createRootfs(container, hostConfig.StorageOpt); err != nil {
 		return nil, nil, err
 	}
 	if hostConfig != nil {
@@ -18,6 +18,7 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/api"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/autogen/dockerversion"
 	"github.com/docker/docker/daemon/events"
 	"github.com/docker/docker/daemon/execdriver"
@@ -101,14 +102,17 @@ type Daemon struct {
 	idIndex          *truncindex.TruncIndex
 	sysInfo          *sysinfo.SysInfo
 	volumes          *volumes.Repository
+	networks         *networkStore
 	config           *Config
 	containerGraph   *graphdb.Database
 	driver           graphdriver.Driver
 	execDriver       execdriver.Driver
 	statsCollector   *statsCollector
+	reloadLock       sync.Mutex // protects the labels and defaultLogConfig, changed by Reload
 	defaultLogConfig runconfig.LogConfig
 	RegistryService  *registry.Service
 	EventsService    *events.Events
+	orphans          *types.OrphansReport
 }
 
 // Get looks for a container using the provided information, which could be
@@ -208,7 +212,8 @@ func (daemon *Daemon) register(container *Container, updateSuffixarray bool) err
 
 	container.registerVolumes()
 
-	if container.IsRunning() {
+	// A container run in live restore mode is re-attached by restore
+	if container.IsRunning() && !daemon.canRestore(container) {
 		logrus.Debugf("killing old running container %s", container.ID)
 
 		container.SetStopped(&execdriver.ExitStatus{ExitCode: 0})
@@ -319,14 +324,29 @@ func (daemon *Daemon) restore() error {
 		registeredContainers = append(registeredContainers, container)
 	}
 
+	// re-attach to the containers which kept running while the daemon was
+	// down. The ones which could not be are stopped, and restarted below
+	// according to their restart policy.
+	for _, container := range registeredContainers {
+		if container.IsRunning() && daemon.canRestore(container) {
+			logrus.Debugf("Restoring container %s", container.ID)
+
+			if err := container.restore(); err != nil {
+				logrus.Errorf("Failed to restore container %s: %s", container.ID, err)
+			}
+		}
+	}
+
 	// check the restart policy on the containers and restart any container with
-	// the restart policy of "always"
+	// the restart policy of "always", or "unless-stopped" if no user stopped it
 	if daemon.config.AutoRestart {
 		logrus.Debug("Restarting containers...")
 
 		for _, container := range registeredContainers {
-			if container.hostConfig.RestartPolicy.IsAlways() ||
-				(container.hostConfig.RestartPolicy.IsOnFailure() && container.ExitCode != 0) {
+			policy := container.hostConfig.RestartPolicy
+			if policy.IsAlways() ||
+				(policy.IsUnlessStopped() && !container.HasBeenManuallyStopped) ||
+				(policy.IsOnFailure() && container.ExitCode != 0) {
 				logrus.Debugf("Starting container %s", container.ID)
 
 				if err := container.Start(); err != nil {
@@ -592,7 +612,9 @@ func (daemon *Daemon) newContainer(name string, config *runconfig.Config, imgID
 	return container, err
 }
 
-func (daemon *Daemon) createRootfs(container *Container) error {
+// createRootfs creates the layers of the root filesystem of container.
+// storageOpt are the storage driver options of its own layer.
+func (daemon *Daemon) createRootfs(container *Container, storageOpt map[string]string) error {
 	// Step 1: create the container directory.
 	// This doubles as a barrier to avoid race conditions.
 	if err := os.Mkdir(container.root, 0700); err != nil {
@@ -612,7 +634,7 @@ func (daemon *Daemon) createRootfs(container *Container) error {
 		return err
 	}
 
-	if err := daemon.driver.Create(container.ID, initID); err != nil {
+	if err := graphdriver.CreateWithOpts(daemon.driver, container.ID, initID, storageOpt); err != nil {
 		return err
 	}
 	return nil
@@ -736,6 +758,9 @@ func NewDaemon(config *Config, registryService *registry.Service) (daemon *Daemo
 		config.Bridge.EnableIpMasq = false
 	}
 	config.DisableNetwork = config.Bridge.Iface == disableNetworkBridge
+	if err := logger.ValidateLogOpts(config.LogConfig.Type, config.LogConfig.Config); err != nil {
+		return nil, err
+	}
 
 	// Check that the system is supported and we have sufficient privileges
   if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
@@ -863,7 +888,15 @@ func NewDaemon(config *Config, registryService *registry.Service) (daemon *Daemo
 		return nil, fmt.Errorf("could not create trust store: %s", err)
 	}
 
-	eventsService := events.New()
+	journal, err := events.OpenJournal(path.Join(config.Root, "events.log"))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open the events journal: %v", err)
+	}
+	eventsService, err := events.NewWithJournal(journal)
+	if err != nil {
+		journal.Close()
+		return nil, fmt.Errorf("Unable to read the events journal: %v", err)
+	}
 	logrus.Debug("Creating repository list")
 	tagCfg := &graph.TagStoreConfig{
 		Graph:    g,
@@ -883,6 +916,11 @@ func NewDaemon(config *Config, registryService *registry.Service) (daemon *Daemo
 		}
 	}
 
+	networks, err := newNetworkStore(filepath.Join(config.Root, "networks"))
+	if err != nil {
+		return nil, err
+	}
+
 	graphdbPath := path.Join(config.Root, "linkgraph.db")
 	graph, err := graphdb.NewSqliteConn(graphdbPath)
 	if err != nil {
@@ -916,6 +954,9 @@ func NewDaemon(config *Config, registryService *registry.Service) (daemon *Daemo
 	if err != nil {
 		return nil, err
 	}
+	if _, ok := ed.(execdriver.Restorer); config.LiveRestore && !ok {
+		return nil, fmt.Errorf("The %s exec driver does not support --live-restore", ed.Name())
+	}
 
 	d.ID = trustKey.PublicKey().KeyID()
 	d.repository = daemonRepo
@@ -926,6 +967,7 @@ func NewDaemon(config *Config, registryService *registry.Service) (daemon *Daemo
 	d.idIndex = truncindex.NewTruncIndex([]string{})
 	d.sysInfo = sysInfo
 	d.volumes = volumes
+	d.networks = networks
 	d.config = config
 	d.sysInitPath = sysInitPath
 	d.execDriver = ed
@@ -934,10 +976,16 @@ func NewDaemon(config *Config, registryService *registry.Service) (daemon *Daemo
 	d.RegistryService = registryService
 	d.EventsService = eventsService
 
+	if !config.DisableNetwork {
+		d.restoreNetworks()
+	}
+
 	if err := d.restore(); err != nil {
 		return nil, err
 	}
 
+	d.orphans = d.cleanupOrphans(config.OrphansDryRun)
+
 	// set up filesystem watch on resolv.conf for network changes
 	if err := d.setupResolvconfWatcher(); err != nil {
 		return nil, err
@@ -962,14 +1010,28 @@ func (daemon *Daemon) Shutdown() error {
 		logrus.Debug("starting clean shutdown of all containers...")
 		for _, container := range daemon.List() {
 			c := container
+			if c.IsRunning() && daemon.config.LiveRestore && c.LiveRestore {
+				logrus.Debugf("leaving %s running", c.ID)
+				continue
+			}
 			if c.IsRunning() {
 				logrus.Debugf("stopping %s", c.ID)
 				group.Add(1)
 
 				go func() {
 					defer group.Done()
-					if err := c.KillSig(15); err != nil {
-						logrus.Debugf("kill 15 error for %s - %s", c.ID, err)
+					// Containers with a stop timeout are killed once it
+					// expires, the others are waited for as long as they
+					// take to exit.
+					if c.Config.StopTimeout != nil {
+						if err := c.Stop(*c.Config.StopTimeout); err != nil {
+							logrus.Errorf("Failed to stop container %s: %v", c.ID, err)
+						}
+					} else {
+						stopSignal := c.StopSignal()
+						if err := c.KillSig(stopSignal); err != nil {
+							logrus.Debugf("kill %d error for %s - %s", stopSignal, c.ID, err)
+						}
 					}
 					c.WaitStop(-1 * time.Second)
 					logrus.Debugf("container stopped %s", c.ID)
@@ -1016,6 +1078,23 @@ func (daemon *Daemon) Run(c *Container, pipes *execdriver.Pipes, startCallback e
 	return daemon.execDriver.Run(c.command, pipes, startCallback)
 }
 
+// Restore re-attaches to the process of the container c left running by
+// the previous daemon and waits for it to exit, like Run.
+func (daemon *Daemon) Restore(c *Container, pipes *execdriver.Pipes, startCallback execdriver.StartCallback) (execdriver.ExitStatus, error) {
+	restorer, ok := daemon.execDriver.(execdriver.Restorer)
+	if !ok {
+		return execdriver.ExitStatus{ExitCode: -1}, fmt.Errorf("The %s exec driver cannot restore containers", daemon.execDriver.Name())
+	}
+	return restorer.Restore(c.command, pipes, startCallback)
+}
+
+// canRestore returns whether the container c, if it is running, keeps
+// running without the daemon and can be re-attached to.
+func (daemon *Daemon) canRestore(c *Container) bool {
+	_, ok := daemon.execDriver.(execdriver.Restorer)
+	return ok && c.LiveRestore
+}
+
 func (daemon *Daemon) Kill(c *Container, sig int) error {
 	return daemon.execDriver.Kill(c.command, sig)
 }
@@ -1149,6 +1228,9 @@ func (daemon *Daemon) verifyHostConfig(hostConfig *runconfig.HostConfig) ([]stri
 	if hostConfig.LxcConf.Len() > 0 && !strings.Contains(daemon.ExecutionDriver().Name(), "lxc") {
 		return warnings, fmt.Errorf("Cannot use --lxc-conf with execdriver: %s", daemon.ExecutionDriver().Name())
 	}
+	if hostConfig.NetworkMode.IsContainer() && daemon.ExecutionDriver().Name() == "jail" {
+		return warnings, fmt.Errorf("Cannot use --net=container with execdriver: %s", daemon.ExecutionDriver().Name())
+	}
 	if hostConfig.Memory != 0 && hostConfig.Memory < 4194304 {
 		return warnings, fmt.Errorf("Minimum memory limit allowed is 4MB")
 	}
@@ -1181,6 +1263,12 @@ func (daemon *Daemon) verifyHostConfig(hostConfig *runconfig.HostConfig) ([]stri
 		hostConfig.OomKillDisable = false
 		return warnings, fmt.Errorf("Your kernel does not support oom kill disable.")
 	}
+	if err := logger.ValidateLogOpts(hostConfig.LogConfig.Type, hostConfig.LogConfig.Config); err != nil {
+		return warnings, err
+	}
+	if err := verifyRestartPolicy(hostConfig.RestartPolicy); err != nil {
+		return warnings, err
+	}
 
 	return warnings, nil
 }
@@ -51,7 +51,9 @@ func (daemon *Daemon) ContainerRm(name string, config *ContainerRmConfig) error
 		daemon.statsCollector.stopCollection(container)
 		if container.IsRunning() {
 			if config.ForceRemove {
-				if err := container.Kill(); err != nil {
+				// The stop signal is sent first, so that the container
+				// can clean up within its stop timeout if it has one.
+				if err := container.Stop(container.forceRemoveTimeout()); err != nil {
 					return fmt.Errorf("Could not kill running container, cannot remove - %v", err)
 				}
 			} else {
@@ -76,8 +78,23 @@ func (daemon *Daemon) ContainerRm(name string, config *ContainerRmConfig) error
 	return nil
 }
 
+// forceRemoveTimeout returns the number of seconds `rm -f` waits for the
+// container to exit after its stop signal: its stop timeout if it has one,
+// or else none.
+func (container *Container) forceRemoveTimeout() int {
+	if container.Config.StopTimeout != nil {
+		return *container.Config.StopTimeout
+	}
+	return 0
+}
+
 func (daemon *Daemon) DeleteVolumes(volumeIDs map[string]struct{}) {
 	for id := range volumeIDs {
+		// Named volumes are managed with `docker volume` and outlive the
+		// containers using them.
+		if v := daemon.volumes.Get(id); v != nil && v.IsNamed() {
+			continue
+		}
 		if err := daemon.volumes.Delete(id); err != nil {
 			logrus.Infof("%s", err)
 			continue
@@ -4,59 +4,129 @@ import (
 	"sync"
 	"time"
 
-	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/pkg/pubsub"
 )
 
 const eventsLimit = 64
 
-// Events is pubsub channel for *jsonmessage.JSONMessage
+// Events is pubsub channel for *types.Event
 type Events struct {
-	mu     sync.Mutex
-	events []*jsonmessage.JSONMessage
-	pub    *pubsub.Publisher
+	mu      sync.Mutex
+	events  []*types.Event
+	pub     *pubsub.Publisher
+	journal *Journal
 }
 
 // New returns new *Events instance
 func New() *Events {
 	return &Events{
-		events: make([]*jsonmessage.JSONMessage, 0, eventsLimit),
+		events: make([]*types.Event, 0, eventsLimit),
 		pub:    pubsub.NewPublisher(100*time.Millisecond, 1024),
 	}
 }
 
+// NewWithJournal returns new *Events instance which writes the events to
+// journal, so that they can be replayed after the daemon restarts. The last
+// events of the journal are stored as if they had just been logged.
+func NewWithJournal(journal *Journal) (*Events, error) {
+	past, err := journal.Read(0)
+	if err != nil {
+		return nil, err
+	}
+	e := New()
+	if len(past) > eventsLimit {
+		past = past[len(past)-eventsLimit:]
+	}
+	e.events = append(e.events, past...)
+	e.journal = journal
+	return e, nil
+}
+
 // Subscribe adds new listener to events, returns slice of 64 stored last events
 // channel in which you can expect new events in form of interface{}, so you
 // need type assertion.
-func (e *Events) Subscribe() ([]*jsonmessage.JSONMessage, chan interface{}) {
+func (e *Events) Subscribe() ([]*types.Event, chan interface{}) {
 	e.mu.Lock()
-	current := make([]*jsonmessage.JSONMessage, len(e.events))
+	current := make([]*types.Event, len(e.events))
 	copy(current, e.events)
 	l := e.pub.Subscribe()
 	e.mu.Unlock()
 	return current, l
 }
 
+// SubscribeSince adds new listener to events like Subscribe, but returns the
+// events logged since the time since, in nanoseconds. They are read from the
+// journal if there is one, only the stored last events are returned
+// otherwise.
+func (e *Events) SubscribeSince(since int64) ([]*types.Event, chan interface{}, error) {
+	e.mu.Lock()
+	l := e.pub.Subscribe()
+	if e.journal == nil {
+		var past []*types.Event
+		for _, ev := range e.events {
+			if ev.TimeNano >= since {
+				past = append(past, ev)
+			}
+		}
+		e.mu.Unlock()
+		return past, l, nil
+	}
+	// The journal is read without blocking Log, up to the events logged
+	// before the listener was added
+	end := e.journal.Offset()
+	e.mu.Unlock()
+
+	past, err := e.journal.ReadTo(since, end)
+	if err != nil {
+		e.pub.Evict(l)
+		return nil, nil, err
+	}
+	return past, l, nil
+}
+
 // Evict evicts listener from pubsub
 func (e *Events) Evict(l chan interface{}) {
 	e.pub.Evict(l)
 }
 
-// Log broadcasts event to listeners. Each listener has 100 millisecond for
-// receiving event or it will be skipped.
-func (e *Events) Log(action, id, from string) {
+// Log broadcasts the event action of actor, an object of type eventType, to
+// listeners. Each listener has 100 millisecond for receiving event or it will
+// be skipped. The events of containers and images also get the fields of
+// the events from before their type, for the existing clients.
+func (e *Events) Log(action, eventType string, actor types.EventActor) {
 	go func() {
 		e.mu.Lock()
-		jm := &jsonmessage.JSONMessage{Status: action, ID: id, From: from, Time: time.Now().UTC().Unix()}
+		now := time.Now().UTC()
+		ev := &types.Event{
+			Type:     eventType,
+			Action:   action,
+			Actor:    actor,
+			Time:     now.Unix(),
+			TimeNano: now.UnixNano(),
+		}
+		switch eventType {
+		case types.ContainerEventType:
+			ev.Status, ev.ID, ev.From = action, actor.ID, actor.Attributes["image"]
+		case types.ImageEventType:
+			ev.Status, ev.ID = action, actor.ID
+		}
+
 		if len(e.events) == cap(e.events) {
 			// discard oldest event
 			copy(e.events, e.events[1:])
-			e.events[len(e.events)-1] = jm
+			e.events[len(e.events)-1] = ev
 		} else {
-			e.events = append(e.events, jm)
+			e.events = append(e.events, ev)
+		}
+		if e.journal != nil {
+			if err := e.journal.Write(ev); err != nil {
+				logrus.Errorf("Unable to write the %s event of %s to the journal: %v", action, actor.ID, err)
+			}
 		}
 		e.mu.Unlock()
-		e.pub.Publish(jm)
+		e.pub.Publish(ev)
 	}()
 }
 
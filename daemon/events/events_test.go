@@ -5,9 +5,13 @@ import (
 	"testing"
 	"time"
 
-	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/api/types"
 )
 
+func containerActor(id, image string) types.EventActor {
+	return types.EventActor{ID: id, Attributes: map[string]string{"image": image}}
+}
+
 func TestEventsLog(t *testing.T) {
 	e := New()
 	_, l1 := e.Subscribe()
@@ -18,10 +22,10 @@ func TestEventsLog(t *testing.T) {
 	if count != 2 {
 		t.Fatalf("Must be 2 subscribers, got %d", count)
 	}
-	e.Log("test", "cont", "image")
+	e.Log("test", types.ContainerEventType, containerActor("cont", "image"))
 	select {
 	case msg := <-l1:
-		jmsg, ok := msg.(*jsonmessage.JSONMessage)
+		jmsg, ok := msg.(*types.Event)
 		if !ok {
 			t.Fatalf("Unexpected type %T", msg)
 		}
@@ -42,7 +46,7 @@ func TestEventsLog(t *testing.T) {
 	}
 	select {
 	case msg := <-l2:
-		jmsg, ok := msg.(*jsonmessage.JSONMessage)
+		jmsg, ok := msg.(*types.Event)
 		if !ok {
 			t.Fatalf("Unexpected type %T", msg)
 		}
@@ -70,7 +74,7 @@ func TestEventsLogTimeout(t *testing.T) {
 
 	c := make(chan struct{})
 	go func() {
-		e.Log("test", "cont", "image")
+		e.Log("test", types.ContainerEventType, containerActor("cont", "image"))
 		close(c)
 	}()
 
@@ -88,7 +92,7 @@ func TestLogEvents(t *testing.T) {
 		action := fmt.Sprintf("action_%d", i)
 		id := fmt.Sprintf("cont_%d", i)
 		from := fmt.Sprintf("image_%d", i)
-		e.Log(action, id, from)
+		e.Log(action, types.ContainerEventType, containerActor(id, from))
 	}
 	time.Sleep(50 * time.Millisecond)
 	current, l := e.Subscribe()
@@ -97,16 +101,16 @@ func TestLogEvents(t *testing.T) {
 		action := fmt.Sprintf("action_%d", num)
 		id := fmt.Sprintf("cont_%d", num)
 		from := fmt.Sprintf("image_%d", num)
-		e.Log(action, id, from)
+		e.Log(action, types.ContainerEventType, containerActor(id, from))
 	}
 	if len(e.events) != eventsLimit {
 		t.Fatalf("Must be %d events, got %d", eventsLimit, len(e.events))
 	}
 
-	var msgs []*jsonmessage.JSONMessage
+	var msgs []*types.Event
 	for len(msgs) < 10 {
 		m := <-l
-		jm, ok := (m).(*jsonmessage.JSONMessage)
+		jm, ok := (m).(*types.Event)
 		if !ok {
 			t.Fatalf("Unexpected type %T", m)
 		}
@@ -133,3 +137,53 @@ func TestLogEvents(t *testing.T) {
 		t.Fatalf("Last action is %s, must be action_89", lastC.Status)
 	}
 }
+
+func TestLogEventTypes(t *testing.T) {
+	e := New()
+	_, l := e.Subscribe()
+	defer e.Evict(l)
+
+	for _, test := range []struct {
+		eventType string
+		actor     types.EventActor
+		expected  types.Event
+	}{
+		{types.ContainerEventType, containerActor("cont", "busybox"), types.Event{Status: "create", ID: "cont", From: "busybox"}},
+		{types.ImageEventType, types.EventActor{ID: "busybox"}, types.Event{Status: "create", ID: "busybox"}},
+		{types.VolumeEventType, types.EventActor{ID: "data"}, types.Event{}},
+	} {
+		e.Log("create", test.eventType, test.actor)
+		select {
+		case msg := <-l:
+			ev := msg.(*types.Event)
+			if ev.Type != test.eventType || ev.Action != "create" || ev.Actor.ID != test.actor.ID {
+				t.Fatalf("Expected the create event of %s %s, got %+v", test.eventType, test.actor.ID, ev)
+			}
+			if ev.Status != test.expected.Status || ev.ID != test.expected.ID || ev.From != test.expected.From {
+				t.Fatalf("Expected the fields %+v, got %+v", test.expected, ev)
+			}
+			if ev.TimeNano/int64(time.Second) != ev.Time {
+				t.Fatalf("Expected the same time in seconds and nanoseconds, got %d and %d", ev.Time, ev.TimeNano)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for broadcasted message")
+		}
+	}
+}
+
+func TestSubscribeSince(t *testing.T) {
+	e := New()
+	e.events = append(e.events,
+		&types.Event{Action: "create", TimeNano: 100},
+		&types.Event{Action: "start", TimeNano: 200},
+		&types.Event{Action: "die", TimeNano: 300},
+	)
+	past, l, err := e.SubscribeSince(200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Evict(l)
+	if len(past) != 2 || past[0].Action != "start" || past[1].Action != "die" {
+		t.Fatalf("Expected the start and die events, got %v", past)
+	}
+}
@@ -0,0 +1,196 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+)
+
+// journalMaxSize is the size over which the file of a journal is rotated.
+const journalMaxSize = 8 << 20
+
+// Journal keeps events in a file, one JSON object per line. Once the file
+// grows over its maximum size, it is renamed with a .1 suffix, replacing the
+// previous one, and a new file is started: a journal never takes more than
+// twice its maximum size on disk. Events can be read while others are
+// written, but only one goroutine may write at a time.
+type Journal struct {
+	path    string
+	maxSize int64
+
+	mu        sync.Mutex // protects f, size and rotations
+	f         *os.File
+	size      int64
+	rotations int
+}
+
+// Offset is a position in a journal, past the events written before it.
+type Offset struct {
+	rotations int
+	size      int64
+}
+
+// OpenJournal opens the journal in the file path, creating it if needed.
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	size, err := f.Seek(0, os.SEEK_END)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// A crash may have left a partial event, which must not be merged with
+	// the next one
+	if size > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, size-1); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if last[0] != '\n' {
+			n, err := f.Write([]byte{'\n'})
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			size += int64(n)
+		}
+	}
+	return &Journal{path: path, maxSize: journalMaxSize, f: f, size: size}, nil
+}
+
+// Write appends ev to the journal.
+func (j *Journal) Write(ev *types.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.size > 0 && j.size+int64(len(data)) > j.maxSize {
+		if err := j.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := j.f.Write(data)
+	j.size += int64(n)
+	return err
+}
+
+func (j *Journal) rotate() error {
+	if err := os.Rename(j.path, j.path+".1"); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.path, os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	j.f.Close()
+	j.f, j.size = f, 0
+	j.rotations++
+	return nil
+}
+
+// Offset returns the current end of the journal.
+func (j *Journal) Offset() Offset {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return Offset{rotations: j.rotations, size: j.size}
+}
+
+// Read returns the events of the journal logged since the time since, in
+// nanoseconds, oldest first. The lines which are not events are skipped.
+func (j *Journal) Read(since int64) ([]*types.Event, error) {
+	return j.ReadTo(since, j.Offset())
+}
+
+// ReadTo returns the events of the journal logged since the time since, in
+// nanoseconds, and written before the offset end, oldest first. If the
+// journal was rotated since end was taken, only the events of the rotated
+// file are returned, and none if it was rotated twice.
+func (j *Journal) ReadTo(since int64, end Offset) ([]*types.Event, error) {
+	type part struct {
+		path  string
+		limit int64 // -1 to read the whole file
+	}
+	var parts []part
+	j.mu.Lock()
+	switch j.rotations - end.rotations {
+	case 0:
+		parts = []part{{j.path + ".1", -1}, {j.path, end.size}}
+	case 1:
+		parts = []part{{j.path + ".1", end.size}}
+	}
+	// Open the files before they can be rotated
+	files := make([]*os.File, len(parts))
+	for i, p := range parts {
+		f, err := os.Open(p.path)
+		if err != nil && !os.IsNotExist(err) {
+			j.mu.Unlock()
+			closeFiles(files)
+			return nil, err
+		}
+		files[i] = f
+	}
+	j.mu.Unlock()
+	defer closeFiles(files)
+
+	var events []*types.Event
+	for i, f := range files {
+		if f == nil {
+			continue
+		}
+		var r io.Reader = f
+		if parts[i].limit >= 0 {
+			r = io.LimitReader(f, parts[i].limit)
+		}
+		var err error
+		if events, err = readEvents(r, since, events); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		if f != nil {
+			f.Close()
+		}
+	}
+}
+
+// readEvents appends the events of r logged since the time since to events.
+func readEvents(r io.Reader, since int64, events []*types.Event) ([]*types.Event, error) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			ev := &types.Event{}
+			if json.Unmarshal(line, ev) == nil && ev.TimeNano >= since {
+				events = append(events, ev)
+			}
+		}
+		if err == io.EOF {
+			return events, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Close closes the file of the journal.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.f.Close()
+}
@@ -0,0 +1,194 @@
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+func newTestJournal(t *testing.T) (*Journal, string) {
+	dir, err := ioutil.TempDir("", "events-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := OpenJournal(filepath.Join(dir, "events.log"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return j, dir
+}
+
+func actions(events []*types.Event) []string {
+	var actions []string
+	for _, ev := range events {
+		actions = append(actions, ev.Action)
+	}
+	return actions
+}
+
+func TestJournalRead(t *testing.T) {
+	j, dir := newTestJournal(t)
+	defer os.RemoveAll(dir)
+	defer j.Close()
+
+	for i, action := range []string{"create", "start", "die"} {
+		if err := j.Write(&types.Event{Action: action, TimeNano: int64(i+1) * 100}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, err := j.Read(200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := actions(events); len(a) != 2 || a[0] != "start" || a[1] != "die" {
+		t.Fatalf("Expected the start and die events, got %v", a)
+	}
+}
+
+func TestJournalRotate(t *testing.T) {
+	j, dir := newTestJournal(t)
+	defer os.RemoveAll(dir)
+	defer j.Close()
+
+	// Room for two events per file
+	j.maxSize = 2 * int64(len(`{"Type":"","Action":"a","Actor":{"ID":""},"timeNano":1}`)+1)
+	for i, action := range []string{"a", "b", "c", "d", "e"} {
+		if err := j.Write(&types.Event{Action: action, TimeNano: int64(i + 1)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, err := j.Read(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := actions(events); len(a) != 3 || a[0] != "c" || a[2] != "e" {
+		t.Fatalf("Expected the events c to e, got %v", a)
+	}
+}
+
+func TestJournalReadTo(t *testing.T) {
+	j, dir := newTestJournal(t)
+	defer os.RemoveAll(dir)
+	defer j.Close()
+
+	// Room for two events per file
+	j.maxSize = 2 * int64(len(`{"Type":"","Action":"a","Actor":{"ID":""},"timeNano":1}`)+1)
+	write := func(actions ...string) {
+		for _, action := range actions {
+			if err := j.Write(&types.Event{Action: action, TimeNano: 1}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, test := range []struct {
+		after    []string
+		expected []string
+	}{
+		{nil, []string{"a", "b", "c"}},
+		{[]string{"d"}, []string{"a", "b", "c"}},
+		// The file of the offset was rotated, the events before it are lost
+		{[]string{"d", "e"}, []string{"c"}},
+		{[]string{"d", "e", "f", "g"}, nil},
+	} {
+		os.Remove(j.path + ".1")
+		j.f.Truncate(0)
+		j.size = 0
+
+		write("a", "b", "c")
+		end := j.Offset()
+		write(test.after...)
+		events, err := j.ReadTo(0, end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a := actions(events); !reflect.DeepEqual(a, test.expected) {
+			t.Fatalf("Expected the events %v after %v, got %v", test.expected, test.after, a)
+		}
+	}
+}
+
+func TestJournalPartialEvent(t *testing.T) {
+	j, dir := newTestJournal(t)
+	defer os.RemoveAll(dir)
+	if err := j.Write(&types.Event{Action: "create", TimeNano: 1}); err != nil {
+		t.Fatal(err)
+	}
+	// What a crash in the middle of a write leaves
+	if _, err := j.f.WriteString(`{"Action":"st`); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	j, err := OpenJournal(j.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if err := j.Write(&types.Event{Action: "die", TimeNano: 3}); err != nil {
+		t.Fatal(err)
+	}
+	events, err := j.Read(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := actions(events); len(a) != 2 || a[0] != "create" || a[1] != "die" {
+		t.Fatalf("Expected the create and die events, got %v", a)
+	}
+}
+
+func TestEventsJournal(t *testing.T) {
+	j, dir := newTestJournal(t)
+	defer os.RemoveAll(dir)
+
+	e, err := NewWithJournal(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < eventsLimit+1; i++ {
+		e.Log("create", types.VolumeEventType, types.EventActor{ID: "data"})
+	}
+	// Log does not wait for the event to be stored
+	for end := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		e.mu.Lock()
+		written, err := j.Read(0)
+		e.mu.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(written) == eventsLimit+1 {
+			break
+		}
+		if time.Now().After(end) {
+			t.Fatalf("Expected %d events in the journal, got %d", eventsLimit+1, len(written))
+		}
+	}
+	j.Close()
+
+	// As after a restart of the daemon
+	if j, err = OpenJournal(j.path); err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if e, err = NewWithJournal(j); err != nil {
+		t.Fatal(err)
+	}
+	if current, l := e.Subscribe(); len(current) != eventsLimit {
+		t.Fatalf("Expected %d stored events, got %d", eventsLimit, len(current))
+	} else {
+		e.Evict(l)
+	}
+	past, l, err := e.SubscribeSince(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Evict(l)
+	if len(past) != eventsLimit+1 {
+		t.Fatalf("Expected %d events replayed, got %d", eventsLimit+1, len(past))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"strings"
 	"sync"
 
@@ -26,6 +27,8 @@ type execConfig struct {
 	OpenStderr bool
 	OpenStdout bool
 	Container  *Container
+
+	pid int // pid of the process, once it is started
 }
 
 type execStore struct {
@@ -239,8 +242,25 @@ func (d *Daemon) Exec(c *Container, execConfig *execConfig, pipes *execdriver.Pi
 		exitStatus = 128
 	}
 
+	execConfig.Lock()
 	execConfig.ExitCode = exitStatus
 	execConfig.Running = false
+	execConfig.Unlock()
 
 	return exitStatus, err
 }
+
+// kill kills the process of the exec command, if it is running.
+func (execConfig *execConfig) kill() error {
+	execConfig.Lock()
+	pid, running := execConfig.pid, execConfig.Running
+	execConfig.Unlock()
+	if !running || pid == 0 {
+		return fmt.Errorf("Exec command %s is not running", execConfig.ID)
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
@@ -21,6 +21,7 @@ var (
 	ErrWaitTimeoutReached      = errors.New("Wait timeout reached")
 	ErrDriverAlreadyRegistered = errors.New("A driver already registered this docker init function")
 	ErrDriverNotFound          = errors.New("The requested docker init has not been found")
+	ErrUpdateNotSupported      = errors.New("Updating the resources of a running container is not supported by this exec driver")
 )
 
 type StartCallback func(*ProcessConfig, int)
@@ -65,14 +66,31 @@ type Driver interface {
 	Terminate(c *Command) error                   // kill it with fire
 	Clean(id string) error                        // clean all traces of container exec
 	Stats(id string) (*ResourceStats, error)      // Get resource stats for a running container
+	Update(c *Command) error                      // Apply the resources of c to the running container
+}
+
+// Lister is implemented by the drivers which can list the containers they
+// run, including the ones left behind by a crash.
+type Lister interface {
+	// List returns the ids of the containers which exist for the driver.
+	List() ([]string, error)
+}
+
+// Restorer is implemented by the drivers which can keep the containers
+// run with LiveRestore running when the daemon exits.
+type Restorer interface {
+	// Restore re-attaches to the container c left running by a previous
+	// daemon, blocks until it exits and returns its exit code, like Run.
+	Restore(c *Command, pipes *Pipes, startCallback StartCallback) (ExitStatus, error)
 }
 
 // Network settings of the container
 type Network struct {
-	Interface      *NetworkInterface `json:"interface"` // if interface is nil then networking is disabled
-	Mtu            int               `json:"mtu"`
-	ContainerID    string            `json:"container_id"` // id of the container to join network.
-	HostNetworking bool              `json:"host_networking"`
+	Interface      *NetworkInterface   `json:"interface"`  // if interface is nil then networking is disabled
+	Interfaces     []*NetworkInterface `json:"interfaces"` // additional user-defined networks the container is connected to
+	Mtu            int                 `json:"mtu"`
+	ContainerID    string              `json:"container_id"` // id of the container to join network.
+	HostNetworking bool                `json:"host_networking"`
 }
 
 // IPC settings of the container
@@ -174,4 +192,5 @@ type Command struct {
 	LxcConfig          []string          `json:"lxc_config"`
 	AppArmorProfile    string            `json:"apparmor_profile"`
 	CgroupParent       string            `json:"cgroup_parent"` // The parent cgroup for this command.
+	LiveRestore        bool              `json:"live_restore"`  // Keep the container running when the daemon exits, see Restorer
 }
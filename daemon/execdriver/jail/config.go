@@ -0,0 +1,121 @@
+package jail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/pkg/symlink"
+)
+
+// jailConfig holds how a container is run in a jail.
+type jailConfig struct {
+	// Params are the arguments of `jail -c`, command included.
+	Params []string
+	// Fstab is the fstab(5) of the mounts of the container, to be written
+	// to the mount.fstab file, or "" if there are no mounts.
+	Fstab string
+	// Unmount are the mount points to unmount, in order, once the
+	// container exits.
+	Unmount []string
+}
+
+// newJailConfig returns the configuration of the jail of the container c,
+// with the network parameters network. The mounts of c are listed in
+// fstabPath and nullfs mounted in order before the jail is created.
+func newJailConfig(c *execdriver.Command, fstabPath string, network []string) *jailConfig {
+	root := c.Rootfs
+	config := &jailConfig{
+		Params: []string{
+			"-c",
+			"name=" + c.ID,
+			"path=" + root,
+			"mount.devfs=1",
+		},
+	}
+	config.Params = append(config.Params, network...)
+
+	var fstab []string
+	for _, m := range c.Mounts {
+		options := "ro"
+		if m.Writable {
+			options = "rw"
+		}
+		mountpoint := mountpointPath(root, m.Destination)
+		fstab = append(fstab, fmt.Sprintf("%s\t%s\tnullfs\t%s\t0\t0", fstabEscape(m.Source), fstabEscape(mountpoint), options))
+		config.Unmount = append(config.Unmount, mountpoint)
+	}
+	if len(fstab) > 0 {
+		config.Params = append(config.Params, "mount.fstab="+fstabPath)
+		config.Fstab = strings.Join(fstab, "\n") + "\n"
+	}
+
+	// jail(8) mounts devfs last, so it goes first, followed by the mounts
+	// in reverse order.
+	unmount := []string{filepath.Join(root, "dev")}
+	for i := len(config.Unmount) - 1; i >= 0; i-- {
+		unmount = append(unmount, config.Unmount[i])
+	}
+	config.Unmount = unmount
+
+	// command takes the rest of the command line and must be the last
+	// parameter.
+	config.Params = append(config.Params, "command="+c.ProcessConfig.Entrypoint)
+	config.Params = append(config.Params, c.ProcessConfig.Arguments...)
+	return config
+}
+
+// mountpointPath returns the path on the host of the destination of a
+// mount in root.
+func mountpointPath(root, destination string) string {
+	return filepath.Join(root, filepath.Clean("/"+destination))
+}
+
+// fstabEscape escapes the whitespace of a path in a fstab(5) field.
+func fstabEscape(path string) string {
+	return strings.NewReplacer(" ", `\040`, "\t", `\011`).Replace(path)
+}
+
+// createMountpoints creates the missing mount points of the mounts in
+// root: a directory for a directory and an empty file for a file.
+func createMountpoints(root string, mounts []execdriver.Mount) error {
+	for _, m := range mounts {
+		mountpoint := mountpointPath(root, m.Destination)
+		resolved, err := symlink.FollowSymlinkInScope(mountpoint, root)
+		if err != nil {
+			return err
+		}
+		// mount(8) would follow the link, out of the container maybe
+		if resolved != mountpoint {
+			return fmt.Errorf("Cannot mount %s in the container: %s is a symbolic link", m.Source, m.Destination)
+		}
+
+		fi, err := os.Stat(m.Source)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(mountpoint); err == nil {
+			continue
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		if fi.IsDir() {
+			if err := os.MkdirAll(mountpoint, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(mountpoint), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(mountpoint, os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package jail
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/daemon/execdriver"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the jail configurations")
+
+func newTestCommand(mounts []execdriver.Mount) *execdriver.Command {
+	return &execdriver.Command{
+		ID:     "1234abcd",
+		Rootfs: "/var/lib/docker/zfs/graph/1234abcd",
+		Mounts: mounts,
+		ProcessConfig: execdriver.ProcessConfig{
+			Entrypoint: "/bin/sh",
+			Arguments:  []string{"-c", "echo hello world"},
+		},
+	}
+}
+
+// render returns the text of config compared with the golden files.
+func render(config *jailConfig) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# params\n")
+	for _, p := range config.Params {
+		buf.WriteString(p + "\n")
+	}
+	buf.WriteString("# fstab\n")
+	buf.WriteString(config.Fstab)
+	buf.WriteString("# unmount\n")
+	buf.WriteString(strings.Join(config.Unmount, "\n") + "\n")
+	return buf.Bytes()
+}
+
+func TestJailConfigGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		mounts []execdriver.Mount
+	}{
+		{"no-mounts", nil},
+		{"mounts", []execdriver.Mount{
+			{Source: "/var/lib/docker/vfs/dir/5678efgh", Destination: "/data", Writable: true},
+			{Source: "/home/user/src", Destination: "/src", Writable: false},
+			{Source: "/var/lib/docker/containers/1234abcd/resolv.conf", Destination: "/etc/resolv.conf", Writable: true, Private: true},
+			{Source: "/var/lib/docker/containers/1234abcd/hostname", Destination: "/etc/hostname", Writable: true, Private: true},
+			{Source: "/var/lib/docker/containers/1234abcd/hosts", Destination: "/etc/hosts", Writable: true, Private: true},
+		}},
+		{"mounts-escaped", []execdriver.Mount{
+			{Source: "/home/user/My Documents", Destination: "/docs/../my docs", Writable: false},
+		}},
+	}
+
+	for _, test := range tests {
+		config := newJailConfig(newTestCommand(test.mounts), "/var/lib/docker/execdriver/jail/1234abcd.fstab", nil)
+		actual := render(config)
+
+		golden := filepath.Join("testdata", test.name+".golden")
+		if *update {
+			if err := ioutil.WriteFile(golden, actual, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(actual, expected) {
+			t.Errorf("%s: configuration does not match %s:\n%s\nexpected:\n%s", test.name, golden, actual, expected)
+		}
+	}
+}
+
+func TestCreateMountpoints(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "jail-mountpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	root := filepath.Join(tmp, "root")
+	src := filepath.Join(tmp, "src")
+	for _, dir := range []string{filepath.Join(root, "etc"), src} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hosts := filepath.Join(tmp, "hosts")
+	if err := ioutil.WriteFile(hosts, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mounts := []execdriver.Mount{
+		{Source: src, Destination: "/data/src"},
+		{Source: hosts, Destination: "/etc/hosts"},
+	}
+	if err := createMountpoints(root, mounts); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(filepath.Join(root, "data", "src")); err != nil || !fi.IsDir() {
+		t.Fatalf("Expected a directory mount point, got %v", err)
+	}
+	if fi, err := os.Stat(filepath.Join(root, "etc", "hosts")); err != nil || !fi.Mode().IsRegular() {
+		t.Fatalf("Expected a file mount point, got %v", err)
+	}
+
+	if err := os.Symlink("/", filepath.Join(root, "host")); err != nil {
+		t.Fatal(err)
+	}
+	if err := createMountpoints(root, []execdriver.Mount{{Source: src, Destination: "/host"}}); err == nil {
+		t.Fatal("Expected an error mounting over a symbolic link")
+	}
+}
@@ -1,29 +1,20 @@
 package jail
 
 import (
-	//"fmt"
+	"fmt"
+	"io"
 	"io/ioutil"
-	//"log"
 	"os"
 	"os/exec"
-	//"path"
-	//"runtime"
+	"path/filepath"
+	"sync"
 	"syscall"
-
-	"github.com/docker/docker/daemon/execdriver"
+	"time"
 
 	"github.com/Sirupsen/logrus"
-
-
-	"github.com/kr/pty"
-	"io"
+	"github.com/docker/docker/daemon/execdriver"
 	"github.com/docker/docker/pkg/term"
-
-	"strings"
-	"errors"
-
-	"bytes"
-	"strconv"
+	"github.com/kr/pty"
 )
 
 const DriverName = "jail"
@@ -48,6 +39,11 @@ func init() {
 type driver struct {
 	root     string
 	initPath string
+	runner   runner
+
+	sync.Mutex       // protects activeContainers and paused
+	activeContainers map[string]*execdriver.Command
+	paused           map[string]bool
 }
 
 func NewDriver(root, initPath string) (*driver, error) {
@@ -58,6 +54,10 @@ func NewDriver(root, initPath string) (*driver, error) {
 	return &driver{
 		root:     root,
 		initPath: initPath,
+		runner:   execRunner{},
+
+		activeContainers: make(map[string]*execdriver.Command),
+		paused:           make(map[string]bool),
 	}, nil
 }
 
@@ -81,12 +81,15 @@ func copyFile(src string, dest string) error {
 
 func (d *driver) Run(c *execdriver.Command, pipes *execdriver.Pipes, startCallback execdriver.StartCallback) (execdriver.ExitStatus, error) {
 	var (
-		term    execdriver.Terminal
-		err 		error
+		term execdriver.Terminal
+		err  error
 	)
 
-	// setting terminal parameters
-	if c.ProcessConfig.Tty {
+	// setting terminal parameters, the output of a container run in live
+	// restore mode goes through its fifos
+	if c.LiveRestore {
+		term = &execdriver.StdConsole{}
+	} else if c.ProcessConfig.Tty {
 		term, err = NewTtyConsole(&c.ProcessConfig, pipes)
 	} else {
 		term, err = execdriver.NewStdConsole(&c.ProcessConfig, pipes)
@@ -98,31 +101,58 @@ func (d *driver) Run(c *execdriver.Command, pipes *execdriver.Pipes, startCallba
 
 	logrus.Info("running jail")
 
-	root := c.Rootfs
+	if err := createMountpoints(c.Rootfs, c.Mounts); err != nil {
+		return execdriver.ExitStatus{ExitCode: -1}, err
+	}
 
-	// build params for the jail
-	params := []string{
-		"/usr/sbin/jail",
-		"-c",
-		"name=" + c.ID,
-		"path=" + root,
-		"mount.devfs=1",
-		"command=" + c.ProcessConfig.Entrypoint,
+	if err := d.setRctlRules(c); err != nil {
+		return execdriver.ExitStatus{ExitCode: -1}, err
 	}
 
-	params = append(params, c.ProcessConfig.Arguments...)
+	vnet, err := d.setupNetwork(c)
+	if err != nil {
+		return execdriver.ExitStatus{ExitCode: -1}, err
+	}
+	defer d.teardownNetwork(vnet)
 
-	c.ProcessConfig.Path = "/usr/sbin/jail"
-	c.ProcessConfig.Args = params
+	config := newJailConfig(c, d.fstabPath(c.ID), vnet.Params)
+	state := &liveState{Teardown: vnet.Teardown, Unmount: config.Unmount}
+	if config.Fstab != "" {
+		if err := ioutil.WriteFile(d.fstabPath(c.ID), []byte(config.Fstab), 0600); err != nil {
+			return execdriver.ExitStatus{ExitCode: -1}, err
+		}
+		defer os.Remove(d.fstabPath(c.ID))
+		state.Fstab = d.fstabPath(c.ID)
+	}
+	defer d.unmount(config.Unmount)
+
+	params := append([]string{"/usr/sbin/jail"}, config.Params...)
 
 	logrus.Debugf("jail params %s", params)
 
-	if err := c.ProcessConfig.Start(); err != nil {
-		logrus.Infof("jail failed %s", err)
-		return execdriver.ExitStatus{ExitCode: -1}, err
+	// The shim of a container run in live restore mode is the parent of
+	// jail(8), whose pid is unknown.
+	parent := 0
+	if c.LiveRestore {
+		defer os.RemoveAll(d.liveDir(c.ID))
+		output, err := d.startLive(c, params, state, pipes)
+		if err != nil {
+			logrus.Infof("jail failed %s", err)
+			return execdriver.ExitStatus{ExitCode: -1}, err
+		}
+		defer output.close()
+	} else {
+		c.ProcessConfig.Path = "/usr/sbin/jail"
+		c.ProcessConfig.Args = params
+
+		if err := c.ProcessConfig.Start(); err != nil {
+			logrus.Infof("jail failed %s", err)
+			return execdriver.ExitStatus{ExitCode: -1}, err
+		}
+		parent = c.ProcessConfig.Process.Pid
 	}
 
-	logrus.Debug("jail started");
+	logrus.Debug("jail started")
 
 	var (
 		waitErr  error
@@ -138,30 +168,54 @@ func (d *driver) Run(c *execdriver.Command, pipes *execdriver.Pipes, startCallba
 		close(waitLock)
 	}()
 
-	var pid int
+	// jail(8) forks the command into the new jail and waits for it, the
+	// container's pid is the one of the command.
+	pid, err := d.waitInitPid(c.ID, parent, waitLock)
+	if err != nil {
+		logrus.Warnf("Unable to find the process of jail %s: %s", c.ID, err)
+	}
 
 	c.ContainerPid = pid
 
+	d.Lock()
+	d.activeContainers[c.ID] = c
+	d.Unlock()
+	defer func() {
+		d.Lock()
+		delete(d.activeContainers, c.ID)
+		delete(d.paused, c.ID)
+		d.Unlock()
+	}()
+
 	if startCallback != nil {
 		logrus.Debugf("Invoking startCallback")
 		startCallback(&c.ProcessConfig, pid)
 	}
 
-
 	<-waitLock
-	exitCode := getExitCode(c)
-
-	if err := exec.Command("umount", root + "/dev").Run(); err != nil { 		
-		logrus.Debugf("umount %s failed: %s", c.ID, err);
-	}
+	exitCode := getExitCode(&c.ProcessConfig)
 
 	return execdriver.ExitStatus{ExitCode: exitCode, OOMKilled: false}, waitErr
 }
 
+// fstabPath returns the path of the mount.fstab file of the jail id.
+func (d *driver) fstabPath(id string) string {
+	return filepath.Join(d.root, id+".fstab")
+}
+
+// unmount unmounts the mount points, in order, once the jail is gone.
+func (d *driver) unmount(mountpoints []string) {
+	for _, mountpoint := range mountpoints {
+		if _, err := d.runner.Output("umount", mountpoint); err != nil {
+			logrus.Debugf("umount %s failed: %s", mountpoint, err)
+		}
+	}
+}
+
 func (d *driver) Exec(c *execdriver.Command, processConfig *execdriver.ProcessConfig, pipes *execdriver.Pipes, startCallback execdriver.StartCallback) (int, error) {
 	var (
-		term    execdriver.Terminal
-		err 		error
+		term execdriver.Terminal
+		err  error
 	)
 
 	// setting terminal parameters
@@ -180,7 +234,7 @@ func (d *driver) Exec(c *execdriver.Command, processConfig *execdriver.ProcessCo
 	// build params for the jail
 	params := []string{
 		"/usr/sbin/jexec",
-		c.ID,		
+		c.ID,
 		processConfig.Entrypoint,
 	}
 
@@ -196,7 +250,7 @@ func (d *driver) Exec(c *execdriver.Command, processConfig *execdriver.ProcessCo
 		return -1, err
 	}
 
-	logrus.Debug("jexec started");
+	logrus.Debug("jexec started")
 
 	var (
 		waitErr  error
@@ -212,9 +266,9 @@ func (d *driver) Exec(c *execdriver.Command, processConfig *execdriver.ProcessCo
 		close(waitLock)
 	}()
 
-	var pid int
-
-	c.ContainerPid = pid
+	// jexec(8) attaches itself to the jail before executing the command,
+	// so it keeps its pid.
+	pid := processConfig.Process.Pid
 
 	if startCallback != nil {
 		logrus.Debugf("Invoking startCallback")
@@ -222,85 +276,161 @@ func (d *driver) Exec(c *execdriver.Command, processConfig *execdriver.ProcessCo
 	}
 
 	<-waitLock
-	exitCode := getExitCode(c)
+	exitCode := getExitCode(processConfig)
 
 	return exitCode, waitErr
 }
 
-func getExitCode(c *execdriver.Command) int {
-	if c.ProcessConfig.ProcessState == nil {
+func getExitCode(processConfig *execdriver.ProcessConfig) int {
+	if processConfig.ProcessState == nil {
 		return -1
 	}
-	return c.ProcessConfig.ProcessState.Sys().(syscall.WaitStatus).ExitStatus()
+	return processConfig.ProcessState.Sys().(syscall.WaitStatus).ExitStatus()
 }
 
+// Kill sends sig to the first process of the jail, or SIGKILL to all of
+// its processes. The jail itself is only removed by Terminate.
 func (d *driver) Kill(c *execdriver.Command, sig int) error {
-	// FIXME: should be this replaced with killall\pkill?
-	// NOTE: a bug is possible if using kill - jail can exist without any processes so it will be always running
 	logrus.Debugf("jail kill %d %s", sig, c.ID)
 
-	if err := exec.Command("jail", "-r", c.ID).Run(); err != nil {
-		return err
+	if sig == int(syscall.SIGKILL) {
+		signaled, err := d.signalAll(c.ID, syscall.SIGKILL)
+		if err != nil {
+			return err
+		}
+		if len(signaled) == 0 {
+			return syscall.ESRCH
+		}
+		return nil
 	}
 
-	return nil
+	pid := c.ContainerPid
+	if pid == 0 {
+		var err error
+		if pid, err = d.initPid(c.ID, 0); err != nil {
+			return syscall.ESRCH
+		}
+	}
+	return d.runner.Signal(pid, syscall.Signal(sig))
 }
 
+// Pause stops every process of the jail with SIGSTOP.
 func (d *driver) Pause(c *execdriver.Command) error {
-	return errors.New("pause is not supported for jail execdriver");
+	logrus.Debugf("jail pause %s", c.ID)
+
+	d.Lock()
+	defer d.Unlock()
+	if d.paused[c.ID] {
+		return fmt.Errorf("jail %s is already paused", c.ID)
+	}
+	stopped, err := d.signalAll(c.ID, syscall.SIGSTOP)
+	if err != nil {
+		// Do not leave the jail half paused
+		for _, pid := range stopped {
+			d.runner.Signal(pid, syscall.SIGCONT)
+		}
+		return err
+	}
+	d.paused[c.ID] = true
+	return nil
 }
 
+// Unpause continues every process of the jail with SIGCONT.
 func (d *driver) Unpause(c *execdriver.Command) error {
-	return errors.New("pause is not supported for jail execdriver");
+	logrus.Debugf("jail unpause %s", c.ID)
+
+	d.Lock()
+	defer d.Unlock()
+	if !d.paused[c.ID] {
+		return fmt.Errorf("jail %s is not paused", c.ID)
+	}
+	if _, err := d.signalAll(c.ID, syscall.SIGCONT); err != nil {
+		return err
+	}
+	delete(d.paused, c.ID)
+	return nil
 }
 
+// Terminate removes the jail, killing its processes. A paused jail is
+// continued first so that its processes get the SIGTERM of jail(8).
 func (d *driver) Terminate(c *execdriver.Command) error {
-	if err := exec.Command("jail", "-r", c.ID).Run(); err != nil {
+	d.Lock()
+	if d.paused[c.ID] {
+		d.signalAll(c.ID, syscall.SIGCONT)
+		delete(d.paused, c.ID)
+	}
+	d.Unlock()
+
+	if _, err := d.runner.Output("jail", "-r", c.ID); err != nil {
 		return err
 	}
 	return nil
 }
 
 func (d *driver) GetPidsForContainer(id string) ([]int, error) {
-
-	cmd := exec.Command("ps", "-opid","-xaJ", id)  
-  var out bytes.Buffer
-  cmd.Stdout = &out
-  err := cmd.Run()
-  if err != nil {
-      return nil, err
-  }
-
-  pids := make([]int, 0)
-  for {
-    line, err := out.ReadString('\n')
-    if err!=nil {
-        break;
-    }
-
-    tokens := strings.Split(line, "\n")  
-    pid, err := strconv.Atoi(tokens[0])
-    if(pid == 0) {
-    	continue
-    }
-
-    if err!=nil {
-        continue
-    }      
-    pids = append(pids, pid)
-  }
-
-  return pids, nil
+	procs, err := d.jailProcesses(id)
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, 0, len(procs))
+	for _, p := range procs {
+		pids = append(pids, p.Pid)
+	}
+	return pids, nil
 }
 
+// Clean removes the resource limits of the container.
 func (d *driver) Clean(id string) error {
 	logrus.Debugf("jail clean %s", id)
+	d.removeRctlRules(id)
 	return nil
 }
 
-func (d *driver) Stats(id string) (*execdriver.ResourceStats, error)  {
-	logrus.Debugf("jail stats %s", id)
-	return nil, nil
+func (d *driver) Stats(id string) (*execdriver.ResourceStats, error) {
+	d.Lock()
+	c := d.activeContainers[id]
+	d.Unlock()
+	if c == nil {
+		return nil, execdriver.ErrNotRunning
+	}
+
+	now := time.Now()
+	out, err := d.runner.Output("rctl", "-u", rctlSubject(id))
+	if err != nil {
+		return nil, err
+	}
+	usage, err := parseRctlUsage(out)
+	if err != nil {
+		return nil, err
+	}
+
+	var memoryLimit int64
+	if c.Resources != nil {
+		memoryLimit = c.Resources.Memory
+	}
+	// if the container does not have any memory limit specified set the
+	// limit to the machines memory
+	if memoryLimit == 0 {
+		if memoryLimit, err = d.physicalMemory(); err != nil {
+			return nil, err
+		}
+	}
+	return usageStats(usage, memoryLimit, now), nil
+}
+
+// Update replaces the rctl(8) rules of the running jail of c with the ones
+// of its resources.
+func (d *driver) Update(c *execdriver.Command) error {
+	d.Lock()
+	active := d.activeContainers[c.ID]
+	if active != nil {
+		active.Resources = c.Resources
+	}
+	d.Unlock()
+	if active == nil {
+		return execdriver.ErrNotRunning
+	}
+	return d.setRctlRules(c)
 }
 
 type info struct {
@@ -312,16 +442,27 @@ func (d *driver) Info(id string) execdriver.Info {
 	return &info{ID: id, driver: d}
 }
 
+// IsRunning returns whether the jail exists, paused or not.
 func (info *info) IsRunning() bool {
-	logrus.Debugf("jail isrunning")
+	logrus.Debugf("jail isrunning %s", info.ID)
 
-	if err := exec.Command("jls", "-j", info.ID).Run(); err != nil {
-		return true
+	out, err := info.driver.runner.Output("jls", "-j", info.ID, "jid")
+	if err != nil {
+		return false
 	}
-
-	return false
+	_, err = parseJid(out)
+	return err == nil
 }
 
+// List returns the names of all the jails, the ones of the containers
+// among them.
+func (d *driver) List() ([]string, error) {
+	out, err := d.runner.Output("jls", "name")
+	if err != nil {
+		return nil, err
+	}
+	return parseJailNames(out), nil
+}
 
 // ===
 
@@ -394,4 +535,4 @@ func (t *TtyConsole) AttachPipes(command *exec.Cmd, pipes *execdriver.Pipes) err
 func (t *TtyConsole) Close() error {
 	t.SlavePty.Close()
 	return t.MasterPty.Close()
-}
\ No newline at end of file
+}
@@ -0,0 +1,312 @@
+package jail
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/execdriver"
+)
+
+// A container run in live restore mode keeps running when the daemon
+// exits. Its jail(8) is run by a shim in its own session, with fifos as
+// standard output and error, and the shim writes the exit code of the
+// container to a file. The directory of the container in the root of the
+// driver holds:
+//
+//	stdout, stderr  the fifos
+//	state.json      what Restore needs to clean up after the container
+//	exit            the exit code, once the container exited
+const (
+	liveStateFile = "state.json"
+	liveExitFile  = "exit"
+
+	// How often Restore checks whether the container exited.
+	exitPoll = 100 * time.Millisecond
+)
+
+// liveShim runs the jail(8) command line given as its arguments, after the
+// directory of the container, and writes its exit code. The fifos are
+// opened read-write so that the container neither blocks on open nor gets
+// SIGPIPE while no daemon reads them: its output is kept until their
+// buffer is full.
+const liveShim = `dir=$1; shift
+"$@" 1<>"$dir/stdout" 2<>"$dir/stderr" </dev/null
+status=$?
+echo $status >"$dir/exit.tmp" && mv "$dir/exit.tmp" "$dir/exit"
+exit $status`
+
+// liveState is what is left to do once a container run in live restore
+// mode exits.
+type liveState struct {
+	// ShimPid is the pid of the shim of the container.
+	ShimPid int
+	// Fstab is the path of the mount.fstab file of the jail, or "".
+	Fstab string
+	// Teardown are the commands run on the host once the jail is gone.
+	Teardown [][]string
+	// Unmount are the mount points to unmount, in order, once the jail
+	// is gone.
+	Unmount []string
+}
+
+// liveDir returns the directory of the container id run in live restore
+// mode.
+func (d *driver) liveDir(id string) string {
+	return filepath.Join(d.root, id)
+}
+
+func writeLiveState(dir string, state *liveState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, liveStateFile+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, liveStateFile))
+}
+
+func readLiveState(dir string) (*liveState, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, liveStateFile))
+	if err != nil {
+		return nil, err
+	}
+	state := &liveState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// readExitCode returns the exit code written by the shim in dir, and false
+// if the container has not exited yet.
+func readExitCode(dir string) (int, bool, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, liveExitFile))
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid exit code %q", data)
+	}
+	return code, true, nil
+}
+
+// liveOutput copies the fifos of a container run in live restore mode to
+// its pipes.
+type liveOutput struct {
+	readers []*os.File
+	// writers keep the readers from getting EOF while the container has
+	// not opened the fifos yet, or is not running anymore.
+	writers []*os.File
+	copied  sync.WaitGroup
+}
+
+// openFifo opens the fifo path for reading without blocking, and for
+// writing.
+func openFifo(path string) (r, w *os.File, err error) {
+	r, err = os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if w, err = os.OpenFile(path, os.O_WRONLY, 0); err != nil {
+		r.Close()
+		return nil, nil, err
+	}
+	if err := syscall.SetNonblock(int(r.Fd()), false); err != nil {
+		r.Close()
+		w.Close()
+		return nil, nil, err
+	}
+	return r, w, nil
+}
+
+// openLiveOutput opens the fifos in dir and copies them to the output
+// pipes of the container.
+func openLiveOutput(dir string, pipes *execdriver.Pipes) (*liveOutput, error) {
+	o := &liveOutput{}
+	for _, f := range []struct {
+		name string
+		dst  io.Writer
+	}{
+		{"stdout", pipes.Stdout},
+		{"stderr", pipes.Stderr},
+	} {
+		r, w, err := openFifo(filepath.Join(dir, f.name))
+		if err != nil {
+			o.close()
+			return nil, err
+		}
+		o.readers = append(o.readers, r)
+		o.writers = append(o.writers, w)
+
+		dst := f.dst
+		if dst == nil {
+			dst = ioutil.Discard
+		}
+		o.copied.Add(1)
+		go func() {
+			defer o.copied.Done()
+			io.Copy(dst, r)
+		}()
+	}
+	return o, nil
+}
+
+// close waits for the output of the container to be copied, once it
+// exited.
+func (o *liveOutput) close() {
+	for _, w := range o.writers {
+		w.Close()
+	}
+	o.copied.Wait()
+	for _, r := range o.readers {
+		r.Close()
+	}
+}
+
+// startLive creates the directory and the fifos of the container c, and
+// starts the shim running jail(8) with params. The state of the container
+// is written once the shim is started.
+func (d *driver) startLive(c *execdriver.Command, params []string, state *liveState, pipes *execdriver.Pipes) (*liveOutput, error) {
+	dir := d.liveDir(c.ID)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"stdout", "stderr"} {
+		if err := syscall.Mkfifo(filepath.Join(dir, name), 0600); err != nil {
+			return nil, err
+		}
+	}
+	output, err := openLiveOutput(dir, pipes)
+	if err != nil {
+		return nil, err
+	}
+
+	c.ProcessConfig.Path = "/bin/sh"
+	c.ProcessConfig.Args = append([]string{"/bin/sh", "-c", liveShim, "sh", dir}, params...)
+	c.ProcessConfig.Stdin = nil
+	c.ProcessConfig.Stdout = nil
+	c.ProcessConfig.Stderr = nil
+	if err := c.ProcessConfig.Start(); err != nil {
+		output.close()
+		return nil, err
+	}
+
+	state.ShimPid = c.ProcessConfig.Process.Pid
+	if err := writeLiveState(dir, state); err != nil {
+		// The container can not be restored, but it runs all the same
+		logrus.Errorf("Unable to save the state of jail %s: %s", c.ID, err)
+	}
+	return output, nil
+}
+
+// waitLiveExit polls dir until the shim writes the exit code of the
+// container, or exits without writing it.
+func (d *driver) waitLiveExit(dir string, shimPid int) (int, error) {
+	for {
+		code, exited, err := readExitCode(dir)
+		if err != nil {
+			return -1, err
+		}
+		if exited {
+			return code, nil
+		}
+		if err := d.runner.Signal(shimPid, 0); err == syscall.ESRCH {
+			// The shim may have written the code since the last read
+			if code, exited, err := readExitCode(dir); err != nil {
+				return -1, err
+			} else if exited {
+				return code, nil
+			}
+			return -1, fmt.Errorf("the shim of %s exited without the exit code of the container", dir)
+		}
+		time.Sleep(exitPoll)
+	}
+}
+
+// cleanupLive tears down what is left of the container id once it exited.
+func (d *driver) cleanupLive(id string, state *liveState) {
+	d.teardownNetwork(&vnetConfig{Teardown: state.Teardown})
+	if state.Fstab != "" {
+		os.Remove(state.Fstab)
+	}
+	d.unmount(state.Unmount)
+	if err := os.RemoveAll(d.liveDir(id)); err != nil {
+		logrus.Debugf("Unable to remove the directory of jail %s: %s", id, err)
+	}
+}
+
+// Restore re-attaches to the container c run in live restore mode by a
+// previous daemon and waits for it to exit, like Run. A container which
+// exited meanwhile is cleaned up and its exit code returned.
+func (d *driver) Restore(c *execdriver.Command, pipes *execdriver.Pipes, startCallback execdriver.StartCallback) (execdriver.ExitStatus, error) {
+	dir := d.liveDir(c.ID)
+	state, err := readLiveState(dir)
+	if err != nil {
+		return execdriver.ExitStatus{ExitCode: -1}, fmt.Errorf("Cannot restore jail %s: %s", c.ID, err)
+	}
+	defer d.cleanupLive(c.ID, state)
+
+	output, err := openLiveOutput(dir, pipes)
+	if err != nil {
+		return execdriver.ExitStatus{ExitCode: -1}, err
+	}
+	c.ProcessConfig.Terminal = &execdriver.StdConsole{}
+
+	var (
+		exitCode int
+		waitErr  error
+		exited   = make(chan struct{})
+	)
+	go func() {
+		exitCode, waitErr = d.waitLiveExit(dir, state.ShimPid)
+		close(exited)
+	}()
+
+	// Without a parent, the first process is the one whose parent lives
+	// outside of the jail.
+	pid, err := d.waitInitPid(c.ID, 0, exited)
+	select {
+	case <-exited:
+	default:
+		if err != nil {
+			logrus.Warnf("Unable to find the process of jail %s: %s", c.ID, err)
+		}
+		c.ContainerPid = pid
+
+		d.Lock()
+		d.activeContainers[c.ID] = c
+		d.Unlock()
+		defer func() {
+			d.Lock()
+			delete(d.activeContainers, c.ID)
+			delete(d.paused, c.ID)
+			d.Unlock()
+		}()
+
+		if startCallback != nil {
+			startCallback(&c.ProcessConfig, pid)
+		}
+	}
+
+	<-exited
+	output.close()
+	return execdriver.ExitStatus{ExitCode: exitCode}, waitErr
+}
@@ -0,0 +1,181 @@
+package jail
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/docker/docker/daemon/execdriver"
+)
+
+// newLiveDir creates the directory and the fifos of the container id in
+// the root of d.
+func newLiveDir(t *testing.T, d *driver, id string) string {
+	dir := d.liveDir(id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"stdout", "stderr"} {
+		if err := syscall.Mkfifo(filepath.Join(dir, name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func newLiveDriver(t *testing.T, outputs map[string]string) (*driver, *fakeRunner) {
+	d, r := newFakeDriver(outputs)
+	root, err := ioutil.TempDir("", "jail-live")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.root = root
+	return d, r
+}
+
+func TestLiveShim(t *testing.T) {
+	d, _ := newLiveDriver(t, nil)
+	defer os.RemoveAll(d.root)
+	dir := newLiveDir(t, d, "1234abcd")
+
+	var stdout, stderr bytes.Buffer
+	output, err := openLiveOutput(dir, &execdriver.Pipes{Stdout: &stdout, Stderr: &stderr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("/bin/sh", "-c", liveShim, "sh", dir, "/bin/sh", "-c", "echo out; echo err >&2; exit 3")
+	err = cmd.Run()
+	if status, ok := err.(*exec.ExitError); !ok || status.Sys().(syscall.WaitStatus).ExitStatus() != 3 {
+		t.Fatalf("Expected the shim to exit with 3, got %v", err)
+	}
+	output.close()
+
+	if code, exited, err := readExitCode(dir); err != nil || !exited || code != 3 {
+		t.Fatalf("Expected exit code 3, got %d, %v, %v", code, exited, err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Fatalf("Expected out and err, got %q and %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestRestore(t *testing.T) {
+	d, r := newLiveDriver(t, map[string]string{
+		jlsCmd:                     "7\n",
+		psCmd:                      psOut,
+		"ifconfig epair3a destroy": "",
+	})
+	defer os.RemoveAll(d.root)
+	dir := newLiveDir(t, d, "1234abcd")
+	state := &liveState{
+		ShimPid:  4240,
+		Teardown: [][]string{{"ifconfig", "epair3a", "destroy"}},
+		Unmount:  []string{"/rootfs/dev"},
+	}
+	if err := writeLiveState(dir, state); err != nil {
+		t.Fatal(err)
+	}
+
+	// What the container wrote while the daemon was down
+	container, err := os.OpenFile(filepath.Join(dir, "stdout"), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := container.WriteString("hello\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	started := make(chan int, 1)
+	exited := make(chan execdriver.ExitStatus)
+	c := &execdriver.Command{ID: "1234abcd"}
+	go func() {
+		status, err := d.Restore(c, &execdriver.Pipes{Stdout: &stdout}, func(_ *execdriver.ProcessConfig, pid int) {
+			started <- pid
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		exited <- status
+	}()
+
+	if pid := <-started; pid != 4242 {
+		t.Fatalf("Expected pid 4242, got %d", pid)
+	}
+	d.Lock()
+	active := d.activeContainers["1234abcd"]
+	d.Unlock()
+	if active != c {
+		t.Fatal("Expected the restored container to be active")
+	}
+
+	container.Close()
+	if err := ioutil.WriteFile(filepath.Join(dir, liveExitFile), []byte("3\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if status := <-exited; status.ExitCode != 3 {
+		t.Fatalf("Expected exit code 3, got %d", status.ExitCode)
+	}
+	if stdout.String() != "hello\n" {
+		t.Fatalf("Expected the output of the container, got %q", stdout.String())
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("Expected the directory of the container to be removed, got %v", err)
+	}
+	expected := []string{jlsCmd, psCmd, "ifconfig epair3a destroy", "umount /rootfs/dev"}
+	if !reflect.DeepEqual(r.commands, expected) {
+		t.Fatalf("Expected %v, got %v", expected, r.commands)
+	}
+	if len(d.activeContainers) != 0 {
+		t.Fatalf("Expected no active container, got %v", d.activeContainers)
+	}
+}
+
+func TestRestoreExited(t *testing.T) {
+	d, _ := newLiveDriver(t, nil)
+	defer os.RemoveAll(d.root)
+	dir := newLiveDir(t, d, "1234abcd")
+	if err := writeLiveState(dir, &liveState{ShimPid: 4240}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, liveExitFile), []byte("0\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &execdriver.Command{ID: "1234abcd"}
+	status, err := d.Restore(c, &execdriver.Pipes{}, func(*execdriver.ProcessConfig, int) {
+		t.Fatal("Expected no start callback for an exited container")
+	})
+	if err != nil || status.ExitCode != 0 {
+		t.Fatalf("Expected exit code 0, got %d, %v", status.ExitCode, err)
+	}
+}
+
+func TestRestoreShimGone(t *testing.T) {
+	d, r := newLiveDriver(t, nil)
+	defer os.RemoveAll(d.root)
+	dir := newLiveDir(t, d, "1234abcd")
+	if err := writeLiveState(dir, &liveState{ShimPid: 4240}); err != nil {
+		t.Fatal(err)
+	}
+	r.dead[4240] = true
+
+	status, err := d.Restore(&execdriver.Command{ID: "1234abcd"}, &execdriver.Pipes{}, nil)
+	if err == nil || status.ExitCode != -1 {
+		t.Fatalf("Expected an error and exit code -1, got %d, %v", status.ExitCode, err)
+	}
+}
+
+func TestRestoreWithoutState(t *testing.T) {
+	d, _ := newLiveDriver(t, nil)
+	defer os.RemoveAll(d.root)
+
+	status, err := d.Restore(&execdriver.Command{ID: "1234abcd"}, &execdriver.Pipes{}, nil)
+	if err == nil || status.ExitCode != -1 {
+		t.Fatalf("Expected an error and exit code -1, got %d, %v", status.ExitCode, err)
+	}
+}
@@ -0,0 +1,178 @@
+package jail
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/execdriver"
+)
+
+// epairRe matches the name of the end of an epair(4) interface printed by
+// `ifconfig epair create`.
+var epairRe = regexp.MustCompile(`^epair[0-9]+a$`)
+
+// vnetConfig holds how the network of a container is set up.
+type vnetConfig struct {
+	// Setup are the commands run on the host, in order, before the jail
+	// is created.
+	Setup [][]string
+	// Params are the network parameters of the jail.
+	Params []string
+	// Teardown are the commands run on the host, in order, once the jail
+	// is gone.
+	Teardown [][]string
+}
+
+// networkInterfaces returns the network interfaces of the container c,
+// the default one first, or nil if it has none.
+func networkInterfaces(c *execdriver.Command) []*execdriver.NetworkInterface {
+	if c.Network == nil || c.Network.Interface == nil {
+		return nil
+	}
+	return append([]*execdriver.NetworkInterface{c.Network.Interface}, c.Network.Interfaces...)
+}
+
+// jailEnd returns the end of the epair hostEnd which is moved into the
+// jail.
+func jailEnd(hostEnd string) string {
+	return strings.TrimSuffix(hostEnd, "a") + "b"
+}
+
+// newVnetConfig returns the network configuration of the jail of the
+// container c. epairs are the host ends of the epair(4) interfaces created
+// for the interfaces of c, in order: each one is added to the bridge of
+// its interface, while its other end is moved into the jail and
+// configured once the jail is created. A jail cannot join the vnet of
+// another jail, so sharing the network of a container is not supported.
+func newVnetConfig(c *execdriver.Command, epairs []string) (*vnetConfig, error) {
+	config := &vnetConfig{}
+	if c.Network == nil {
+		return config, nil
+	}
+	if c.Network.ContainerID != "" {
+		return nil, fmt.Errorf("The jail driver does not support sharing the network of container %s", c.Network.ContainerID)
+	}
+	if c.Network.HostNetworking {
+		config.Params = []string{"ip4=inherit", "ip6=inherit"}
+		return config, nil
+	}
+
+	ifaces := networkInterfaces(c)
+	if len(epairs) != len(ifaces) {
+		return nil, fmt.Errorf("%d epair interfaces for %d network interfaces", len(epairs), len(ifaces))
+	}
+
+	// The commands run in the jail: the loopback interface is always up,
+	// even without any other interface.
+	jexec := [][]string{{"ifconfig", "lo0", "inet", "127.0.0.1/8", "up"}}
+	var jailEnds []string
+	for i, iface := range ifaces {
+		hostEnd := epairs[i]
+		end := jailEnd(hostEnd)
+		jailEnds = append(jailEnds, end)
+
+		if c.Network.Mtu > 0 {
+			mtu := strconv.Itoa(c.Network.Mtu)
+			config.Setup = append(config.Setup,
+				[]string{"ifconfig", hostEnd, "mtu", mtu},
+				[]string{"ifconfig", end, "mtu", mtu})
+		}
+		if iface.MacAddress != "" {
+			config.Setup = append(config.Setup, []string{"ifconfig", end, "ether", iface.MacAddress})
+		}
+		config.Setup = append(config.Setup,
+			[]string{"ifconfig", iface.Bridge, "addm", hostEnd},
+			[]string{"ifconfig", hostEnd, "up"})
+		config.Teardown = append(config.Teardown, []string{"ifconfig", hostEnd, "destroy"})
+
+		jexec = append(jexec, []string{"ifconfig", end, "inet", fmt.Sprintf("%s/%d", iface.IPAddress, iface.IPPrefixLen), "up"})
+		if iface.GlobalIPv6Address != "" {
+			jexec = append(jexec, []string{"ifconfig", end, "inet6", fmt.Sprintf("%s/%d", iface.GlobalIPv6Address, iface.GlobalIPv6PrefixLen), "alias"})
+		}
+	}
+	if len(ifaces) > 0 {
+		// Only the default interface has a gateway
+		if gw := ifaces[0].Gateway; gw != "" {
+			jexec = append(jexec, []string{"route", "add", "default", gw})
+		}
+		if gw := ifaces[0].IPv6Gateway; gw != "" {
+			jexec = append(jexec, []string{"route", "-6", "add", "default", gw})
+		}
+	}
+
+	config.Params = []string{"vnet"}
+	if len(jailEnds) > 0 {
+		config.Params = append(config.Params, "vnet.interface="+strings.Join(jailEnds, ","))
+	}
+	// exec.created runs on the host once the interfaces are in the jail,
+	// but before its command starts.
+	var created []string
+	for _, args := range jexec {
+		created = append(created, strings.Join(append([]string{"/usr/sbin/jexec", c.ID}, args...), " "))
+	}
+	config.Params = append(config.Params, "exec.created="+strings.Join(created, " && "))
+	return config, nil
+}
+
+// parseEpair parses the output of `ifconfig epair create`.
+func parseEpair(out []byte) (string, error) {
+	s := strings.TrimSpace(string(out))
+	if !epairRe.MatchString(s) {
+		return "", fmt.Errorf("invalid epair interface %q", s)
+	}
+	return s, nil
+}
+
+// setupNetwork creates the epair(4) interfaces of the container c and
+// runs the setup commands of its network configuration. The interfaces
+// are destroyed if anything fails.
+func (d *driver) setupNetwork(c *execdriver.Command) (*vnetConfig, error) {
+	var epairs []string
+	destroy := func() {
+		for _, epair := range epairs {
+			if _, err := d.runner.Output("ifconfig", epair, "destroy"); err != nil {
+				logrus.Debugf("ifconfig %s destroy failed: %s", epair, err)
+			}
+		}
+	}
+
+	for range networkInterfaces(c) {
+		out, err := d.runner.Output("ifconfig", "epair", "create")
+		if err != nil {
+			destroy()
+			return nil, err
+		}
+		epair, err := parseEpair(out)
+		if err != nil {
+			destroy()
+			return nil, err
+		}
+		epairs = append(epairs, epair)
+	}
+
+	config, err := newVnetConfig(c, epairs)
+	if err != nil {
+		destroy()
+		return nil, err
+	}
+	for _, args := range config.Setup {
+		if _, err := d.runner.Output(args[0], args[1:]...); err != nil {
+			destroy()
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// teardownNetwork runs the teardown commands of the network configuration
+// once the jail is gone.
+func (d *driver) teardownNetwork(config *vnetConfig) {
+	for _, args := range config.Teardown {
+		if _, err := d.runner.Output(args[0], args[1:]...); err != nil {
+			logrus.Debugf("%s failed: %s", strings.Join(args, " "), err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package jail
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/daemon/execdriver"
+)
+
+func newTestNetwork() *execdriver.Network {
+	return &execdriver.Network{
+		Mtu: 1500,
+		Interface: &execdriver.NetworkInterface{
+			Bridge:              "docker0",
+			IPAddress:           "172.17.0.2",
+			IPPrefixLen:         16,
+			Gateway:             "172.17.42.1",
+			MacAddress:          "02:42:ac:11:00:02",
+			GlobalIPv6Address:   "2001:db8::2",
+			GlobalIPv6PrefixLen: 64,
+			IPv6Gateway:         "2001:db8::1",
+		},
+		Interfaces: []*execdriver.NetworkInterface{
+			{Bridge: "br-5678efgh", IPAddress: "172.18.0.2", IPPrefixLen: 16},
+		},
+	}
+}
+
+// renderVnet returns the text of config compared with the golden files.
+func renderVnet(config *vnetConfig) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# setup\n")
+	for _, args := range config.Setup {
+		buf.WriteString(strings.Join(args, " ") + "\n")
+	}
+	buf.WriteString("# params\n")
+	for _, p := range config.Params {
+		buf.WriteString(p + "\n")
+	}
+	buf.WriteString("# teardown\n")
+	for _, args := range config.Teardown {
+		buf.WriteString(strings.Join(args, " ") + "\n")
+	}
+	return buf.Bytes()
+}
+
+func TestVnetConfigGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		network *execdriver.Network
+		epairs  []string
+	}{
+		{"vnet", newTestNetwork(), []string{"epair3a", "epair4a"}},
+		{"vnet-none", &execdriver.Network{Mtu: 1500}, nil},
+		{"vnet-host", &execdriver.Network{Mtu: 1500, HostNetworking: true}, nil},
+	}
+
+	for _, test := range tests {
+		c := newTestCommand(nil)
+		c.Network = test.network
+		config, err := newVnetConfig(c, test.epairs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := renderVnet(config)
+
+		golden := filepath.Join("testdata", test.name+".golden")
+		if *update {
+			if err := ioutil.WriteFile(golden, actual, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(actual, expected) {
+			t.Errorf("%s: configuration does not match %s:\n%s\nexpected:\n%s", test.name, golden, actual, expected)
+		}
+	}
+}
+
+func TestVnetConfigJoinedContainer(t *testing.T) {
+	c := newTestCommand(nil)
+	c.Network = &execdriver.Network{ContainerID: "5678efgh"}
+	if _, err := newVnetConfig(c, nil); err == nil {
+		t.Fatal("Expected an error when sharing the network of a container")
+	}
+}
+
+func TestVnetConfigEpairMismatch(t *testing.T) {
+	c := newTestCommand(nil)
+	c.Network = newTestNetwork()
+	if _, err := newVnetConfig(c, []string{"epair3a"}); err == nil {
+		t.Fatal("Expected an error with fewer epairs than interfaces")
+	}
+}
+
+func TestParseEpair(t *testing.T) {
+	if epair, err := parseEpair([]byte("epair12a\n")); err != nil || epair != "epair12a" {
+		t.Fatalf("Expected epair12a, got %q, %v", epair, err)
+	}
+	for _, out := range []string{"", "epair12b\n", "bridge0\n", "epaira\n"} {
+		if _, err := parseEpair([]byte(out)); err == nil {
+			t.Fatalf("Expected an error parsing %q", out)
+		}
+	}
+}
+
+func TestSetupNetwork(t *testing.T) {
+	d, r := newFakeDriver(map[string]string{
+		"ifconfig epair create":                    "epair3a\n",
+		"ifconfig epair3a mtu 1500":                "",
+		"ifconfig epair3b mtu 1500":                "",
+		"ifconfig epair3b ether 02:42:ac:11:00:02": "",
+		"ifconfig docker0 addm epair3a":            "",
+		"ifconfig epair3a up":                      "",
+		"ifconfig epair3a destroy":                 "",
+	})
+	c := newTestCommand(nil)
+	c.Network = newTestNetwork()
+	c.Network.Interfaces = nil
+
+	config, err := d.setupNetwork(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"ifconfig epair create",
+		"ifconfig epair3a mtu 1500",
+		"ifconfig epair3b mtu 1500",
+		"ifconfig epair3b ether 02:42:ac:11:00:02",
+		"ifconfig docker0 addm epair3a",
+		"ifconfig epair3a up",
+	}
+	if !reflect.DeepEqual(r.commands, expected) {
+		t.Fatalf("Expected %v, got %v", expected, r.commands)
+	}
+
+	r.commands = nil
+	d.teardownNetwork(config)
+	if expected := []string{"ifconfig epair3a destroy"}; !reflect.DeepEqual(r.commands, expected) {
+		t.Fatalf("Expected %v, got %v", expected, r.commands)
+	}
+}
+
+func TestSetupNetworkFailure(t *testing.T) {
+	// Adding the epair to the bridge fails
+	d, r := newFakeDriver(map[string]string{
+		"ifconfig epair create":    "epair3a\n",
+		"ifconfig epair3a destroy": "",
+	})
+	c := newTestCommand(nil)
+	c.Network = &execdriver.Network{
+		Interface: &execdriver.NetworkInterface{Bridge: "docker0", IPAddress: "172.17.0.2", IPPrefixLen: 16},
+	}
+
+	if _, err := d.setupNetwork(c); err == nil {
+		t.Fatal("Expected an error setting up the network")
+	}
+	expected := []string{
+		"ifconfig epair create",
+		"ifconfig docker0 addm epair3a",
+		"ifconfig epair3a destroy",
+	}
+	if !reflect.DeepEqual(r.commands, expected) {
+		t.Fatalf("Expected %v, got %v", expected, r.commands)
+	}
+}
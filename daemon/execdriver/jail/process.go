@@ -0,0 +1,184 @@
+package jail
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// How long Run waits for the command of a new jail to show up.
+	initPidTimeout = 5 * time.Second
+	initPidPoll    = 50 * time.Millisecond
+)
+
+// runner runs the jail(8) utilities and sends signals on behalf of the
+// driver. It is replaced with canned output in the tests.
+type runner interface {
+	// Output runs the command and returns its standard output.
+	Output(name string, args ...string) ([]byte, error)
+	// Signal sends sig to the process pid.
+	Signal(pid int, sig syscall.Signal) error
+}
+
+type execRunner struct{}
+
+func (execRunner) Output(name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func (execRunner) Signal(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
+
+// jailProcess is a process running in a jail, as listed by ps -J.
+type jailProcess struct {
+	Pid  int
+	PPid int
+}
+
+// parseJid parses the output of `jls -j <name> jid`.
+func parseJid(out []byte) (int, error) {
+	s := strings.TrimSpace(string(out))
+	jid, err := strconv.Atoi(s)
+	if err != nil || jid <= 0 {
+		return 0, fmt.Errorf("invalid jail id %q", s)
+	}
+	return jid, nil
+}
+
+// parseJailNames parses the output of `jls name`.
+func parseJailNames(out []byte) []string {
+	var names []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseProcesses parses the output of `ps -o pid=,ppid= -J <jid>`.
+func parseProcesses(out []byte) ([]jailProcess, error) {
+	var procs []jailProcess
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid ps output line %q", line)
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pid in ps output line %q", line)
+		}
+		ppid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid ppid in ps output line %q", line)
+		}
+		procs = append(procs, jailProcess{Pid: pid, PPid: ppid})
+	}
+	return procs, nil
+}
+
+// initProcess returns the pid of the first process of the jail: the child
+// of the jail(8) utility which created it, parent, or else the process
+// whose parent lives outside of the jail. It returns 0 if there is none.
+func initProcess(procs []jailProcess, parent int) int {
+	inJail := make(map[int]bool, len(procs))
+	for _, p := range procs {
+		inJail[p.Pid] = true
+		if parent != 0 && p.PPid == parent {
+			return p.Pid
+		}
+	}
+	for _, p := range procs {
+		if !inJail[p.PPid] {
+			return p.Pid
+		}
+	}
+	return 0
+}
+
+// jailProcesses lists the processes running in the jail called name.
+func (d *driver) jailProcesses(name string) ([]jailProcess, error) {
+	out, err := d.runner.Output("jls", "-j", name, "jid")
+	if err != nil {
+		return nil, err
+	}
+	jid, err := parseJid(out)
+	if err != nil {
+		return nil, err
+	}
+	out, err = d.runner.Output("ps", "-a", "-x", "-o", "pid=,ppid=", "-J", strconv.Itoa(jid))
+	if err != nil {
+		return nil, err
+	}
+	return parseProcesses(out)
+}
+
+// initPid returns the pid of the first process of the jail called name,
+// started by the jail(8) utility whose pid is parent.
+func (d *driver) initPid(name string, parent int) (int, error) {
+	procs, err := d.jailProcesses(name)
+	if err != nil {
+		return 0, err
+	}
+	if pid := initProcess(procs, parent); pid != 0 {
+		return pid, nil
+	}
+	return 0, fmt.Errorf("no process found in jail %s", name)
+}
+
+// waitInitPid polls the jail called name until its first process shows
+// up. It gives up once exited is closed or after initPidTimeout.
+func (d *driver) waitInitPid(name string, parent int, exited <-chan struct{}) (int, error) {
+	deadline := time.Now().Add(initPidTimeout)
+	for {
+		pid, err := d.initPid(name, parent)
+		if err == nil {
+			return pid, nil
+		}
+		if time.Now().After(deadline) {
+			return 0, err
+		}
+		select {
+		case <-exited:
+			return 0, err
+		case <-time.After(initPidPoll):
+		}
+	}
+}
+
+// signalAll sends sig to every process of the jail called name. It returns
+// the pids signaled, even on error. Processes which exited meanwhile are
+// ignored.
+func (d *driver) signalAll(name string, sig syscall.Signal) ([]int, error) {
+	procs, err := d.jailProcesses(name)
+	if err != nil {
+		return nil, err
+	}
+	var signaled []int
+	for _, p := range procs {
+		if err := d.runner.Signal(p.Pid, sig); err != nil {
+			if err == syscall.ESRCH {
+				continue
+			}
+			return signaled, err
+		}
+		signaled = append(signaled, p.Pid)
+	}
+	return signaled, nil
+}
@@ -0,0 +1,278 @@
+package jail
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/docker/docker/daemon/execdriver"
+)
+
+type signal struct {
+	pid int
+	sig syscall.Signal
+}
+
+// fakeRunner returns canned output for the commands, keyed by the command
+// line, and records the commands run and the signals sent.
+type fakeRunner struct {
+	outputs  map[string]string
+	commands []string
+	signals  []signal
+	dead     map[int]bool
+}
+
+func (r *fakeRunner) Output(name string, args ...string) ([]byte, error) {
+	cmd := strings.Join(append([]string{name}, args...), " ")
+	r.commands = append(r.commands, cmd)
+	out, ok := r.outputs[cmd]
+	if !ok {
+		return nil, fmt.Errorf("%s: exit status 1", cmd)
+	}
+	return []byte(out), nil
+}
+
+func (r *fakeRunner) Signal(pid int, sig syscall.Signal) error {
+	if r.dead[pid] {
+		return syscall.ESRCH
+	}
+	r.signals = append(r.signals, signal{pid, sig})
+	return nil
+}
+
+const (
+	jlsCmd = "jls -j 1234abcd jid"
+	psCmd  = "ps -a -x -o pid=,ppid= -J 7"
+	psOut  = ` 4242  4240
+ 4250  4242
+ 4251  4250
+`
+)
+
+func newFakeDriver(outputs map[string]string) (*driver, *fakeRunner) {
+	r := &fakeRunner{outputs: outputs, dead: make(map[int]bool)}
+	return &driver{
+		runner:           r,
+		activeContainers: make(map[string]*execdriver.Command),
+		paused:           make(map[string]bool),
+	}, r
+}
+
+func TestParseJid(t *testing.T) {
+	if jid, err := parseJid([]byte("7\n")); err != nil || jid != 7 {
+		t.Fatalf("Expected jid 7, got %d, %v", jid, err)
+	}
+	for _, out := range []string{"", "\n", "abc\n", "0\n"} {
+		if _, err := parseJid([]byte(out)); err == nil {
+			t.Fatalf("Expected an error parsing %q", out)
+		}
+	}
+}
+
+func TestParseProcesses(t *testing.T) {
+	procs, err := parseProcesses([]byte(psOut))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []jailProcess{{4242, 4240}, {4250, 4242}, {4251, 4250}}
+	if !reflect.DeepEqual(procs, expected) {
+		t.Fatalf("Expected %v, got %v", expected, procs)
+	}
+
+	if procs, err := parseProcesses([]byte("")); err != nil || len(procs) != 0 {
+		t.Fatalf("Expected no process, got %v, %v", procs, err)
+	}
+	for _, out := range []string{"4242\n", "4242 x\n", "x 4240\n"} {
+		if _, err := parseProcesses([]byte(out)); err == nil {
+			t.Fatalf("Expected an error parsing %q", out)
+		}
+	}
+}
+
+func TestInitProcess(t *testing.T) {
+	procs := []jailProcess{{4250, 4242}, {4242, 4240}, {4251, 4250}}
+	if pid := initProcess(procs, 4240); pid != 4242 {
+		t.Fatalf("Expected the child of the jail utility, got %d", pid)
+	}
+	if pid := initProcess(procs, 0); pid != 4242 {
+		t.Fatalf("Expected the process with a parent outside the jail, got %d", pid)
+	}
+	if pid := initProcess(nil, 4240); pid != 0 {
+		t.Fatalf("Expected no process, got %d", pid)
+	}
+}
+
+func TestInitPid(t *testing.T) {
+	d, _ := newFakeDriver(map[string]string{jlsCmd: "7\n", psCmd: psOut})
+	pid, err := d.initPid("1234abcd", 4240)
+	if err != nil || pid != 4242 {
+		t.Fatalf("Expected pid 4242, got %d, %v", pid, err)
+	}
+
+	d, _ = newFakeDriver(map[string]string{jlsCmd: "7\n", psCmd: ""})
+	if _, err := d.initPid("1234abcd", 4240); err == nil {
+		t.Fatal("Expected an error for a jail without processes")
+	}
+
+	d, _ = newFakeDriver(nil)
+	if _, err := d.initPid("1234abcd", 4240); err == nil {
+		t.Fatal("Expected an error for a missing jail")
+	}
+}
+
+func TestWaitInitPidExited(t *testing.T) {
+	d, _ := newFakeDriver(nil)
+	exited := make(chan struct{})
+	close(exited)
+	if pid, err := d.waitInitPid("1234abcd", 4240, exited); err == nil {
+		t.Fatalf("Expected an error once the jail exited, got pid %d", pid)
+	}
+}
+
+func TestGetPidsForContainer(t *testing.T) {
+	d, _ := newFakeDriver(map[string]string{jlsCmd: "7\n", psCmd: psOut})
+	pids, err := d.GetPidsForContainer("1234abcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{4242, 4250, 4251}; !reflect.DeepEqual(pids, expected) {
+		t.Fatalf("Expected %v, got %v", expected, pids)
+	}
+}
+
+func TestKillSignalsInitProcess(t *testing.T) {
+	d, r := newFakeDriver(map[string]string{jlsCmd: "7\n", psCmd: psOut})
+	c := &execdriver.Command{ID: "1234abcd", ContainerPid: 4242}
+	if err := d.Kill(c, int(syscall.SIGHUP)); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []signal{{4242, syscall.SIGHUP}}; !reflect.DeepEqual(r.signals, expected) {
+		t.Fatalf("Expected %v, got %v", expected, r.signals)
+	}
+
+	// Without a known pid, the init process is looked up
+	d, r = newFakeDriver(map[string]string{jlsCmd: "7\n", psCmd: psOut})
+	c = &execdriver.Command{ID: "1234abcd"}
+	if err := d.Kill(c, int(syscall.SIGTERM)); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []signal{{4242, syscall.SIGTERM}}; !reflect.DeepEqual(r.signals, expected) {
+		t.Fatalf("Expected %v, got %v", expected, r.signals)
+	}
+}
+
+func TestKillAll(t *testing.T) {
+	d, r := newFakeDriver(map[string]string{jlsCmd: "7\n", psCmd: psOut})
+	r.dead[4251] = true
+	c := &execdriver.Command{ID: "1234abcd", ContainerPid: 4242}
+	if err := d.Kill(c, int(syscall.SIGKILL)); err != nil {
+		t.Fatal(err)
+	}
+	expected := []signal{{4242, syscall.SIGKILL}, {4250, syscall.SIGKILL}}
+	if !reflect.DeepEqual(r.signals, expected) {
+		t.Fatalf("Expected %v, got %v", expected, r.signals)
+	}
+}
+
+func TestKillMissingJail(t *testing.T) {
+	d, r := newFakeDriver(map[string]string{jlsCmd: "7\n", psCmd: ""})
+	c := &execdriver.Command{ID: "1234abcd"}
+	if err := d.Kill(c, int(syscall.SIGKILL)); err != syscall.ESRCH {
+		t.Fatalf("Expected ESRCH, got %v", err)
+	}
+	if err := d.Kill(c, int(syscall.SIGTERM)); err != syscall.ESRCH {
+		t.Fatalf("Expected ESRCH, got %v", err)
+	}
+	if len(r.signals) != 0 {
+		t.Fatalf("Expected no signal, got %v", r.signals)
+	}
+}
+
+func TestPauseUnpause(t *testing.T) {
+	d, r := newFakeDriver(map[string]string{jlsCmd: "7\n", psCmd: psOut})
+	c := &execdriver.Command{ID: "1234abcd", ContainerPid: 4242}
+	if err := d.Pause(c); err != nil {
+		t.Fatal(err)
+	}
+	expected := []signal{{4242, syscall.SIGSTOP}, {4250, syscall.SIGSTOP}, {4251, syscall.SIGSTOP}}
+	if !reflect.DeepEqual(r.signals, expected) {
+		t.Fatalf("Expected %v, got %v", expected, r.signals)
+	}
+	if err := d.Pause(c); err == nil {
+		t.Fatal("Expected an error pausing a paused jail")
+	}
+	if !d.Info(c.ID).IsRunning() {
+		t.Fatal("Expected a paused jail to be running")
+	}
+
+	r.signals = nil
+	if err := d.Unpause(c); err != nil {
+		t.Fatal(err)
+	}
+	expected = []signal{{4242, syscall.SIGCONT}, {4250, syscall.SIGCONT}, {4251, syscall.SIGCONT}}
+	if !reflect.DeepEqual(r.signals, expected) {
+		t.Fatalf("Expected %v, got %v", expected, r.signals)
+	}
+	if err := d.Unpause(c); err == nil {
+		t.Fatal("Expected an error unpausing a running jail")
+	}
+}
+
+func TestPauseMissingJail(t *testing.T) {
+	d, _ := newFakeDriver(nil)
+	c := &execdriver.Command{ID: "1234abcd"}
+	if err := d.Pause(c); err == nil {
+		t.Fatal("Expected an error pausing a missing jail")
+	}
+	if err := d.Unpause(c); err == nil {
+		t.Fatal("Expected an error unpausing a jail which was not paused")
+	}
+}
+
+func TestTerminatePaused(t *testing.T) {
+	d, r := newFakeDriver(map[string]string{jlsCmd: "7\n", psCmd: psOut, "jail -r 1234abcd": ""})
+	c := &execdriver.Command{ID: "1234abcd", ContainerPid: 4242}
+	if err := d.Pause(c); err != nil {
+		t.Fatal(err)
+	}
+	r.signals = nil
+	if err := d.Terminate(c); err != nil {
+		t.Fatal(err)
+	}
+	expected := []signal{{4242, syscall.SIGCONT}, {4250, syscall.SIGCONT}, {4251, syscall.SIGCONT}}
+	if !reflect.DeepEqual(r.signals, expected) {
+		t.Fatalf("Expected %v, got %v", expected, r.signals)
+	}
+	if d.paused[c.ID] {
+		t.Fatal("Expected the jail not to be paused anymore")
+	}
+}
+
+func TestIsRunning(t *testing.T) {
+	d, _ := newFakeDriver(map[string]string{jlsCmd: "7\n"})
+	if !d.Info("1234abcd").IsRunning() {
+		t.Fatal("Expected the jail to be running")
+	}
+	d, _ = newFakeDriver(nil)
+	if d.Info("1234abcd").IsRunning() {
+		t.Fatal("Expected a missing jail not to be running")
+	}
+}
+
+func TestList(t *testing.T) {
+	d, _ := newFakeDriver(map[string]string{"jls name": "1234abcd\nwww\n"})
+	names, err := d.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"1234abcd", "www"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+
+	d, _ = newFakeDriver(map[string]string{"jls name": ""})
+	if names, err := d.List(); err != nil || len(names) != 0 {
+		t.Fatalf("Expected no jails, got %v, %v", names, err)
+	}
+}
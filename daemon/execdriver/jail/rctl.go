@@ -0,0 +1,170 @@
+package jail
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/pkg/ulimit"
+	"github.com/docker/libcontainer"
+	"github.com/docker/libcontainer/cgroups"
+)
+
+// Default period of CpuQuota, as for the CFS scheduler on Linux.
+const defaultCpuPeriod = 100000
+
+// rctlResources maps the ulimits to the rctl(8) resources and whether
+// their amount is counted per process, as for a rlimit, or for the whole
+// jail.
+var rctlResources = map[int]struct {
+	name       string
+	perProcess bool
+}{
+	ulimit.RLIMIT_AS:      {"vmemoryuse", true},
+	ulimit.RLIMIT_CORE:    {"coredumpsize", true},
+	ulimit.RLIMIT_CPU:     {"cputime", true},
+	ulimit.RLIMIT_DATA:    {"datasize", true},
+	ulimit.RLIMIT_MEMLOCK: {"memorylocked", true},
+	ulimit.RLIMIT_NOFILE:  {"openfiles", true},
+	ulimit.RLIMIT_NPROC:   {"maxproc", false},
+	ulimit.RLIMIT_RSS:     {"memoryuse", true},
+	ulimit.RLIMIT_STACK:   {"stacksize", true},
+}
+
+// rctlSubject returns the rctl(8) subject of the jail id.
+func rctlSubject(id string) string {
+	return "jail:" + id
+}
+
+// rctlRules translates the resources of the jail id into rctl(8) rules.
+// It also returns the settings which can't be enforced with rctl.
+func rctlRules(id string, r *execdriver.Resources) (rules []string, unsupported []string) {
+	if r == nil {
+		return nil, nil
+	}
+	subject := rctlSubject(id)
+	rule := func(resource string, amount int64, per string) {
+		s := fmt.Sprintf("%s:%s:deny=%d", subject, resource, amount)
+		if per != "" {
+			s += "/" + per
+		}
+		rules = append(rules, s)
+	}
+
+	if r.Memory > 0 {
+		rule("memoryuse", r.Memory, "")
+		if r.MemorySwap > r.Memory {
+			rule("swapuse", r.MemorySwap-r.Memory, "")
+		}
+	}
+	if r.CpuQuota > 0 {
+		period := r.CpuPeriod
+		if period <= 0 {
+			period = defaultCpuPeriod
+		}
+		// pcpu is the percentage of a single CPU
+		percent := r.CpuQuota * 100 / period
+		if percent < 1 {
+			percent = 1
+		}
+		rule("pcpu", percent, "")
+	}
+	if r.CpuShares > 0 {
+		unsupported = append(unsupported, "cpu-shares")
+	}
+	for _, rl := range r.Rlimits {
+		res, ok := rctlResources[rl.Type]
+		if !ok {
+			unsupported = append(unsupported, fmt.Sprintf("ulimit %d", rl.Type))
+			continue
+		}
+		per := ""
+		if res.perProcess {
+			per = "process"
+		}
+		rule(res.name, int64(rl.Hard), per)
+	}
+	return rules, unsupported
+}
+
+// parseRctlUsage parses the output of `rctl -u jail:<id>`, lines of
+// resource=amount.
+func parseRctlUsage(out []byte) (map[string]uint64, error) {
+	usage := make(map[string]uint64)
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid rctl usage line %q", line)
+		}
+		amount, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount in rctl usage line %q", line)
+		}
+		usage[parts[0]] = amount
+	}
+	return usage, nil
+}
+
+// usageStats converts the resource usage of a jail to stats.
+//
+// The CPU usage comes from the cputime of rctl, which counts whole seconds.
+// It is the only counter which includes the processes of the jail which
+// exited: summing the finer times of the live processes, as ps(1) reports
+// them, would make the usage go back whenever a process exits. The CPU
+// percentage computed from two close samples is thus coarse, a container
+// using less than a second of CPU between them shows 0% or 100% of a CPU.
+func usageStats(usage map[string]uint64, memoryLimit int64, read time.Time) *execdriver.ResourceStats {
+	cs := cgroups.NewStats()
+	cs.CpuStats.CpuUsage.TotalUsage = usage["cputime"] * uint64(time.Second)
+	cs.MemoryStats.Usage = usage["memoryuse"]
+	for _, resource := range []string{"vmemoryuse", "swapuse", "memorylocked", "maxproc", "nthr", "openfiles"} {
+		if v, ok := usage[resource]; ok {
+			cs.MemoryStats.Stats[resource] = v
+		}
+	}
+	return &execdriver.ResourceStats{
+		Stats:       &libcontainer.Stats{CgroupStats: cs},
+		Read:        read,
+		MemoryLimit: memoryLimit,
+	}
+}
+
+// setRctlRules replaces the rctl(8) rules of the jail of c with the ones
+// of its resources.
+func (d *driver) setRctlRules(c *execdriver.Command) error {
+	rules, unsupported := rctlRules(c.ID, c.Resources)
+	for _, s := range unsupported {
+		logrus.Warnf("Resource limit %s is not supported by the jail driver and is ignored", s)
+	}
+	d.removeRctlRules(c.ID)
+	for _, rule := range rules {
+		if _, err := d.runner.Output("rctl", "-a", rule); err != nil {
+			return fmt.Errorf("Unable to set resource limits of jail %s: %v", c.ID, err)
+		}
+	}
+	return nil
+}
+
+// removeRctlRules removes the rctl(8) rules of the jail id. Errors are
+// ignored: there may be no rules, or racct may be disabled.
+func (d *driver) removeRctlRules(id string) {
+	if _, err := d.runner.Output("rctl", "-r", rctlSubject(id)); err != nil {
+		logrus.Debugf("Removing rctl rules of %s failed: %s", id, err)
+	}
+}
+
+// physicalMemory returns the memory of the host, in bytes.
+func (d *driver) physicalMemory() (int64, error) {
+	out, err := d.runner.Output("sysctl", "-n", "hw.physmem")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+}
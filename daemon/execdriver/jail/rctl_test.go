@@ -0,0 +1,132 @@
+package jail
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/pkg/ulimit"
+)
+
+func TestRctlRules(t *testing.T) {
+	r := &execdriver.Resources{
+		Memory:     512 * 1024 * 1024,
+		MemorySwap: 1024 * 1024 * 1024,
+		CpuShares:  512,
+		CpuQuota:   50000,
+		Rlimits: []*ulimit.Rlimit{
+			{Type: ulimit.RLIMIT_NOFILE, Soft: 1024, Hard: 2048},
+			{Type: ulimit.RLIMIT_NPROC, Soft: 100, Hard: 100},
+			{Type: ulimit.RLIMIT_NICE, Soft: 10, Hard: 10},
+		},
+	}
+	rules, unsupported := rctlRules("1234abcd", r)
+	expected := []string{
+		"jail:1234abcd:memoryuse:deny=536870912",
+		"jail:1234abcd:swapuse:deny=536870912",
+		"jail:1234abcd:pcpu:deny=50",
+		"jail:1234abcd:openfiles:deny=2048/process",
+		"jail:1234abcd:maxproc:deny=100",
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Fatalf("Expected rules %v, got %v", expected, rules)
+	}
+	if expected := []string{"cpu-shares", "ulimit 13"}; !reflect.DeepEqual(unsupported, expected) {
+		t.Fatalf("Expected unsupported %v, got %v", expected, unsupported)
+	}
+
+	rules, _ = rctlRules("1234abcd", &execdriver.Resources{CpuQuota: 150000, CpuPeriod: 50000})
+	if expected := []string{"jail:1234abcd:pcpu:deny=300"}; !reflect.DeepEqual(rules, expected) {
+		t.Fatalf("Expected rules %v, got %v", expected, rules)
+	}
+
+	if rules, unsupported := rctlRules("1234abcd", &execdriver.Resources{}); len(rules) != 0 || len(unsupported) != 0 {
+		t.Fatalf("Expected no rules, got %v, %v", rules, unsupported)
+	}
+}
+
+func TestParseRctlUsage(t *testing.T) {
+	out, err := ioutil.ReadFile(filepath.Join("testdata", "rctl-usage.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage, err := parseRctlUsage(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 25 || usage["memoryuse"] != 3739648 || usage["cputime"] != 12 {
+		t.Fatalf("Unexpected usage %v", usage)
+	}
+
+	for _, out := range []string{"cputime\n", "cputime=x\n", "cputime=-1\n"} {
+		if _, err := parseRctlUsage([]byte(out)); err == nil {
+			t.Fatalf("Expected an error parsing %q", out)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	out, err := ioutil.ReadFile(filepath.Join("testdata", "rctl-usage.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := newFakeDriver(map[string]string{
+		"rctl -u jail:1234abcd": string(out),
+		"sysctl -n hw.physmem":  "8589934592\n",
+	})
+	d.activeContainers = make(map[string]*execdriver.Command)
+
+	if _, err := d.Stats("1234abcd"); err != execdriver.ErrNotRunning {
+		t.Fatalf("Expected ErrNotRunning, got %v", err)
+	}
+
+	d.activeContainers["1234abcd"] = &execdriver.Command{ID: "1234abcd", Resources: &execdriver.Resources{}}
+	stats, err := d.Stats("1234abcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := stats.CgroupStats
+	if cs.CpuStats.CpuUsage.TotalUsage != uint64(12*time.Second) {
+		t.Fatalf("Unexpected cpu usage %d", cs.CpuStats.CpuUsage.TotalUsage)
+	}
+	if cs.MemoryStats.Usage != 3739648 || cs.MemoryStats.Stats["vmemoryuse"] != 15159296 {
+		t.Fatalf("Unexpected memory stats %+v", cs.MemoryStats)
+	}
+	if stats.MemoryLimit != 8589934592 {
+		t.Fatalf("Expected the memory of the host as limit, got %d", stats.MemoryLimit)
+	}
+
+	d.activeContainers["1234abcd"].Resources.Memory = 512 * 1024 * 1024
+	if stats, err := d.Stats("1234abcd"); err != nil || stats.MemoryLimit != 512*1024*1024 {
+		t.Fatalf("Expected the memory limit of the container, got %v", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	d, r := newFakeDriver(map[string]string{
+		"rctl -r jail:1234abcd":                        "",
+		"rctl -a jail:1234abcd:memoryuse:deny=4194304": "",
+	})
+	c := &execdriver.Command{ID: "1234abcd", Resources: &execdriver.Resources{Memory: 4194304}}
+	if err := d.Update(c); err != execdriver.ErrNotRunning {
+		t.Fatalf("Expected ErrNotRunning, got %v", err)
+	}
+	if len(r.commands) != 0 {
+		t.Fatalf("Expected no commands for a stopped jail, got %v", r.commands)
+	}
+
+	d.activeContainers["1234abcd"] = &execdriver.Command{ID: "1234abcd", Resources: &execdriver.Resources{}}
+	if err := d.Update(c); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"rctl -r jail:1234abcd", "rctl -a jail:1234abcd:memoryuse:deny=4194304"}
+	if !reflect.DeepEqual(r.commands, expected) {
+		t.Fatalf("Expected commands %v, got %v", expected, r.commands)
+	}
+	if d.activeContainers["1234abcd"].Resources.Memory != 4194304 {
+		t.Fatal("Expected the resources of the active jail to be updated")
+	}
+}
@@ -812,3 +812,9 @@ func (d *driver) Stats(id string) (*execdriver.ResourceStats, error) {
 	}
 	return execdriver.Stats(d.containerDir(id), d.activeContainers[id].container.Cgroups.Memory, d.machineMemory)
 }
+
+// Update is not supported: the lxc config of a container is only read when
+// it starts.
+func (d *driver) Update(c *execdriver.Command) error {
+	return execdriver.ErrUpdateNotSupported
+}
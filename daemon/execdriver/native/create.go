@@ -123,6 +123,25 @@ func (d *driver) createNetwork(container *configs.Config, c *execdriver.Command)
 		container.Networks = append(container.Networks, &vethNetwork)
 	}
 
+	// Additional networks only get a route to their own subnet, the
+	// default gateway stays on eth0.
+	for i, iface := range c.Network.Interfaces {
+		iName, err := generateIfaceName()
+		if err != nil {
+			return err
+		}
+		container.Networks = append(container.Networks, &configs.Network{
+			Name:              fmt.Sprintf("eth%d", i+1),
+			HostInterfaceName: iName,
+			Mtu:               c.Network.Mtu,
+			Address:           fmt.Sprintf("%s/%d", iface.IPAddress, iface.IPPrefixLen),
+			MacAddress:        iface.MacAddress,
+			Type:              "veth",
+			Bridge:            iface.Bridge,
+			HairpinMode:       iface.HairpinMode,
+		})
+	}
+
 	if c.Network.ContainerID != "" {
 		d.Lock()
 		active := d.activeContainers[c.Network.ContainerID]
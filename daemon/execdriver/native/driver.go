@@ -357,6 +357,21 @@ func (d *driver) Stats(id string) (*execdriver.ResourceStats, error) {
 	}, nil
 }
 
+// Update sets the cgroups of the running container to the resources of c.
+func (d *driver) Update(c *execdriver.Command) error {
+	d.Lock()
+	active := d.activeContainers[c.ID]
+	d.Unlock()
+	if active == nil {
+		return execdriver.ErrNotRunning
+	}
+	config := active.Config()
+	if err := execdriver.SetupCgroups(&config, c); err != nil {
+		return err
+	}
+	return active.Set(config)
+}
+
 type TtyConsole struct {
 	console libcontainer.Console
 }
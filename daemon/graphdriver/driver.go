@@ -124,6 +124,59 @@ type Driver interface {
 	DiffSize(id, parent string) (size int64, err error)
 }
 
+// QuotaDriver is implemented by the drivers which can limit the size of
+// a layer.
+type QuotaDriver interface {
+	// CreateWithOpts creates a new layer like Create, with driver
+	// specific options such as its maximum size.
+	CreateWithOpts(id, parent string, opts map[string]string) error
+	// ValidateStorageOpts checks the options given to CreateWithOpts.
+	ValidateStorageOpts(opts map[string]string) error
+	// Usage returns the usage of the layer with the specified id.
+	Usage(id string) (*Usage, error)
+}
+
+// Usage is the disk usage of a layer.
+type Usage struct {
+	// Quota is the maximum size of the layer in bytes, or 0 if it has
+	// no limit.
+	Quota uint64
+	// Used is the space used by the layer in bytes.
+	Used uint64
+}
+
+// LayerLister is implemented by the drivers which can list their layers,
+// to find the ones left behind by a crash.
+type LayerLister interface {
+	// Layers returns the ids of all the layers of the driver.
+	Layers() ([]string, error)
+}
+
+// CreateWithOpts creates a new layer with the storage options opts on
+// driver. Options are only supported by the drivers which implement
+// QuotaDriver.
+func CreateWithOpts(driver Driver, id, parent string, opts map[string]string) error {
+	if len(opts) == 0 {
+		return driver.Create(id, parent)
+	}
+	if q, ok := driver.(QuotaDriver); ok {
+		return q.CreateWithOpts(id, parent, opts)
+	}
+	return fmt.Errorf("Storage driver %s does not support storage options", driver)
+}
+
+// ValidateStorageOpts checks that driver supports the storage options
+// opts, so that they can be rejected before anything is created.
+func ValidateStorageOpts(driver Driver, opts map[string]string) error {
+	if len(opts) == 0 {
+		return nil
+	}
+	if q, ok := driver.(QuotaDriver); ok {
+		return q.ValidateStorageOpts(opts)
+	}
+	return fmt.Errorf("Storage driver %s does not support storage options", driver)
+}
+
 func init() {
 	drivers = make(map[string]InitFunc)
 }
@@ -27,8 +27,20 @@ type naiveDiffDriver struct {
 //     Changes(id, parent string) ([]archive.Change, error)
 //     ApplyDiff(id, parent string, diff archive.ArchiveReader) (size int64, err error)
 //     DiffSize(id, parent string) (size int64, err error)
+//
+// If driver implements QuotaDriver, so does the returned driver.
 func NaiveDiffDriver(driver ProtoDriver) Driver {
-	return &naiveDiffDriver{ProtoDriver: driver}
+	gdw := &naiveDiffDriver{ProtoDriver: driver}
+	if q, ok := driver.(QuotaDriver); ok {
+		return &naiveDiffQuotaDriver{gdw, q}
+	}
+	return gdw
+}
+
+// naiveDiffQuotaDriver is a naiveDiffDriver wrapping a QuotaDriver.
+type naiveDiffQuotaDriver struct {
+	*naiveDiffDriver
+	QuotaDriver
 }
 
 // Diff produces an archive of the changes between the specified
@@ -0,0 +1,254 @@
+// +build linux freebsd
+
+package zfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/ioutils"
+	zfs "github.com/mistifyio/go-zfs"
+)
+
+// The layers are clones of a snapshot of their parent, so `zfs diff`
+// between this origin snapshot and the layer gives its changes without
+// walking both filesystems. The naive diff driver is used for the layers
+// which are not a clone of their parent.
+
+// originSnapshot returns the snapshot of parent the layer id was cloned
+// from, or "" if it was not.
+func (d *Driver) originSnapshot(id, parent string) string {
+	if parent == "" {
+		return ""
+	}
+	dataset, err := d.backend.GetDataset(d.ZfsPath(id))
+	if err != nil {
+		log.Debugf("[zfs] failed to get dataset of %s: %v", id, err)
+		return ""
+	}
+	if !strings.HasPrefix(dataset.Origin, d.ZfsPath(parent)+"@") {
+		return ""
+	}
+	return dataset.Origin
+}
+
+// parseDiff returns the changes of the inode changes reported by
+// `zfs diff` for a filesystem mounted on mountpoint. The paths of the
+// changes are relative to the filesystem, and renamed inodes are deleted
+// from their old path and added to their new one.
+func parseDiff(mountpoint string, inodes []*zfs.InodeChange) ([]archive.Change, error) {
+	relative := func(path string) (string, error) {
+		rel, err := filepath.Rel(mountpoint, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", fmt.Errorf("zfs diff path %s is not under %s", path, mountpoint)
+		}
+		return filepath.Join("/", rel), nil
+	}
+
+	var changes []archive.Change
+	for _, inode := range inodes {
+		path, err := relative(inode.Path)
+		if err != nil {
+			return nil, err
+		}
+		switch inode.Change {
+		case zfs.Created:
+			changes = append(changes, archive.Change{Path: path, Kind: archive.ChangeAdd})
+		case zfs.Removed:
+			changes = append(changes, archive.Change{Path: path, Kind: archive.ChangeDelete})
+		case zfs.Modified:
+			// The root directory is not part of the changes
+			if path != "/" {
+				changes = append(changes, archive.Change{Path: path, Kind: archive.ChangeModify})
+			}
+		case zfs.Renamed:
+			newPath, err := relative(inode.NewPath)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes,
+				archive.Change{Path: path, Kind: archive.ChangeDelete},
+				archive.Change{Path: newPath, Kind: archive.ChangeAdd})
+		default:
+			return nil, fmt.Errorf("Unknown zfs diff change %d of %s", inode.Change, inode.Path)
+		}
+	}
+	return changes, nil
+}
+
+// cleanChanges returns changes sorted by path, without duplicates nor the
+// deletion of the content of deleted directories. A path both deleted and
+// added, by a rename, is replaced.
+func cleanChanges(changes []archive.Change) []archive.Change {
+	kinds := make(map[string]archive.ChangeType, len(changes))
+	for _, change := range changes {
+		kind, ok := kinds[change.Path]
+		// An addition wins over anything else, a deletion over a
+		// modification.
+		if !ok || change.Kind == archive.ChangeAdd || (change.Kind == archive.ChangeDelete && kind == archive.ChangeModify) {
+			kinds[change.Path] = change.Kind
+		}
+	}
+
+	deleted := func(path string) bool {
+		for dir := filepath.Dir(path); dir != "/"; dir = filepath.Dir(dir) {
+			if kinds[dir] == archive.ChangeDelete {
+				return true
+			}
+		}
+		return false
+	}
+
+	var paths []string
+	for path := range kinds {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var clean []archive.Change
+	for _, path := range paths {
+		kind := kinds[path]
+		if kind == archive.ChangeDelete && deleted(path) {
+			continue
+		}
+		clean = append(clean, archive.Change{Path: path, Kind: kind})
+	}
+	return clean
+}
+
+// addRenamedDirs adds the content of the directories added to layerFs to
+// changes: a renamed directory is a single change for `zfs diff`.
+func addRenamedDirs(layerFs string, changes []archive.Change) ([]archive.Change, error) {
+	var added []archive.Change
+	for _, change := range changes {
+		if change.Kind != archive.ChangeAdd {
+			continue
+		}
+		root := filepath.Join(layerFs, change.Path)
+		fi, err := os.Lstat(root)
+		if err != nil {
+			// The layer changed since the diff
+			continue
+		}
+		if !fi.IsDir() {
+			continue
+		}
+		err = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+			if err != nil || path == root {
+				return err
+			}
+			rel, err := filepath.Rel(layerFs, path)
+			if err != nil {
+				return err
+			}
+			added = append(added, archive.Change{Path: filepath.Join("/", rel), Kind: archive.ChangeAdd})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(added) == 0 {
+		return changes, nil
+	}
+	return cleanChanges(append(changes, added...)), nil
+}
+
+// diffChanges returns the changes of the layer id, mounted on layerFs,
+// since its origin snapshot.
+func (d *Driver) diffChanges(id, snapshot, layerFs string) ([]archive.Change, error) {
+	inodes, err := d.backend.Diff(snapshot, &zfs.Dataset{Name: d.ZfsPath(id)})
+	if err != nil {
+		return nil, err
+	}
+	changes, err := parseDiff(layerFs, inodes)
+	if err != nil {
+		return nil, err
+	}
+	return addRenamedDirs(layerFs, cleanChanges(changes))
+}
+
+// Changes produces a list of changes between the specified layer
+// and its parent layer. If parent is "", then all changes will be ADD changes.
+func (d *Driver) Changes(id, parent string) ([]archive.Change, error) {
+	snapshot := d.originSnapshot(id, parent)
+	if snapshot == "" {
+		return d.naive.Changes(id, parent)
+	}
+
+	layerFs, err := d.Get(id, "")
+	if err != nil {
+		return nil, err
+	}
+	defer d.Put(id)
+
+	return d.diffChanges(id, snapshot, layerFs)
+}
+
+// Diff produces an archive of the changes between the specified
+// layer and its parent layer which may be "".
+func (d *Driver) Diff(id, parent string) (arch archive.Archive, err error) {
+	snapshot := d.originSnapshot(id, parent)
+	if snapshot == "" {
+		return d.naive.Diff(id, parent)
+	}
+
+	layerFs, err := d.Get(id, "")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			d.Put(id)
+		}
+	}()
+
+	changes, err := d.diffChanges(id, snapshot, layerFs)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := archive.ExportChanges(layerFs, changes)
+	if err != nil {
+		return nil, err
+	}
+	return ioutils.NewReadCloserWrapper(archive, func() error {
+		err := archive.Close()
+		d.Put(id)
+		return err
+	}), nil
+}
+
+// ApplyDiff extracts the changeset from the given diff into the
+// layer with the specified id and parent, returning the size of the
+// new layer in bytes. Extracting the diff has nothing specific to zfs.
+func (d *Driver) ApplyDiff(id, parent string, diff archive.ArchiveReader) (size int64, err error) {
+	return d.naive.ApplyDiff(id, parent, diff)
+}
+
+// DiffSize calculates the changes between the specified layer
+// and its parent and returns the size in bytes of the changes
+// relative to its base filesystem directory.
+func (d *Driver) DiffSize(id, parent string) (size int64, err error) {
+	snapshot := d.originSnapshot(id, parent)
+	if snapshot == "" {
+		return d.naive.DiffSize(id, parent)
+	}
+
+	layerFs, err := d.Get(id, "")
+	if err != nil {
+		return
+	}
+	defer d.Put(id)
+
+	changes, err := d.diffChanges(id, snapshot, layerFs)
+	if err != nil {
+		return
+	}
+	return archive.ChangesSize(layerFs, changes), nil
+}
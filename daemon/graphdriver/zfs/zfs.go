@@ -1,4 +1,4 @@
-// +build linux, +build freebsd
+// +build linux freebsd
 
 package zfs
 
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,12 +18,16 @@ import (
 	"github.com/docker/docker/daemon/graphdriver"
 	"github.com/docker/docker/pkg/mount"
 	"github.com/docker/docker/pkg/parsers"
+	"github.com/docker/docker/pkg/units"
 	zfs "github.com/mistifyio/go-zfs"
 )
 
 type ZfsOptions struct {
 	fsName    string
 	mountPath string
+	// properties are the properties of the datasets of the layers, set
+	// with the zfs.compression, zfs.recordsize and zfs.atime options.
+	properties map[string]string
 }
 
 func init() {
@@ -35,6 +40,48 @@ func (*Logger) Log(cmd []string) {
 	log.Debugf("[zfs] %s", strings.Join(cmd, " "))
 }
 
+// backend runs the zfs commands of the driver with go-zfs. It is replaced
+// with a fake in the tests.
+type backend interface {
+	CreateFilesystem(name string, properties map[string]string) (*zfs.Dataset, error)
+	Snapshot(dataset *zfs.Dataset, name string) (*zfs.Dataset, error)
+	Clone(snapshot *zfs.Dataset, name string, properties map[string]string) (*zfs.Dataset, error)
+	Destroy(dataset *zfs.Dataset, flags zfs.DestroyFlag) error
+	GetDataset(name string) (*zfs.Dataset, error)
+	GetZpool(name string) (*zfs.Zpool, error)
+	Diff(snapshot string, dataset *zfs.Dataset) ([]*zfs.InodeChange, error)
+}
+
+type goZfs struct{}
+
+func (goZfs) CreateFilesystem(name string, properties map[string]string) (*zfs.Dataset, error) {
+	return zfs.CreateFilesystem(name, properties)
+}
+
+func (goZfs) Snapshot(dataset *zfs.Dataset, name string) (*zfs.Dataset, error) {
+	return dataset.Snapshot(name /*recursive */, false)
+}
+
+func (goZfs) Clone(snapshot *zfs.Dataset, name string, properties map[string]string) (*zfs.Dataset, error) {
+	return snapshot.Clone(name, properties)
+}
+
+func (goZfs) Destroy(dataset *zfs.Dataset, flags zfs.DestroyFlag) error {
+	return dataset.Destroy(flags)
+}
+
+func (goZfs) GetDataset(name string) (*zfs.Dataset, error) {
+	return zfs.GetDataset(name)
+}
+
+func (goZfs) GetZpool(name string) (*zfs.Zpool, error) {
+	return zfs.GetZpool(name)
+}
+
+func (goZfs) Diff(snapshot string, dataset *zfs.Dataset) ([]*zfs.InodeChange, error) {
+	return dataset.Diff(snapshot)
+}
+
 func Init(base string, opt []string) (graphdriver.Driver, error) {
 	var err error
 	options, err := parseOptions(opt)
@@ -93,13 +140,16 @@ func Init(base string, opt []string) (graphdriver.Driver, error) {
 		dataset:          rootDataset,
 		options:          options,
 		filesystemsCache: filesystemsCache,
+		backend:          goZfs{},
 	}
-	return graphdriver.NaiveDiffDriver(d), nil
+	d.naive = graphdriver.NaiveDiffDriver(d)
+	return d, nil
 }
 
 func parseOptions(opt []string) (ZfsOptions, error) {
 	var options ZfsOptions
 	options.fsName = ""
+	options.properties = map[string]string{}
 	for _, option := range opt {
 		key, val, err := parsers.ParseKeyValueOpt(option)
 		if err != nil {
@@ -109,6 +159,26 @@ func parseOptions(opt []string) (ZfsOptions, error) {
 		switch key {
 		case "zfs.fsname":
 			options.fsName = val
+		case "zfs.compression":
+			options.properties["compression"] = val
+		case "zfs.recordsize":
+			size, err := units.RAMInBytes(val)
+			if err != nil {
+				return options, fmt.Errorf("Invalid zfs.recordsize %s: %v", val, err)
+			}
+			options.properties["recordsize"] = strconv.FormatInt(size, 10)
+		case "zfs.atime":
+			atime, err := strconv.ParseBool(val)
+			if err != nil {
+				if val != "on" && val != "off" {
+					return options, fmt.Errorf("Invalid zfs.atime %s, expected on or off", val)
+				}
+			} else if atime {
+				val = "on"
+			} else {
+				val = "off"
+			}
+			options.properties["atime"] = val
 		default:
 			return options, fmt.Errorf("Unknown option %s", key)
 		}
@@ -116,6 +186,31 @@ func parseOptions(opt []string) (ZfsOptions, error) {
 	return options, nil
 }
 
+// parseStorageOpts returns the properties of the dataset of a layer
+// created with the storage options opts.
+func parseStorageOpts(opts map[string]string) (map[string]string, error) {
+	properties := map[string]string{}
+	for key, val := range opts {
+		switch strings.ToLower(key) {
+		case "size":
+			size, err := units.RAMInBytes(val)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid size %s: %v", val, err)
+			}
+			if size <= 0 {
+				return nil, fmt.Errorf("Invalid size %s: must be positive", val)
+			}
+			// quota also limits the snapshots of the layer, refquota only
+			// the data it references.
+			properties["quota"] = strconv.FormatInt(size, 10)
+			properties["refquota"] = strconv.FormatInt(size, 10)
+		default:
+			return nil, fmt.Errorf("Unknown storage option %s", key)
+		}
+	}
+	return properties, nil
+}
+
 func checkRootdirFs(rootdir string) error {
 	var buf syscall.Statfs_t
 	if err := syscall.Statfs(rootdir, &buf); err != nil {
@@ -160,6 +255,10 @@ type Driver struct {
 	options          ZfsOptions
 	sync.Mutex       // protects filesystem cache against concurrent access
 	filesystemsCache map[string]bool
+	backend          backend
+	// naive computes the changes of the layers which are not a clone of
+	// their parent.
+	naive graphdriver.Driver
 }
 
 func (d *Driver) String() string {
@@ -171,8 +270,15 @@ func (d *Driver) Cleanup() error {
 }
 
 func (d *Driver) Status() [][2]string {
-	parts := strings.Split(d.dataset.Name, "/")
-	pool, err := zfs.GetZpool(parts[0])
+	// The cached dataset is as old as the driver: get the current usage
+	dataset, err := d.backend.GetDataset(d.dataset.Name)
+	if err != nil {
+		log.Debugf("[zfs] failed to get dataset %s: %v", d.dataset.Name, err)
+		dataset = d.dataset
+	}
+
+	parts := strings.Split(dataset.Name, "/")
+	pool, err := d.backend.GetZpool(parts[0])
 
 	var poolName, poolHealth string
 	if err == nil {
@@ -184,30 +290,53 @@ func (d *Driver) Status() [][2]string {
 	}
 
 	quota := "no"
-	if d.dataset.Quota != 0 {
-		quota = strconv.FormatUint(d.dataset.Quota, 10)
+	if dataset.Quota != 0 {
+		quota = strconv.FormatUint(dataset.Quota, 10)
 	}
 
-	return [][2]string{
+	status := [][2]string{
 		{"Zpool", poolName},
 		{"Zpool Health", poolHealth},
-		{"Parent Dataset", d.dataset.Name},
-		{"Space Used By Parent", strconv.FormatUint(d.dataset.Used, 10)},
-		{"Space Available", strconv.FormatUint(d.dataset.Avail, 10)},
+		{"Parent Dataset", dataset.Name},
+		{"Space Used By Parent", strconv.FormatUint(dataset.Used, 10)},
+		{"Space Available", strconv.FormatUint(dataset.Avail, 10)},
 		{"Parent Quota", quota},
-		{"Compression", d.dataset.Compression},
+		{"Compression", dataset.Compression},
 	}
+	for _, p := range []struct{ name, property string }{
+		{"Layer Compression", "compression"},
+		{"Layer Record Size", "recordsize"},
+		{"Layer Atime", "atime"},
+	} {
+		if val, ok := d.options.properties[p.property]; ok {
+			status = append(status, [2]string{p.name, val})
+		}
+	}
+	return status
+}
+
+// properties returns the properties of the dataset of a new layer, with
+// the extra properties of its storage options.
+func (d *Driver) properties(extra map[string]string) map[string]string {
+	properties := map[string]string{"mountpoint": "legacy"}
+	for key, val := range d.options.properties {
+		properties[key] = val
+	}
+	for key, val := range extra {
+		properties[key] = val
+	}
+	return properties
 }
 
-func (d *Driver) cloneFilesystem(name, parentName string) error {
+func (d *Driver) cloneFilesystem(name, parentName string, properties map[string]string) error {
 	snapshotName := fmt.Sprintf("%d", time.Now().Nanosecond())
-	parentDataset := zfs.Dataset{Name: parentName}
-	snapshot, err := parentDataset.Snapshot(snapshotName /*recursive */, false)
+	parentDataset := &zfs.Dataset{Name: parentName}
+	snapshot, err := d.backend.Snapshot(parentDataset, snapshotName)
 	if err != nil {
 		return err
 	}
 
-	_, err = snapshot.Clone(name, map[string]string{"mountpoint": "legacy"})
+	_, err = d.backend.Clone(snapshot, name, properties)
 	if err == nil {
 		d.Lock()
 		d.filesystemsCache[name] = true
@@ -215,10 +344,10 @@ func (d *Driver) cloneFilesystem(name, parentName string) error {
 	}
 
 	if err != nil {
-		snapshot.Destroy(zfs.DestroyDeferDeletion)
+		d.backend.Destroy(snapshot, zfs.DestroyDeferDeletion)
 		return err
 	}
-	return snapshot.Destroy(zfs.DestroyDeferDeletion)
+	return d.backend.Destroy(snapshot, zfs.DestroyDeferDeletion)
 }
 
 func (d *Driver) ZfsPath(id string) string {
@@ -230,7 +359,19 @@ func (d *Driver) MountPath(id string) string {
 }
 
 func (d *Driver) Create(id string, parent string) error {
-	err := d.create(id, parent)
+	return d.CreateWithOpts(id, parent, nil)
+}
+
+// CreateWithOpts creates the layer id like Create. The only storage option
+// is size, the quota of the dataset of the layer.
+func (d *Driver) CreateWithOpts(id, parent string, opts map[string]string) error {
+	extra, err := parseStorageOpts(opts)
+	if err != nil {
+		return err
+	}
+	properties := d.properties(extra)
+
+	err = d.create(id, parent, properties)
 	if err == nil {
 		return nil
 	}
@@ -243,20 +384,25 @@ func (d *Driver) Create(id string, parent string) error {
 		return err
 	}
 
-	dataset := zfs.Dataset{Name: d.ZfsPath(id)}
-	if err := dataset.Destroy(zfs.DestroyRecursiveClones); err != nil {
+	dataset := &zfs.Dataset{Name: d.ZfsPath(id)}
+	if err := d.backend.Destroy(dataset, zfs.DestroyRecursiveClones); err != nil {
 		return err
 	}
 
 	// retry
-	return d.create(id, parent)
+	return d.create(id, parent, properties)
 }
 
-func (d *Driver) create(id, parent string) error {
+// ValidateStorageOpts checks the storage options of CreateWithOpts.
+func (d *Driver) ValidateStorageOpts(opts map[string]string) error {
+	_, err := parseStorageOpts(opts)
+	return err
+}
+
+func (d *Driver) create(id, parent string, properties map[string]string) error {
 	name := d.ZfsPath(id)
 	if parent == "" {
-		mountoptions := map[string]string{"mountpoint": "legacy"}
-		fs, err := zfs.CreateFilesystem(name, mountoptions)
+		fs, err := d.backend.CreateFilesystem(name, properties)
 		if err == nil {
 			d.Lock()
 			d.filesystemsCache[fs.Name] = true
@@ -264,13 +410,39 @@ func (d *Driver) create(id, parent string) error {
 		}
 		return err
 	}
-	return d.cloneFilesystem(name, d.ZfsPath(parent))
+	return d.cloneFilesystem(name, d.ZfsPath(parent), properties)
+}
+
+// Usage returns the quota and the space used by the dataset of the layer
+// id.
+func (d *Driver) Usage(id string) (*graphdriver.Usage, error) {
+	dataset, err := d.backend.GetDataset(d.ZfsPath(id))
+	if err != nil {
+		return nil, err
+	}
+	return &graphdriver.Usage{Quota: dataset.Quota, Used: dataset.Used}, nil
+}
+
+// Layers returns the ids of the layers, the datasets right under the
+// parent dataset.
+func (d *Driver) Layers() ([]string, error) {
+	prefix := d.options.fsName + "/"
+	d.Lock()
+	defer d.Unlock()
+	var ids []string
+	for name := range d.filesystemsCache {
+		if id := strings.TrimPrefix(name, prefix); id != name && !strings.Contains(id, "/") {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
 }
 
 func (d *Driver) Remove(id string) error {
 	name := d.ZfsPath(id)
-	dataset := zfs.Dataset{Name: name}
-	err := dataset.Destroy(zfs.DestroyRecursive)
+	dataset := &zfs.Dataset{Name: name}
+	err := d.backend.Destroy(dataset, zfs.DestroyRecursive)
 	if err == nil {
 		d.Lock()
 		delete(d.filesystemsCache, name)
@@ -0,0 +1,313 @@
+// +build linux freebsd
+
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/pkg/archive"
+	zfs "github.com/mistifyio/go-zfs"
+)
+
+// fakeBackend records the zfs commands of the driver instead of running
+// them.
+type fakeBackend struct {
+	commands []string
+	datasets map[string]*zfs.Dataset
+	inodes   []*zfs.InodeChange
+}
+
+func props(properties map[string]string) string {
+	var p []string
+	for key, val := range properties {
+		p = append(p, key+"="+val)
+	}
+	sort.Strings(p)
+	return strings.Join(p, ",")
+}
+
+func (b *fakeBackend) CreateFilesystem(name string, properties map[string]string) (*zfs.Dataset, error) {
+	b.commands = append(b.commands, fmt.Sprintf("create %s %s", props(properties), name))
+	return &zfs.Dataset{Name: name}, nil
+}
+
+func (b *fakeBackend) Snapshot(dataset *zfs.Dataset, name string) (*zfs.Dataset, error) {
+	b.commands = append(b.commands, "snapshot "+dataset.Name)
+	return &zfs.Dataset{Name: dataset.Name + "@" + name, Type: zfs.DatasetSnapshot}, nil
+}
+
+func (b *fakeBackend) Clone(snapshot *zfs.Dataset, name string, properties map[string]string) (*zfs.Dataset, error) {
+	b.commands = append(b.commands, fmt.Sprintf("clone %s %s", props(properties), name))
+	return &zfs.Dataset{Name: name}, nil
+}
+
+func (b *fakeBackend) Destroy(dataset *zfs.Dataset, flags zfs.DestroyFlag) error {
+	name := dataset.Name
+	if i := strings.Index(name, "@"); i >= 0 {
+		// Snapshot names are made of the time
+		name = name[:i] + "@snapshot"
+	}
+	b.commands = append(b.commands, "destroy "+name)
+	return nil
+}
+
+func (b *fakeBackend) GetDataset(name string) (*zfs.Dataset, error) {
+	if dataset, ok := b.datasets[name]; ok {
+		return dataset, nil
+	}
+	return nil, errors.New("dataset does not exist")
+}
+
+func (b *fakeBackend) GetZpool(name string) (*zfs.Zpool, error) {
+	return &zfs.Zpool{Name: name, Health: "ONLINE"}, nil
+}
+
+func (b *fakeBackend) Diff(snapshot string, dataset *zfs.Dataset) ([]*zfs.InodeChange, error) {
+	b.commands = append(b.commands, fmt.Sprintf("diff %s %s", snapshot, dataset.Name))
+	return b.inodes, nil
+}
+
+func newFakeDriver(t *testing.T, opt []string) (*Driver, *fakeBackend) {
+	options, err := parseOptions(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options.fsName = "zroot/docker"
+	b := &fakeBackend{datasets: map[string]*zfs.Dataset{}}
+	d := &Driver{
+		dataset:          &zfs.Dataset{Name: "zroot/docker"},
+		options:          options,
+		filesystemsCache: map[string]bool{},
+		backend:          b,
+	}
+	d.naive = graphdriver.NaiveDiffDriver(d)
+	return d, b
+}
+
+func TestParseOptions(t *testing.T) {
+	options, err := parseOptions([]string{"zfs.compression=lz4", "zfs.recordsize=16k", "zfs.atime=false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"compression": "lz4", "recordsize": "16384", "atime": "off"}
+	if !reflect.DeepEqual(options.properties, expected) {
+		t.Fatalf("Expected %v, got %v", expected, options.properties)
+	}
+
+	for _, opt := range []string{"zfs.recordsize=big", "zfs.atime=maybe", "zfs.dedup=on"} {
+		if _, err := parseOptions([]string{opt}); err == nil {
+			t.Fatalf("Expected an error parsing %s", opt)
+		}
+	}
+}
+
+func TestParseStorageOpts(t *testing.T) {
+	properties, err := parseStorageOpts(map[string]string{"size": "10G"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"quota": "10737418240", "refquota": "10737418240"}
+	if !reflect.DeepEqual(properties, expected) {
+		t.Fatalf("Expected %v, got %v", expected, properties)
+	}
+
+	for _, opts := range []map[string]string{{"size": "huge"}, {"size": "0"}, {"inodes": "100"}} {
+		if _, err := parseStorageOpts(opts); err == nil {
+			t.Fatalf("Expected an error parsing %v", opts)
+		}
+	}
+}
+
+func TestCreateWithOpts(t *testing.T) {
+	d, b := newFakeDriver(t, []string{"zfs.compression=lz4"})
+	if err := d.Create("base", ""); err != nil {
+		t.Fatal(err)
+	}
+	// Through the graphdriver like the daemon
+	if err := graphdriver.CreateWithOpts(d, "container", "base", map[string]string{"size": "1G"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"create compression=lz4,mountpoint=legacy zroot/docker/base",
+		"snapshot zroot/docker/base",
+		"clone compression=lz4,mountpoint=legacy,quota=1073741824,refquota=1073741824 zroot/docker/container",
+		"destroy zroot/docker/base@snapshot",
+	}
+	if !reflect.DeepEqual(b.commands, expected) {
+		t.Fatalf("Expected %v, got %v", expected, b.commands)
+	}
+	if !d.Exists("base") || !d.Exists("container") {
+		t.Fatal("Expected the layers to exist")
+	}
+
+	// Invalid options create nothing
+	b.commands = nil
+	if err := graphdriver.CreateWithOpts(d, "other", "base", map[string]string{"size": "huge"}); err == nil {
+		t.Fatal("Expected an error with an invalid size")
+	}
+	if len(b.commands) != 0 {
+		t.Fatalf("Expected no commands, got %v", b.commands)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	d, b := newFakeDriver(t, nil)
+	b.datasets["zroot/docker/container"] = &zfs.Dataset{Name: "zroot/docker/container", Quota: 1 << 30, Used: 4096}
+	usage, err := d.Usage("container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (graphdriver.Usage{Quota: 1 << 30, Used: 4096}); *usage != expected {
+		t.Fatalf("Expected %v, got %v", expected, *usage)
+	}
+	if _, err := d.Usage("missing"); err == nil {
+		t.Fatal("Expected an error for a missing layer")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	d, b := newFakeDriver(t, []string{"zfs.atime=off"})
+	b.datasets["zroot/docker"] = &zfs.Dataset{Name: "zroot/docker", Used: 2048, Avail: 8192, Quota: 10240, Compression: "off"}
+	expected := [][2]string{
+		{"Zpool", "zroot"},
+		{"Zpool Health", "ONLINE"},
+		{"Parent Dataset", "zroot/docker"},
+		{"Space Used By Parent", "2048"},
+		{"Space Available", "8192"},
+		{"Parent Quota", "10240"},
+		{"Compression", "off"},
+		{"Layer Atime", "off"},
+	}
+	if status := d.Status(); !reflect.DeepEqual(status, expected) {
+		t.Fatalf("Expected %v, got %v", expected, status)
+	}
+}
+
+func TestParseDiff(t *testing.T) {
+	inodes := []*zfs.InodeChange{
+		{Change: zfs.Modified, Type: zfs.Directory, Path: "/var/lib/docker/zfs/graph/layer/"},
+		{Change: zfs.Created, Type: zfs.File, Path: "/var/lib/docker/zfs/graph/layer/etc/hello.txt"},
+		{Change: zfs.Modified, Type: zfs.Directory, Path: "/var/lib/docker/zfs/graph/layer/etc"},
+		{Change: zfs.Removed, Type: zfs.SymbolicLink, Path: "/var/lib/docker/zfs/graph/layer/bin/sh"},
+		{Change: zfs.Renamed, Type: zfs.File, Path: "/var/lib/docker/zfs/graph/layer/a b", NewPath: "/var/lib/docker/zfs/graph/layer/c"},
+	}
+	changes, err := parseDiff("/var/lib/docker/zfs/graph/layer", inodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []archive.Change{
+		{Path: "/etc/hello.txt", Kind: archive.ChangeAdd},
+		{Path: "/etc", Kind: archive.ChangeModify},
+		{Path: "/bin/sh", Kind: archive.ChangeDelete},
+		{Path: "/a b", Kind: archive.ChangeDelete},
+		{Path: "/c", Kind: archive.ChangeAdd},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("Expected %v, got %v", expected, changes)
+	}
+
+	outside := []*zfs.InodeChange{{Change: zfs.Created, Type: zfs.File, Path: "/var/lib/docker/zfs/graph/other/file"}}
+	if _, err := parseDiff("/var/lib/docker/zfs/graph/layer", outside); err == nil {
+		t.Fatal("Expected an error for a path outside of the layer")
+	}
+	unknown := []*zfs.InodeChange{{Type: zfs.File, Path: "/var/lib/docker/zfs/graph/layer/file"}}
+	if _, err := parseDiff("/var/lib/docker/zfs/graph/layer", unknown); err == nil {
+		t.Fatal("Expected an error for an unknown change")
+	}
+}
+
+func TestCleanChanges(t *testing.T) {
+	changes := []archive.Change{
+		{Path: "/usr/lib", Kind: archive.ChangeDelete},
+		{Path: "/usr", Kind: archive.ChangeModify},
+		{Path: "/usr/lib/libc.so", Kind: archive.ChangeDelete},
+		{Path: "/etc/passwd", Kind: archive.ChangeModify},
+		{Path: "/etc/passwd", Kind: archive.ChangeDelete},
+		{Path: "/etc/passwd", Kind: archive.ChangeAdd},
+		{Path: "/etc", Kind: archive.ChangeModify},
+		{Path: "/etc", Kind: archive.ChangeModify},
+	}
+	expected := []archive.Change{
+		{Path: "/etc", Kind: archive.ChangeModify},
+		{Path: "/etc/passwd", Kind: archive.ChangeAdd},
+		{Path: "/usr", Kind: archive.ChangeModify},
+		{Path: "/usr/lib", Kind: archive.ChangeDelete},
+	}
+	if clean := cleanChanges(changes); !reflect.DeepEqual(clean, expected) {
+		t.Fatalf("Expected %v, got %v", expected, clean)
+	}
+}
+
+func TestDiffChanges(t *testing.T) {
+	layerFs, err := ioutil.TempDir("", "zfs-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(layerFs)
+	// /srv was renamed to /data
+	if err := os.MkdirAll(filepath.Join(layerFs, "data", "www"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(layerFs, "data", "www", "index.html"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, b := newFakeDriver(t, nil)
+	b.datasets["zroot/docker/container"] = &zfs.Dataset{Name: "zroot/docker/container", Origin: "zroot/docker/image@123"}
+	b.inodes = []*zfs.InodeChange{
+		{Change: zfs.Modified, Type: zfs.Directory, Path: layerFs + "/"},
+		{Change: zfs.Renamed, Type: zfs.Directory, Path: layerFs + "/srv", NewPath: layerFs + "/data"},
+	}
+
+	if snapshot := d.originSnapshot("container", "other"); snapshot != "" {
+		t.Fatalf("Expected no origin snapshot of another parent, got %s", snapshot)
+	}
+	snapshot := d.originSnapshot("container", "image")
+	if snapshot != "zroot/docker/image@123" {
+		t.Fatalf("Expected the origin snapshot zroot/docker/image@123, got %q", snapshot)
+	}
+	changes, err := d.diffChanges("container", snapshot, layerFs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []archive.Change{
+		{Path: "/data", Kind: archive.ChangeAdd},
+		{Path: "/data/www", Kind: archive.ChangeAdd},
+		{Path: "/data/www/index.html", Kind: archive.ChangeAdd},
+		{Path: "/srv", Kind: archive.ChangeDelete},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("Expected %v, got %v", expected, changes)
+	}
+	if last := b.commands[len(b.commands)-1]; last != "diff zroot/docker/image@123 zroot/docker/container" {
+		t.Fatalf("Expected a zfs diff from the origin snapshot, got %s", last)
+	}
+}
+
+func TestLayers(t *testing.T) {
+	d, _ := newFakeDriver(t, nil)
+	d.filesystemsCache = map[string]bool{
+		"zroot/docker":             true,
+		"zroot/docker/image":       true,
+		"zroot/docker/container":   true,
+		"zroot/docker/image/child": true,
+		"zroot/other":              true,
+	}
+	layers, err := d.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"container", "image"}; !reflect.DeepEqual(layers, expected) {
+		t.Fatalf("Expected %v, got %v", expected, layers)
+	}
+}
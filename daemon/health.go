@@ -0,0 +1,275 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/runconfig"
+)
+
+const (
+	// Longest healthcheck probe output message to store. Longer messages will be truncated.
+	maxOutputLen = 4096
+
+	// Default interval between probe runs (from the end of the first to the start of the second).
+	// Also the time before the first probe.
+	defaultProbeInterval = 30 * time.Second
+
+	// The maximum length of time a single probe run should take. If the probe takes longer
+	// than this, the check is considered to have failed.
+	defaultProbeTimeout = 30 * time.Second
+
+	// Default number of consecutive failures of the health check
+	// for the container to be considered unhealthy.
+	defaultProbeRetries = 3
+
+	// Maximum number of entries to record
+	maxLogEntries = 5
+)
+
+// Health holds the current health state of a container, along with the
+// channel used to stop its monitor.
+type Health struct {
+	types.Health
+
+	stop chan struct{} // closed to stop the health monitor
+}
+
+// String returns a human-readable description of the health state
+func (s *Health) String() string {
+	if s.Status == types.Starting {
+		return "health: starting"
+	}
+	return s.Status
+}
+
+// update records the result of a probe and reports whether it changed
+// the health status.
+func (s *Health) update(result *types.HealthcheckResult, retries int) bool {
+	s.Log = append(s.Log, result)
+	if l := len(s.Log); l > maxLogEntries {
+		s.Log = append([]*types.HealthcheckResult(nil), s.Log[l-maxLogEntries:]...)
+	}
+
+	oldStatus := s.Status
+	if result.ExitCode == 0 {
+		s.FailingStreak = 0
+		s.Status = types.Healthy
+	} else {
+		s.FailingStreak++
+		if s.FailingStreak >= retries {
+			s.Status = types.Unhealthy
+		}
+		// Else we're starting or healthy. Stay in that state.
+	}
+	return s.Status != oldStatus
+}
+
+// initHealthMonitor starts the healthcheck of the container, if it has one.
+// The container must be locked.
+func (container *Container) initHealthMonitor() {
+	container.stopHealthMonitor()
+
+	config := container.Config.Healthcheck
+	if config.IsDisabled() {
+		container.State.Health = nil
+		return
+	}
+
+	h := container.State.Health
+	if h == nil {
+		h = &Health{}
+		container.State.Health = h
+	}
+	h.Status = types.Starting
+	h.FailingStreak = 0
+	h.stop = make(chan struct{})
+
+	go container.monitorHealth(config, h.stop)
+}
+
+// stopHealthMonitor stops the healthcheck of the container. The container
+// must be locked.
+func (container *Container) stopHealthMonitor() {
+	if h := container.State.Health; h != nil && h.stop != nil {
+		logrus.Debugf("Stopping healthcheck of container %s", container.ID)
+		close(h.stop)
+		h.stop = nil
+	}
+}
+
+func (container *Container) monitorHealth(config *runconfig.HealthConfig, stop chan struct{}) {
+	var (
+		interval = durationWithDefault(config.Interval, defaultProbeInterval)
+		timeout  = durationWithDefault(config.Timeout, defaultProbeTimeout)
+		retries  = config.Retries
+	)
+	if retries <= 0 {
+		retries = defaultProbeRetries
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+
+		if container.IsPaused() {
+			continue
+		}
+
+		results := make(chan *types.HealthcheckResult, 1)
+		start := time.Now()
+		go func() {
+			result, err := container.daemon.runHealthcheck(container, config.Test, timeout)
+			if err != nil {
+				logrus.Warnf("Health check for container %s error: %v", container.ID, err)
+				result = &types.HealthcheckResult{
+					ExitCode: -1,
+					Output:   err.Error(),
+					Start:    start,
+					End:      time.Now(),
+				}
+			}
+			results <- result
+		}()
+
+		select {
+		case <-stop:
+			return
+		case result := <-results:
+			// A probe is never started before the previous one is
+			// done or killed for exceeding its timeout
+			container.handleProbeResult(result, retries, stop)
+		}
+	}
+}
+
+// handleProbeResult records the result of a probe, unless the monitor
+// which ran it has been stopped since.
+func (container *Container) handleProbeResult(result *types.HealthcheckResult, retries int, stop chan struct{}) {
+	container.Lock()
+	h := container.State.Health
+	if h == nil || h.stop != stop {
+		container.Unlock()
+		return
+	}
+	changed := h.update(result, retries)
+	status := h.Status
+	if err := container.toDisk(); err != nil {
+		logrus.Errorf("Error dumping container %s state to disk: %s", container.ID, err)
+	}
+	container.Unlock()
+
+	if changed {
+		container.LogEvent("health_status: " + status)
+	}
+}
+
+// runHealthcheck runs a probe in the container using the exec machinery
+// and returns its result. The probe is killed if it runs for longer than
+// timeout.
+func (daemon *Daemon) runHealthcheck(container *Container, test []string, timeout time.Duration) (*types.HealthcheckResult, error) {
+	var cmd []string
+	switch {
+	case len(test) > 1 && test[0] == "CMD":
+		cmd = test[1:]
+	case len(test) == 2 && test[0] == "CMD-SHELL":
+		cmd = []string{"/bin/sh", "-c", test[1]}
+	default:
+		return nil, fmt.Errorf("Unknown healthcheck type %q", test)
+	}
+
+	id, err := daemon.ContainerExecCreate(&runconfig.ExecConfig{
+		User:         container.Config.User,
+		Container:    container.ID,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return nil, err
+	}
+	execConfig := daemon.execCommands.Get(id)
+	if execConfig == nil {
+		return nil, fmt.Errorf("No such exec instance '%s' found in daemon", id)
+	}
+	defer daemon.unregisterExecCommand(execConfig)
+
+	output := &limitedBuffer{}
+	start := time.Now()
+	execErr := make(chan error, 1)
+	go func() {
+		execErr <- daemon.ContainerExecStart(id, nil, output, output)
+	}()
+
+	select {
+	case err := <-execErr:
+		if err != nil {
+			return nil, err
+		}
+	case <-time.After(timeout):
+		if err := execConfig.kill(); err != nil {
+			logrus.Warnf("Unable to kill the health check of container %s: %v", container.ID, err)
+		}
+		return &types.HealthcheckResult{
+			ExitCode: -1,
+			Output:   fmt.Sprintf("Health check exceeded timeout (%v)", timeout),
+			Start:    start,
+			End:      time.Now(),
+		}, nil
+	}
+
+	return &types.HealthcheckResult{
+		Start:    start,
+		End:      time.Now(),
+		ExitCode: execConfig.ExitCode,
+		Output:   output.String(),
+	}, nil
+}
+
+func durationWithDefault(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	return d
+}
+
+// limitedBuffer is a thread-safe buffer which keeps the first maxOutputLen
+// bytes written to it.
+type limitedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bufLen := b.buf.Len()
+	dataLen := len(data)
+	keep := dataLen
+	if keep > maxOutputLen-bufLen {
+		keep = maxOutputLen - bufLen
+		b.truncated = true
+	}
+	b.buf.Write(data[:keep])
+	return dataLen, nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := b.buf.String()
+	if b.truncated {
+		out = out + "..."
+	}
+	return out
+}
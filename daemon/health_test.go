@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestHealthUpdate(t *testing.T) {
+	h := &Health{}
+	h.Status = types.Starting
+
+	fail := &types.HealthcheckResult{ExitCode: 1}
+	pass := &types.HealthcheckResult{ExitCode: 0}
+
+	if h.update(fail, 2) || h.Status != types.Starting {
+		t.Fatalf("Expected to still be starting after one failure, got %s", h.Status)
+	}
+	if !h.update(fail, 2) || h.Status != types.Unhealthy {
+		t.Fatalf("Expected to be unhealthy after two failures, got %s", h.Status)
+	}
+	if !h.update(pass, 2) || h.Status != types.Healthy || h.FailingStreak != 0 {
+		t.Fatalf("Expected to be healthy after a success, got %s (streak %d)", h.Status, h.FailingStreak)
+	}
+	if h.update(fail, 2) || h.Status != types.Healthy {
+		t.Fatalf("Expected to still be healthy after one failure, got %s", h.Status)
+	}
+
+	for i := 0; i < 10; i++ {
+		h.update(pass, 2)
+	}
+	if len(h.Log) != maxLogEntries {
+		t.Fatalf("Expected %d log entries, got %d", maxLogEntries, len(h.Log))
+	}
+
+	if s := (&State{Running: true, Health: h}).String(); !strings.HasSuffix(s, "(healthy)") {
+		t.Fatalf("Expected the state to show the health, got %q", s)
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{}
+	b.Write([]byte("hello "))
+	b.Write([]byte(strings.Repeat("x", maxOutputLen)))
+
+	out := b.String()
+	if !strings.HasPrefix(out, "hello x") || !strings.HasSuffix(out, "...") {
+		t.Fatalf("Unexpected output %q", out)
+	}
+	if len(out) != maxOutputLen+len("...") {
+		t.Fatalf("Expected the output to be truncated to %d bytes, got %d", maxOutputLen, len(out))
+	}
+}
@@ -105,10 +105,14 @@ func (daemon *Daemon) imgDeleteHelper(name string, list *[]types.ImageDelete, fi
 				return err
 			}
 			if tagDeleted {
+				name := utils.ImageReference(repoName, tag)
 				*list = append(*list, types.ImageDelete{
-					Untagged: utils.ImageReference(repoName, tag),
+					Untagged: name,
+				})
+				daemon.EventsService.Log("untag", types.ImageEventType, types.EventActor{
+					ID:         img.ID,
+					Attributes: map[string]string{"name": name},
 				})
-				daemon.EventsService.Log("untag", img.ID, "")
 			}
 		}
 	}
@@ -124,7 +128,7 @@ func (daemon *Daemon) imgDeleteHelper(name string, list *[]types.ImageDelete, fi
 			*list = append(*list, types.ImageDelete{
 				Deleted: img.ID,
 			})
-			daemon.EventsService.Log("delete", img.ID, "")
+			daemon.EventsService.Log("delete", types.ImageEventType, types.EventActor{ID: img.ID})
 			if img.Parent != "" && !noprune {
 				err := daemon.imgDeleteHelper(img.Parent, list, false, force, noprune)
 				if first {
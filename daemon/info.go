@@ -73,18 +73,18 @@ func (daemon *Daemon) SystemInfo() (*types.Info, error) {
 		NGoroutines:        runtime.NumGoroutine(),
 		SystemTime:         time.Now().Format(time.RFC3339Nano),
 		ExecutionDriver:    daemon.ExecutionDriver().Name(),
-		LoggingDriver:      daemon.defaultLogConfig.Type,
+		LoggingDriver:      daemon.getDefaultLogConfig().Type,
 		NEventsListener:    daemon.EventsService.SubscribersCount(),
 		KernelVersion:      kernelVersion,
 		OperatingSystem:    operatingSystem,
 		IndexServerAddress: registry.IndexServerAddress(),
-		RegistryConfig:     daemon.RegistryService.Config,
+		RegistryConfig:     daemon.RegistryService.Config(),
 		InitSha1:           dockerversion.INITSHA1,
 		InitPath:           initPath,
 		NCPU:               runtime.NumCPU(),
 		MemTotal:           meminfo.MemTotal,
 		DockerRootDir:      daemon.Config().Root,
-		Labels:             daemon.Config().Labels,
+		Labels:             daemon.labels(),
 	}
 
 	if httpProxy := os.Getenv("http_proxy"); httpProxy != "" {
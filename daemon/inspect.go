@@ -3,7 +3,9 @@ package daemon
 import (
 	"fmt"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/daemon/graphdriver"
 	"github.com/docker/docker/runconfig"
 )
 
@@ -32,7 +34,7 @@ func (daemon *Daemon) ContainerInspect(name string) (*types.ContainerJSON, error
 	// we need this trick to preserve empty log driver, so
 	// container will use daemon defaults even if daemon change them
 	if hostConfig.LogConfig.Type == "" {
-		hostConfig.LogConfig = daemon.defaultLogConfig
+		hostConfig.LogConfig = daemon.getDefaultLogConfig()
 	}
 
 	containerState := &types.ContainerState{
@@ -47,6 +49,11 @@ func (daemon *Daemon) ContainerInspect(name string) (*types.ContainerJSON, error
 		StartedAt:  container.State.StartedAt,
 		FinishedAt: container.State.FinishedAt,
 	}
+	if container.State.Health != nil {
+		health := container.State.Health.Health
+		health.Log = append([]*types.HealthcheckResult(nil), health.Log...)
+		containerState.Health = &health
+	}
 
 	contJSON := &types.ContainerJSON{
 		Id:              container.ID,
@@ -63,6 +70,7 @@ func (daemon *Daemon) ContainerInspect(name string) (*types.ContainerJSON, error
 		LogPath:         container.LogPath,
 		Name:            container.Name,
 		RestartCount:    container.RestartCount,
+		LastRestartedAt: container.LastRestartedAt,
 		Driver:          container.Driver,
 		ExecDriver:      container.ExecDriver,
 		MountLabel:      container.MountLabel,
@@ -74,6 +82,14 @@ func (daemon *Daemon) ContainerInspect(name string) (*types.ContainerJSON, error
 		HostConfig:      &hostConfig,
 	}
 
+	if q, ok := daemon.driver.(graphdriver.QuotaDriver); ok {
+		if usage, err := q.Usage(container.ID); err == nil {
+			contJSON.Storage = &types.ContainerStorage{Quota: usage.Quota, Used: usage.Used}
+		} else {
+			logrus.Debugf("Unable to get the storage usage of %s: %s", container.ID, err)
+		}
+	}
+
 	return contJSON, nil
 }
 
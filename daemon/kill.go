@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"fmt"
+	"strconv"
 	"syscall"
 )
 
@@ -15,6 +16,8 @@ func (daemon *Daemon) ContainerKill(name string, sig uint64) error {
 		return err
 	}
 
+	running := container.IsRunning()
+
 	// If no signal is passed, or SIGKILL, perform regular Kill (SIGKILL + wait())
 	if sig == 0 || syscall.Signal(sig) == syscall.SIGKILL {
 		if err := container.Kill(); err != nil {
@@ -26,6 +29,12 @@ func (daemon *Daemon) ContainerKill(name string, sig uint64) error {
 			return fmt.Errorf("Cannot kill container %s: %s", name, err)
 		}
 	}
-	container.LogEvent("kill")
+	if running {
+		container.setManuallyStopped()
+	}
+	if sig == 0 {
+		sig = uint64(syscall.SIGKILL)
+	}
+	container.LogEventWithAttributes("kill", map[string]string{"signal": strconv.FormatUint(sig, 10)})
 	return nil
 }
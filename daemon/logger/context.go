@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/docker/docker/pkg/stringid"
+)
+
+// ID returns the short ID of the container.
+func (ctx Context) ID() string {
+	return stringid.TruncateID(ctx.ContainerID)
+}
+
+// FullID returns the ID of the container.
+func (ctx Context) FullID() string {
+	return ctx.ContainerID
+}
+
+// Name returns the name of the container, without the leading slash.
+func (ctx Context) Name() string {
+	return strings.TrimPrefix(ctx.ContainerName, "/")
+}
+
+// ImageID returns the short ID of the image of the container.
+func (ctx Context) ImageID() string {
+	return stringid.TruncateID(ctx.ContainerImageID)
+}
+
+// ImageFullID returns the ID of the image of the container.
+func (ctx Context) ImageFullID() string {
+	return ctx.ContainerImageID
+}
+
+// ImageName returns the name the container was created from.
+func (ctx Context) ImageName() string {
+	return ctx.ContainerImageName
+}
+
+// Hostname returns the hostname of the daemon's host.
+func (ctx Context) Hostname() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("logger: can not resolve hostname: %v", err)
+	}
+	return hostname, nil
+}
+
+// Tag renders the template given with the "tag" log option, or else
+// defaultTemplate, for the container. The template can refer to the
+// methods of Context, e.g. "{{.ImageName}}/{{.Name}}/{{.ID}}".
+func (ctx Context) Tag(defaultTemplate string) (string, error) {
+	text := ctx.Config["tag"]
+	if text == "" {
+		text = defaultTemplate
+	}
+	tmpl, err := template.New("tag").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("logger: invalid tag template %q: %v", text, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, ctx); err != nil {
+		return "", fmt.Errorf("logger: invalid tag template %q: %v", text, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,37 @@
+package logger
+
+import "testing"
+
+func TestContextTag(t *testing.T) {
+	ctx := Context{
+		ContainerID:        "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657",
+		ContainerName:      "/web",
+		ContainerImageID:   "9b9cb95443b5f846cd3c8cfa3f64e63b6ba68de2618a08875a119c81a8f96698",
+		ContainerImageName: "nginx:latest",
+		Config:             map[string]string{},
+	}
+
+	tag, err := ctx.Tag("{{.ID}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag != "a7317399f3f8" {
+		t.Fatalf("Expected the default tag a7317399f3f8, got %s", tag)
+	}
+
+	ctx.Config["tag"] = "{{.ImageName}}/{{.Name}}/{{.ImageID}}"
+	tag, err = ctx.Tag("{{.ID}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag != "nginx:latest/web/9b9cb95443b5" {
+		t.Fatalf("Expected the tag nginx:latest/web/9b9cb95443b5, got %s", tag)
+	}
+
+	for _, tmpl := range []string{"{{.ID", "{{.Nope}}"} {
+		ctx.Config["tag"] = tmpl
+		if _, err := ctx.Tag("{{.ID}}"); err == nil {
+			t.Fatalf("Expected an error for the tag %s", tmpl)
+		}
+	}
+}
@@ -8,17 +8,24 @@ import (
 // Creator is a method that builds a logging driver instance with given context
 type Creator func(Context) (Logger, error)
 
+// LogOptValidator checks the options of a logging driver
+type LogOptValidator func(cfg map[string]string) error
+
 // Context provides enough information for a logging driver to do its function
 type Context struct {
-	Config        map[string]string
-	ContainerID   string
-	ContainerName string
-	LogPath       string
+	Config             map[string]string
+	ContainerID        string
+	ContainerName      string
+	ContainerImageID   string
+	ContainerImageName string
+	ContainerLabels    map[string]string
+	LogPath            string
 }
 
 type logdriverFactory struct {
-	registry map[string]Creator
-	m        sync.Mutex
+	registry     map[string]Creator
+	optValidator map[string]LogOptValidator
+	m            sync.Mutex
 }
 
 func (lf *logdriverFactory) register(name string, c Creator) error {
@@ -32,6 +39,24 @@ func (lf *logdriverFactory) register(name string, c Creator) error {
 	return nil
 }
 
+func (lf *logdriverFactory) registerLogOptValidator(name string, l LogOptValidator) error {
+	lf.m.Lock()
+	defer lf.m.Unlock()
+
+	if _, ok := lf.optValidator[name]; ok {
+		return fmt.Errorf("logger: log option validator named '%s' is already registered", name)
+	}
+	lf.optValidator[name] = l
+	return nil
+}
+
+func (lf *logdriverFactory) getLogOptValidator(name string) LogOptValidator {
+	lf.m.Lock()
+	defer lf.m.Unlock()
+
+	return lf.optValidator[name]
+}
+
 func (lf *logdriverFactory) get(name string) (Creator, error) {
 	lf.m.Lock()
 	defer lf.m.Unlock()
@@ -43,7 +68,7 @@ func (lf *logdriverFactory) get(name string) (Creator, error) {
 	return c, nil
 }
 
-var factory = &logdriverFactory{registry: make(map[string]Creator)} // global factory instance
+var factory = &logdriverFactory{registry: make(map[string]Creator), optValidator: make(map[string]LogOptValidator)} // global factory instance
 
 // RegisterLogDriver registers the given logging driver builder with given logging
 // driver name.
@@ -55,3 +80,18 @@ func RegisterLogDriver(name string, c Creator) error {
 func GetLogDriver(name string) (Creator, error) {
 	return factory.get(name)
 }
+
+// RegisterLogOptValidator registers the function checking the options of
+// the logging driver with given name.
+func RegisterLogOptValidator(name string, l LogOptValidator) error {
+	return factory.registerLogOptValidator(name, l)
+}
+
+// ValidateLogOpts checks the options given for a logging driver. Drivers
+// without a registered validator accept any option.
+func ValidateLogOpts(name string, cfg map[string]string) error {
+	if validate := factory.getLogOptValidator(name); validate != nil {
+		return validate(cfg)
+	}
+	return nil
+}
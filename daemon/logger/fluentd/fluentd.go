@@ -0,0 +1,251 @@
+// Package fluentd provides the log driver for forwarding server logs
+// to fluentd endpoints with the forward protocol.
+package fluentd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/logger"
+)
+
+const (
+	name = "fluentd"
+
+	defaultHost = "127.0.0.1"
+	defaultPort = 24224
+
+	dialTimeout  = 5 * time.Second
+	writeTimeout = 5 * time.Second
+
+	// bufferLimit is the number of messages kept while fluentd cannot be
+	// reached. The messages logged past it are dropped.
+	bufferLimit = 1024
+
+	// The connection is retried with a delay doubling from
+	// minRetryDelay up to maxRetryDelay.
+	minRetryDelay = 100 * time.Millisecond
+	maxRetryDelay = 30 * time.Second
+)
+
+// fluentd queues the messages logged by the container, a goroutine writes
+// them to the server. This way, logging does not block while the server is
+// unreachable.
+type fluentd struct {
+	address string
+	tag     string
+
+	// fields are the fields of every record, besides the log line and
+	// its source: the container and its labels.
+	fields map[string]string
+
+	mu      sync.Mutex // protects closed
+	closed  bool
+	queue   chan []byte   // encoded messages waiting to be written
+	closing chan struct{} // closed by Close
+	stopped chan struct{} // closed once the writer is done
+
+	conn net.Conn // only used by the writer
+}
+
+func init() {
+	if err := logger.RegisterLogDriver(name, New); err != nil {
+		logrus.Fatal(err)
+	}
+	if err := logger.RegisterLogOptValidator(name, ValidateLogOpt); err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+// New creates a fluentd logger sending the messages of the container to
+// the fluentd-address given in the log options.
+func New(ctx logger.Context) (logger.Logger, error) {
+	address, err := parseAddress(ctx.Config["fluentd-address"])
+	if err != nil {
+		return nil, err
+	}
+	tag, err := ctx.Tag("docker.{{.ID}}")
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string)
+	for k, v := range ctx.ContainerLabels {
+		fields[k] = v
+	}
+	fields["container_id"] = ctx.FullID()
+	fields["container_name"] = ctx.Name()
+	fields["image_id"] = ctx.ImageFullID()
+	fields["image_name"] = ctx.ImageName()
+
+	f := &fluentd{
+		address: address,
+		tag:     tag,
+		fields:  fields,
+		queue:   make(chan []byte, bufferLimit),
+		closing: make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if err := f.connect(); err != nil {
+		return nil, err
+	}
+	go f.run()
+	return f, nil
+}
+
+// ValidateLogOpt checks the options of the fluentd driver.
+func ValidateLogOpt(cfg map[string]string) error {
+	for key := range cfg {
+		switch key {
+		case "fluentd-address", "tag":
+		default:
+			return fmt.Errorf("unknown log opt %q for fluentd log driver", key)
+		}
+	}
+	_, err := parseAddress(cfg["fluentd-address"])
+	return err
+}
+
+// parseAddress returns the host:port fluentd listens on, filling the
+// defaults in for the parts address lacks.
+func parseAddress(address string) (string, error) {
+	if address == "" {
+		return net.JoinHostPort(defaultHost, strconv.Itoa(defaultPort)), nil
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		// No port
+		host, port = address, strconv.Itoa(defaultPort)
+	}
+	if host == "" {
+		host = defaultHost
+	}
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return "", fmt.Errorf("invalid fluentd-address %s: invalid port %s", address, port)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// connect dials fluentd.
+func (f *fluentd) connect() error {
+	conn, err := net.DialTimeout("tcp", f.address, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("fluentd: cannot connect to %s: %v", f.address, err)
+	}
+	f.conn = conn
+	return nil
+}
+
+func (f *fluentd) Log(msg *logger.Message) error {
+	record := make(map[string]string, len(f.fields)+2)
+	for k, v := range f.fields {
+		record[k] = v
+	}
+	record["log"] = string(msg.Line)
+	record["source"] = msg.Source
+
+	// Message mode of the forward protocol: [tag, time, record]
+	var buf bytes.Buffer
+	writeArrayHeader(&buf, 3)
+	writeString(&buf, f.tag)
+	writeUint(&buf, uint64(msg.Timestamp.Unix()))
+	writeStringMap(&buf, record)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return fmt.Errorf("fluentd: logger is closed")
+	}
+	select {
+	case f.queue <- buf.Bytes():
+		return nil
+	default:
+		return fmt.Errorf("fluentd: %s is unreachable and the buffer is full, dropping message", f.address)
+	}
+}
+
+// run writes the queued messages until the logger is closed. Once it is,
+// the remaining messages are written as long as fluentd can be reached,
+// and dropped otherwise.
+func (f *fluentd) run() {
+	defer close(f.stopped)
+	for data := range f.queue {
+		if !f.write(data) {
+			for range f.queue {
+			}
+		}
+	}
+	if f.conn != nil {
+		f.conn.Close()
+	}
+}
+
+// write writes data to fluentd, connecting again with an increasing delay
+// while it cannot be reached. It gives up and returns false if the logger
+// gets closed in the meantime.
+func (f *fluentd) write(data []byte) bool {
+	for delay := minRetryDelay; ; delay *= 2 {
+		err := f.tryWrite(data)
+		if err == nil {
+			return true
+		}
+		if delay == minRetryDelay {
+			logrus.Errorf("%v, buffering messages", err)
+		}
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+		select {
+		case <-f.closing:
+			logrus.Errorf("fluentd: dropping the messages not sent to %s", f.address)
+			return false
+		case <-time.After(delay):
+		}
+	}
+}
+
+// tryWrite writes data on the connection, opening it first if it was lost.
+func (f *fluentd) tryWrite(data []byte) error {
+	if f.conn == nil {
+		if err := f.connect(); err != nil {
+			return err
+		}
+	}
+	f.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := f.conn.Write(data); err != nil {
+		f.conn.Close()
+		f.conn = nil
+		return fmt.Errorf("fluentd: cannot write to %s: %v", f.address, err)
+	}
+	return nil
+}
+
+func (f *fluentd) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	close(f.closing)
+	close(f.queue)
+	f.mu.Unlock()
+
+	<-f.stopped
+	return nil
+}
+
+func (f *fluentd) Name() string {
+	return name
+}
+
+func (f *fluentd) GetReader() (io.Reader, error) {
+	return nil, logger.ReadLogsNotSupported
+}
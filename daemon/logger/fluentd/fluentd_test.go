@@ -0,0 +1,235 @@
+package fluentd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/daemon/logger"
+)
+
+// decode reads a value encoded by the msgpack.go functions.
+func decode(r *bufio.Reader) (interface{}, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	readUint := func(size int) (uint64, error) {
+		buf := make([]byte, 8)
+		if _, err := io.ReadFull(r, buf[8-size:]); err != nil {
+			return 0, err
+		}
+		return binary.BigEndian.Uint64(buf), nil
+	}
+
+	var n uint64
+	switch {
+	case b < 0x80:
+		return uint64(b), nil
+	case b&0xf0 == 0x80:
+		return decodeMap(r, uint64(b&0x0f))
+	case b&0xf0 == 0x90:
+		return decodeArray(r, uint64(b&0x0f))
+	case b&0xe0 == 0xa0:
+		return decodeString(r, uint64(b&0x1f))
+	case b == 0xcc, b == 0xcd, b == 0xce, b == 0xcf:
+		return readUint(1 << (b - 0xcc))
+	case b == 0xd9, b == 0xda, b == 0xdb:
+		if n, err = readUint(1 << (b - 0xd9)); err != nil {
+			return nil, err
+		}
+		return decodeString(r, n)
+	case b == 0xdc, b == 0xdd:
+		if n, err = readUint(2 << (b - 0xdc)); err != nil {
+			return nil, err
+		}
+		return decodeArray(r, n)
+	case b == 0xde, b == 0xdf:
+		if n, err = readUint(2 << (b - 0xde)); err != nil {
+			return nil, err
+		}
+		return decodeMap(r, n)
+	}
+	return nil, fmt.Errorf("unexpected msgpack type %#x", b)
+}
+
+func decodeString(r *bufio.Reader, n uint64) (interface{}, error) {
+	buf := make([]byte, n)
+	_, err := io.ReadFull(r, buf)
+	return string(buf), err
+}
+
+func decodeArray(r *bufio.Reader, n uint64) (interface{}, error) {
+	a := []interface{}{}
+	for i := uint64(0); i < n; i++ {
+		v, err := decode(r)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+func decodeMap(r *bufio.Reader, n uint64) (interface{}, error) {
+	m := map[string]interface{}{}
+	for i := uint64(0); i < n; i++ {
+		k, err := decode(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := decode(r)
+		if err != nil {
+			return nil, err
+		}
+		m[k.(string)] = v
+	}
+	return m, nil
+}
+
+func TestFluentd(t *testing.T) {
+	server, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	l, err := New(logger.Context{
+		Config:             map[string]string{"fluentd-address": server.Addr().String()},
+		ContainerID:        "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657",
+		ContainerName:      "/web",
+		ContainerImageID:   "9b9cb95443b5f846cd3c8cfa3f64e63b6ba68de2618a08875a119c81a8f96698",
+		ContainerImageName: "nginx",
+		ContainerLabels:    map[string]string{"com.example.team": "web", "log": "overridden"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	conn, err := server.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	long := fmt.Sprintf("%0300d", 0)
+	for _, line := range []string{"hello", long} {
+		if err := l.Log(&logger.Message{Line: []byte(line), Source: "stdout", Timestamp: time.Unix(1000, 0)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for _, line := range []string{"hello", long} {
+		msg, err := decode(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []interface{}{
+			"docker.a7317399f3f8",
+			uint64(1000),
+			map[string]interface{}{
+				"log":              line,
+				"source":           "stdout",
+				"container_id":     "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657",
+				"container_name":   "web",
+				"image_id":         "9b9cb95443b5f846cd3c8cfa3f64e63b6ba68de2618a08875a119c81a8f96698",
+				"image_name":       "nginx",
+				"com.example.team": "web",
+			},
+		}
+		if !reflect.DeepEqual(msg, expected) {
+			t.Fatalf("Expected %v, got %v", expected, msg)
+		}
+	}
+}
+
+func TestFluentdUnreachable(t *testing.T) {
+	server, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := server.Addr().String()
+
+	l, err := New(logger.Context{
+		Config:      map[string]string{"fluentd-address": address},
+		ContainerID: "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// The messages logged while fluentd is down are sent once it is back
+	conn, err := server.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	server.Close()
+
+	start := time.Now()
+	for _, line := range []string{"lost", "hello"} {
+		if err := l.Log(&logger.Message{Line: []byte(line), Source: "stdout", Timestamp: time.Unix(1000, 0)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected logging not to block while fluentd is down, took %v", elapsed)
+	}
+
+	if server, err = net.Listen("tcp", address); err != nil {
+		t.Skipf("Cannot listen on %s again: %v", address, err)
+	}
+	defer server.Close()
+	if conn, err = server.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The first message may have been written to the closed connection
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for {
+		msg, err := decode(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		record := msg.([]interface{})[2].(map[string]interface{})
+		if record["log"] == "hello" {
+			break
+		}
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	for address, expected := range map[string]string{
+		"":                "127.0.0.1:24224",
+		"fluentd":         "fluentd:24224",
+		"fluentd:24225":   "fluentd:24225",
+		":24225":          "127.0.0.1:24225",
+		"192.168.0.1:123": "192.168.0.1:123",
+	} {
+		addr, err := parseAddress(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr != expected {
+			t.Fatalf("Expected %s for %q, got %s", expected, address, addr)
+		}
+	}
+
+	if _, err := parseAddress("fluentd:port"); err == nil {
+		t.Fatal("Expected an error for an invalid port")
+	}
+	if err := ValidateLogOpt(map[string]string{"gelf-address": "udp://localhost:12201"}); err == nil {
+		t.Fatal("Expected an error for an unknown option")
+	}
+}
@@ -0,0 +1,90 @@
+package fluentd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+// The fluentd forward protocol is based on MessagePack. Only the types
+// needed for the messages sent by the driver are encoded here: arrays,
+// maps of strings, strings and positive integers.
+
+func writeArrayHeader(buf *bytes.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.WriteByte(0x90 | byte(n))
+	case n < 1<<16:
+		buf.WriteByte(0xdc)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdd)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func writeMapHeader(buf *bytes.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.WriteByte(0x80 | byte(n))
+	case n < 1<<16:
+		buf.WriteByte(0xde)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdf)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n < 1<<8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n < 1<<16:
+		buf.WriteByte(0xda)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdb)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.WriteString(s)
+}
+
+func writeUint(buf *bytes.Buffer, v uint64) {
+	switch {
+	case v < 1<<7:
+		buf.WriteByte(byte(v))
+	case v < 1<<8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(v))
+	case v < 1<<16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(v))
+	case v < 1<<32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(v))
+	default:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, v)
+	}
+}
+
+// writeStringMap encodes m with its keys sorted, so that the encoding of
+// a record does not depend on the order of the map.
+func writeStringMap(buf *bytes.Buffer, m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	writeMapHeader(buf, len(m))
+	for _, k := range keys {
+		writeString(buf, k)
+		writeString(buf, m[k])
+	}
+}
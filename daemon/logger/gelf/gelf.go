@@ -0,0 +1,331 @@
+// Package gelf provides the log driver for forwarding server logs to
+// endpoints that support the Graylog Extended Log Format.
+package gelf
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/logger"
+)
+
+const name = "gelf"
+
+const (
+	// Largest UDP datagram sent, chunks included, which fits in the
+	// usual MTU.
+	chunkSize = 1420
+	// Header of each chunk: magic bytes, message ID, sequence number and
+	// number of chunks.
+	chunkHeaderLen = 12
+	// GELF servers drop the messages made of more chunks.
+	maxChunks = 128
+
+	// Syslog severity levels of the messages
+	levelErr  = 3
+	levelInfo = 6
+
+	dialTimeout  = 5 * time.Second
+	writeTimeout = 5 * time.Second
+)
+
+var (
+	chunkMagic = []byte{0x1e, 0x0f}
+
+	// Characters allowed in the names of additional fields
+	invalidFieldChars = regexp.MustCompile(`[^\w\.\-]`)
+)
+
+type gelfLogger struct {
+	network          string // "udp" or "tcp"
+	address          string
+	compressionType  string
+	compressionLevel int
+
+	// fields are the additional fields of every message: the container
+	// and its labels, the tag and the host.
+	fields   map[string]interface{}
+	hostname string
+
+	mu     sync.Mutex // protects conn and closed
+	conn   net.Conn
+	closed bool
+}
+
+func init() {
+	if err := logger.RegisterLogDriver(name, New); err != nil {
+		logrus.Fatal(err)
+	}
+	if err := logger.RegisterLogOptValidator(name, ValidateLogOpt); err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+// New creates a gelf logger sending the messages of the container to
+// the gelf-address given in the log options.
+func New(ctx logger.Context) (logger.Logger, error) {
+	if err := ValidateLogOpt(ctx.Config); err != nil {
+		return nil, err
+	}
+	addr, err := parseAddress(ctx.Config["gelf-address"])
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, err := ctx.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	tag, err := ctx.Tag("{{.ID}}")
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{
+		"_container_id":   ctx.FullID(),
+		"_container_name": ctx.Name(),
+		"_image_id":       ctx.ImageFullID(),
+		"_image_name":     ctx.ImageName(),
+		"_tag":            tag,
+	}
+	for k, v := range ctx.ContainerLabels {
+		field := "_" + invalidFieldChars.ReplaceAllString(k, "_")
+		if _, exists := fields[field]; exists || field == "_id" {
+			continue
+		}
+		fields[field] = v
+	}
+
+	l := &gelfLogger{
+		network:          addr.Scheme,
+		address:          addr.Host,
+		compressionType:  "gzip",
+		compressionLevel: flate.BestSpeed,
+		fields:           fields,
+		hostname:         hostname,
+	}
+	if t, ok := ctx.Config["gelf-compression-type"]; ok {
+		l.compressionType = t
+	}
+	if s, ok := ctx.Config["gelf-compression-level"]; ok {
+		l.compressionLevel, _ = strconv.Atoi(s)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.connect(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// ValidateLogOpt checks the options of the gelf driver.
+func ValidateLogOpt(cfg map[string]string) error {
+	for key, value := range cfg {
+		switch key {
+		case "gelf-address", "tag":
+		case "gelf-compression-type":
+			switch value {
+			case "gzip", "zlib", "none":
+			default:
+				return fmt.Errorf("unknown value %q for log opt %q for gelf log driver, must be gzip, zlib or none", value, key)
+			}
+		case "gelf-compression-level":
+			level, err := strconv.Atoi(value)
+			if err != nil || level < flate.DefaultCompression || level > flate.BestCompression {
+				return fmt.Errorf("invalid value %q for log opt %q for gelf log driver, must be between %d and %d", value, key, flate.DefaultCompression, flate.BestCompression)
+			}
+		default:
+			return fmt.Errorf("unknown log opt %q for gelf log driver", key)
+		}
+	}
+	_, err := parseAddress(cfg["gelf-address"])
+	return err
+}
+
+// parseAddress checks that address is a udp://host:port or tcp://host:port
+// URL.
+func parseAddress(address string) (*url.URL, error) {
+	if address == "" {
+		return nil, fmt.Errorf("gelf-address is a required parameter for gelf log driver")
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gelf-address %q: %v", address, err)
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return nil, fmt.Errorf("gelf-address %q must start with udp:// or tcp://", address)
+	}
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		return nil, fmt.Errorf("gelf-address %q must be in the form %s://host:port", address, u.Scheme)
+	}
+	return u, nil
+}
+
+// connect dials the GELF server. The lock must be held.
+func (l *gelfLogger) connect() error {
+	conn, err := net.DialTimeout(l.network, l.address, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("gelf: cannot connect to %s://%s: %v", l.network, l.address, err)
+	}
+	l.conn = conn
+	return nil
+}
+
+func (l *gelfLogger) Log(msg *logger.Message) error {
+	level := levelInfo
+	if msg.Source == "stderr" {
+		level = levelErr
+	}
+
+	m := map[string]interface{}{
+		"version":       "1.1",
+		"host":          l.hostname,
+		"short_message": string(msg.Line),
+		"timestamp":     float64(msg.Timestamp.UnixNano()) / float64(time.Second),
+		"level":         level,
+		"_source":       msg.Source,
+	}
+	for k, v := range l.fields {
+		m[k] = v
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return fmt.Errorf("gelf: logger is closed")
+	}
+	if l.network == "tcp" {
+		return l.writeTCP(data)
+	}
+	return l.writeUDP(data)
+}
+
+// writeTCP sends a message over the stream, where messages are delimited
+// by a null byte and can't be compressed. The connection is opened again
+// once if it was lost.
+func (l *gelfLogger) writeTCP(data []byte) error {
+	frame := append(data, 0)
+	for retry := 0; ; retry++ {
+		if l.conn == nil {
+			if err := l.connect(); err != nil {
+				return err
+			}
+		}
+		l.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		_, err := l.conn.Write(frame)
+		if err == nil {
+			return nil
+		}
+		l.conn.Close()
+		l.conn = nil
+		if retry > 0 {
+			return err
+		}
+	}
+}
+
+// writeUDP sends a message in one datagram, or in chunks if it is too
+// large.
+func (l *gelfLogger) writeUDP(data []byte) error {
+	data, err := l.compress(data)
+	if err != nil {
+		return err
+	}
+
+	if len(data) <= chunkSize {
+		_, err := l.conn.Write(data)
+		return err
+	}
+
+	chunkData := chunkSize - chunkHeaderLen
+	count := (len(data) + chunkData - 1) / chunkData
+	if count > maxChunks {
+		return fmt.Errorf("gelf: message of %d bytes is too large to be sent in %d chunks", len(data), maxChunks)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	chunk := make([]byte, 0, chunkSize)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * chunkData
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk = append(chunk[:0], chunkMagic...)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, data[i*chunkData:end]...)
+		if _, err := l.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *gelfLogger) compress(data []byte) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+		err error
+	)
+	switch l.compressionType {
+	case "none":
+		return data, nil
+	case "zlib":
+		w, err = zlib.NewWriterLevel(&buf, l.compressionLevel)
+	default:
+		w, err = gzip.NewWriterLevel(&buf, l.compressionLevel)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (l *gelfLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+	if l.conn == nil {
+		return nil
+	}
+	err := l.conn.Close()
+	l.conn = nil
+	return err
+}
+
+func (l *gelfLogger) Name() string {
+	return name
+}
+
+func (l *gelfLogger) GetReader() (io.Reader, error) {
+	return nil, logger.ReadLogsNotSupported
+}
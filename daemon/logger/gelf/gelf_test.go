@@ -0,0 +1,200 @@
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/daemon/logger"
+)
+
+func testContext(address string, config map[string]string) logger.Context {
+	config["gelf-address"] = address
+	return logger.Context{
+		Config:             config,
+		ContainerID:        "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657",
+		ContainerName:      "/web",
+		ContainerImageID:   "9b9cb95443b5f846cd3c8cfa3f64e63b6ba68de2618a08875a119c81a8f96698",
+		ContainerImageName: "nginx",
+		ContainerLabels:    map[string]string{"com.example.team": "web", "id": "reserved"},
+	}
+}
+
+func readDatagram(t *testing.T, conn *net.UDPConn) []byte {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 65536)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n]
+}
+
+func checkMessage(t *testing.T, data []byte, line string, level float64) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"version":           "1.1",
+		"short_message":     line,
+		"level":             level,
+		"_container_id":     "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657",
+		"_container_name":   "web",
+		"_image_id":         "9b9cb95443b5f846cd3c8cfa3f64e63b6ba68de2618a08875a119c81a8f96698",
+		"_image_name":       "nginx",
+		"_tag":              "a7317399f3f8",
+		"_com.example.team": "web",
+	}
+	for k, v := range expected {
+		if m[k] != v {
+			t.Fatalf("Expected %s to be %v, got %v", k, v, m[k])
+		}
+	}
+	if _, exists := m["_id"]; exists {
+		t.Fatal("Expected the reserved _id field not to be set")
+	}
+	if m["timestamp"] != 1.5 {
+		t.Fatalf("Expected the timestamp 1.5, got %v", m["timestamp"])
+	}
+}
+
+func TestGelfUDP(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	l, err := New(testContext("udp://"+server.LocalAddr().String(), map[string]string{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ts := time.Unix(1, int64(500*time.Millisecond))
+	if err := l.Log(&logger.Message{Line: []byte("hello"), Source: "stderr", Timestamp: ts}); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(readDatagram(t, server)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMessage(t, data, "hello", levelErr)
+}
+
+func TestGelfUDPChunked(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	l, err := New(testContext("udp://"+server.LocalAddr().String(), map[string]string{"gelf-compression-type": "none"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	line := strings.Repeat("x", 3*chunkSize)
+	ts := time.Unix(1, int64(500*time.Millisecond))
+	if err := l.Log(&logger.Message{Line: []byte(line), Source: "stdout", Timestamp: ts}); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		data  []byte
+		id    []byte
+		count = -1
+	)
+	for i := 0; i != count; i++ {
+		chunk := readDatagram(t, server)
+		if len(chunk) > chunkSize || !bytes.Equal(chunk[:2], chunkMagic) {
+			t.Fatalf("Invalid chunk header %v", chunk[:chunkHeaderLen])
+		}
+		if id == nil {
+			id, count = chunk[2:10], int(chunk[11])
+		}
+		if !bytes.Equal(chunk[2:10], id) || int(chunk[10]) != i || int(chunk[11]) != count {
+			t.Fatalf("Unexpected chunk header %v for chunk %d of %d", chunk[:chunkHeaderLen], i, count)
+		}
+		data = append(data, chunk[chunkHeaderLen:]...)
+	}
+	if count != 4 {
+		t.Fatalf("Expected 4 chunks, got %d", count)
+	}
+	checkMessage(t, data, line, levelInfo)
+}
+
+func TestGelfTCP(t *testing.T) {
+	server, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	l, err := New(testContext("tcp://"+server.Addr().String(), map[string]string{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	conn, err := server.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ts := time.Unix(1, int64(500*time.Millisecond))
+	for _, line := range []string{"one", "two"} {
+		if err := l.Log(&logger.Message{Line: []byte(line), Source: "stdout", Timestamp: ts}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for _, line := range []string{"one", "two"} {
+		frame, err := r.ReadBytes(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkMessage(t, frame[:len(frame)-1], line, levelInfo)
+	}
+}
+
+func TestGelfValidateLogOpt(t *testing.T) {
+	for _, cfg := range []map[string]string{
+		{},
+		{"gelf-address": "localhost:12201"},
+		{"gelf-address": "http://localhost:12201"},
+		{"gelf-address": "udp://localhost"},
+		{"gelf-address": "udp://localhost:12201", "gelf-compression-type": "lz4"},
+		{"gelf-address": "udp://localhost:12201", "gelf-compression-level": "10"},
+		{"gelf-address": "udp://localhost:12201", "syslog-facility": "daemon"},
+	} {
+		if err := ValidateLogOpt(cfg); err == nil {
+			t.Fatalf("Expected an error for %v", cfg)
+		}
+	}
+
+	cfg := map[string]string{
+		"gelf-address":           "udp://localhost:12201",
+		"gelf-compression-type":  "zlib",
+		"gelf-compression-level": "9",
+		"tag":                    "{{.Name}}",
+	}
+	if err := ValidateLogOpt(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
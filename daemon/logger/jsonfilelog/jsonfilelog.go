@@ -2,14 +2,18 @@ package jsonfilelog
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"sync"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/daemon/logger"
 	"github.com/docker/docker/pkg/jsonlog"
+	"github.com/docker/docker/pkg/tailfile"
 	"github.com/docker/docker/pkg/timeutils"
+	"github.com/docker/docker/pkg/units"
 )
 
 const (
@@ -19,9 +23,12 @@ const (
 // JSONFileLogger is Logger implementation for default docker logging:
 // JSON objects to file
 type JSONFileLogger struct {
-	buf *bytes.Buffer
-	f   *os.File   // store for closing
-	mu  sync.Mutex // protects buffer
+	buf         *bytes.Buffer
+	f           *os.File   // store for closing
+	mu          sync.Mutex // protects buffer and rotation
+	capacity    int64      // maximum size of the log file, -1 for no limit
+	maxFiles    int        // number of log files kept, including the current one
+	currentSize int64      // size of the current log file
 
 	ctx logger.Context
 }
@@ -30,21 +37,74 @@ func init() {
 	if err := logger.RegisterLogDriver(Name, New); err != nil {
 		logrus.Fatal(err)
 	}
+	if err := logger.RegisterLogOptValidator(Name, ValidateLogOpt); err != nil {
+		logrus.Fatal(err)
+	}
 }
 
 // New creates new JSONFileLogger which writes to filename
 func New(ctx logger.Context) (logger.Logger, error) {
+	capacity, maxFiles, err := parseLogOpts(ctx.Config)
+	if err != nil {
+		return nil, err
+	}
 	log, err := os.OpenFile(ctx.LogPath, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
 	if err != nil {
 		return nil, err
 	}
+	size, err := log.Seek(0, os.SEEK_END)
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
 	return &JSONFileLogger{
-		f:   log,
-		buf: bytes.NewBuffer(nil),
-		ctx: ctx,
+		f:           log,
+		buf:         bytes.NewBuffer(nil),
+		capacity:    capacity,
+		maxFiles:    maxFiles,
+		currentSize: size,
+		ctx:         ctx,
 	}, nil
 }
 
+// ValidateLogOpt checks the max-size and max-file options of the driver.
+func ValidateLogOpt(cfg map[string]string) error {
+	_, _, err := parseLogOpts(cfg)
+	return err
+}
+
+// parseLogOpts returns the maximum size of a log file, -1 if it is not
+// limited, and the number of log files to keep.
+func parseLogOpts(cfg map[string]string) (int64, int, error) {
+	var (
+		capacity int64 = -1
+		maxFiles       = 1
+		err      error
+	)
+	if s, ok := cfg["max-size"]; ok {
+		capacity, err = units.FromHumanSize(s)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Invalid max-size for the %s log driver: %s", Name, s)
+		}
+		if capacity <= 0 {
+			return 0, 0, fmt.Errorf("max-size for the %s log driver must be positive", Name)
+		}
+	}
+	if s, ok := cfg["max-file"]; ok {
+		maxFiles, err = strconv.Atoi(s)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Invalid max-file for the %s log driver: %s", Name, s)
+		}
+		if maxFiles < 1 {
+			return 0, 0, fmt.Errorf("max-file for the %s log driver cannot be less than 1", Name)
+		}
+		if capacity == -1 && maxFiles > 1 {
+			return 0, 0, fmt.Errorf("max-file for the %s log driver requires max-size to be set", Name)
+		}
+	}
+	return capacity, maxFiles, nil
+}
+
 // Log converts logger.Message to jsonlog.JSONLog and serializes it to file
 func (l *JSONFileLogger) Log(msg *logger.Message) error {
 	l.mu.Lock()
@@ -59,7 +119,16 @@ func (l *JSONFileLogger) Log(msg *logger.Message) error {
 		return err
 	}
 	l.buf.WriteByte('\n')
-	_, err = l.buf.WriteTo(l.f)
+
+	if l.capacity != -1 && l.currentSize > 0 && l.currentSize+int64(l.buf.Len()) > l.capacity {
+		if err := l.rotate(); err != nil {
+			l.buf = bytes.NewBuffer(nil)
+			return err
+		}
+	}
+
+	n, err := l.buf.WriteTo(l.f)
+	l.currentSize += n
 	if err != nil {
 		// this buffer is screwed, replace it with another to avoid races
 		l.buf = bytes.NewBuffer(nil)
@@ -68,8 +137,149 @@ func (l *JSONFileLogger) Log(msg *logger.Message) error {
 	return nil
 }
 
+// rotate shifts the rotated log files, dropping the oldest one, renames
+// the current log file to LogPath.1 and opens a new one. Without rotated
+// files, the current log file is truncated. The lock must be held.
+func (l *JSONFileLogger) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+
+	name := l.ctx.LogPath
+	if l.maxFiles > 1 {
+		for i := l.maxFiles - 1; i > 1; i-- {
+			if err := rename(rotatedName(name, i-1), rotatedName(name, i)); err != nil {
+				return err
+			}
+		}
+		if err := rename(name, rotatedName(name, 1)); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	l.f = f
+	l.currentSize = 0
+	return nil
+}
+
+func rotatedName(name string, i int) string {
+	return name + "." + strconv.Itoa(i)
+}
+
+// rename is os.Rename which ignores a missing oldpath.
+func rename(oldpath, newpath string) error {
+	if err := os.Rename(oldpath, newpath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// GetReader returns a reader over the whole log, from the oldest rotated
+// file to the current one.
 func (l *JSONFileLogger) GetReader() (io.Reader, error) {
-	return os.Open(l.ctx.LogPath)
+	return l.ReadLogs(-1)
+}
+
+// ReadLogs returns a reader over the last lines of the log, all of them if
+// lines is negative, which can span the rotated log files.
+func (l *JSONFileLogger) ReadLogs(lines int) (io.ReadCloser, error) {
+	files, err := openLogFiles(l.ctx.LogPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if lines < 0 {
+		readers := make([]io.Reader, len(files))
+		for i, f := range files {
+			readers[i] = f
+		}
+		return &logReader{Reader: io.MultiReader(readers...), files: files}, nil
+	}
+	defer closeFiles(files)
+
+	var tail [][]byte
+	for i := len(files) - 1; i >= 0 && len(tail) < lines; i-- {
+		ls, err := tailfile.TailFile(files[i], lines-len(tail))
+		if err != nil {
+			return nil, err
+		}
+		tail = append(ls, tail...)
+	}
+	buf := bytes.NewBuffer(nil)
+	for _, line := range tail {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return &logReader{Reader: buf}, nil
+}
+
+// openLogFiles opens the rotated log files, from the oldest one, followed
+// by the current log file. The files are opened again if they are rotated
+// in the meantime.
+func openLogFiles(name string) ([]*os.File, error) {
+	for {
+		files, rotated, err := tryOpenLogFiles(name)
+		if err != nil {
+			return nil, err
+		}
+		if !rotated {
+			return files, nil
+		}
+		closeFiles(files)
+	}
+}
+
+func tryOpenLogFiles(name string) (files []*os.File, rotated bool, err error) {
+	current, err := os.Open(name)
+	if err != nil {
+		return nil, false, err
+	}
+	files = []*os.File{current}
+	for i := 1; ; i++ {
+		f, err := os.Open(rotatedName(name, i))
+		if err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+			closeFiles(files)
+			return nil, false, err
+		}
+		files = append([]*os.File{f}, files...)
+	}
+
+	// The current log file was rotated if the name now refers to a new one
+	opened, err := current.Stat()
+	if err != nil {
+		closeFiles(files)
+		return nil, false, err
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		closeFiles(files)
+		return nil, false, err
+	}
+	return files, !os.SameFile(opened, fi), nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// logReader reads the log files and closes them all on Close.
+type logReader struct {
+	io.Reader
+	files []*os.File
+}
+
+func (r *logReader) Close() error {
+	closeFiles(r.files)
+	return nil
 }
 
 func (l *JSONFileLogger) LogPath() string {
@@ -78,6 +288,8 @@ func (l *JSONFileLogger) LogPath() string {
 
 // Close closes underlying file
 func (l *JSONFileLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	return l.f.Close()
 }
 
@@ -1,9 +1,11 @@
 package jsonfilelog
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -51,6 +53,106 @@ func TestJSONFileLogger(t *testing.T) {
 	}
 }
 
+func TestJSONFileLoggerWithOpts(t *testing.T) {
+	cid := "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657"
+	tmp, err := ioutil.TempDir("", "docker-logger-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	filename := filepath.Join(tmp, "container.log")
+	l, err := New(logger.Context{
+		ContainerID: cid,
+		LogPath:     filename,
+		Config:      map[string]string{"max-file": "3", "max-size": "1k"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for i := 0; i < 50; i++ {
+		if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte(fmt.Sprintf("line%02d", i)), Source: "src1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each entry is 64 bytes long, so 15 of them fit in a file, and the
+	// first 15 entries were dropped with the oldest file
+	for _, f := range []struct {
+		name  string
+		first int
+		count int
+	}{
+		{filename + ".2", 15, 15},
+		{filename + ".1", 30, 15},
+		{filename, 45, 5},
+	} {
+		res, err := ioutil.ReadFile(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(res), "\n"), "\n")
+		if len(lines) != f.count {
+			t.Fatalf("Expected %d entries in %s, got %d", f.count, f.name, len(lines))
+		}
+		if expected := fmt.Sprintf(`{"log":"line%02d\n"`, f.first); !strings.HasPrefix(lines[0], expected) {
+			t.Fatalf("Expected %s to start with %s, got %s", f.name, expected, lines[0])
+		}
+	}
+	if _, err := os.Stat(filename + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("Expected only 3 log files, got %s.3: %v", filename, err)
+	}
+
+	jl := l.(*JSONFileLogger)
+	for _, tail := range []struct {
+		lines    int
+		expected int
+		first    int
+	}{
+		{-1, 35, 15},
+		{3, 3, 47},
+		{10, 10, 40},
+		{20, 20, 30},
+		{100, 35, 15},
+	} {
+		r, err := jl.ReadLogs(tail.lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(res), "\n"), "\n")
+		if len(lines) != tail.expected {
+			t.Fatalf("Expected %d entries for %d lines, got %d", tail.expected, tail.lines, len(lines))
+		}
+		for i, line := range lines {
+			if expected := fmt.Sprintf(`{"log":"line%02d\n"`, tail.first+i); !strings.HasPrefix(line, expected) {
+				t.Fatalf("Expected entry %d to start with %s, got %s", i, expected, line)
+			}
+		}
+	}
+}
+
+func TestJSONFileLoggerInvalidOpts(t *testing.T) {
+	for _, cfg := range []map[string]string{
+		{"max-size": "lots"},
+		{"max-size": "0"},
+		{"max-size": "1k", "max-file": "0"},
+		{"max-file": "2"},
+	} {
+		if err := ValidateLogOpt(cfg); err == nil {
+			t.Fatalf("Expected an error for %v", cfg)
+		}
+	}
+	if err := ValidateLogOpt(map[string]string{"max-size": "10m", "max-file": "5"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func BenchmarkJSONFileLogger(b *testing.B) {
 	cid := "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657"
 	tmp, err := ioutil.TempDir("", "docker-logger-")
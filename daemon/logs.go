@@ -1,12 +1,10 @@
 package daemon
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
-	"os"
 	"strconv"
 	"syscall"
 	"time"
@@ -15,7 +13,6 @@ import (
 	"github.com/docker/docker/daemon/logger/jsonfilelog"
 	"github.com/docker/docker/pkg/jsonlog"
 	"github.com/docker/docker/pkg/stdcopy"
-	"github.com/docker/docker/pkg/tailfile"
 	"github.com/docker/docker/pkg/timeutils"
 )
 
@@ -62,33 +59,26 @@ func (daemon *Daemon) ContainerLogs(name string, config *ContainerLogsConfig) er
 		return fmt.Errorf("\"logs\" endpoint is supported only for \"json-file\" logging driver")
 	}
 	logDriver, err := container.getLogger()
-	cLog, err := logDriver.GetReader()
 	if err != nil {
-		logrus.Errorf("Error reading logs: %s", err)
-	} else {
-		// json-file driver
-		if config.Tail != "all" {
-			var err error
-			lines, err = strconv.Atoi(config.Tail)
-			if err != nil {
-				logrus.Errorf("Failed to parse tail %s, error: %v, show all logs", config.Tail, err)
-				lines = -1
-			}
+		return err
+	}
+	defer logDriver.Close()
+
+	if config.Tail != "all" {
+		lines, err = strconv.Atoi(config.Tail)
+		if err != nil {
+			logrus.Errorf("Failed to parse tail %s, error: %v, show all logs", config.Tail, err)
+			lines = -1
 		}
+	}
 
-		if lines != 0 {
-			if lines > 0 {
-				f := cLog.(*os.File)
-				ls, err := tailfile.TailFile(f, lines)
-				if err != nil {
-					return err
-				}
-				tmp := bytes.NewBuffer([]byte{})
-				for _, l := range ls {
-					fmt.Fprintf(tmp, "%s\n", l)
-				}
-				cLog = tmp
-			}
+	if lines != 0 {
+		// The log can span the files rotated by the json-file driver
+		cLog, err := logDriver.(*jsonfilelog.JSONFileLogger).ReadLogs(lines)
+		if err != nil {
+			logrus.Errorf("Error reading logs: %s", err)
+		} else {
+			defer cLog.Close()
 
 			dec := json.NewDecoder(cLog)
 			l := &jsonlog.JSONLog{}
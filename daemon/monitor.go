@@ -12,7 +12,15 @@ import (
 	"github.com/docker/docker/runconfig"
 )
 
-const defaultTimeIncrement = 100
+const (
+	// defaultRestartDelay is the delay before the first restart of a
+	// container, when its restart policy does not set one
+	defaultRestartDelay = 100 * time.Millisecond
+
+	// defaultMaxRestartDelay is the ceiling of the delay between restarts,
+	// when the restart policy does not set one
+	defaultMaxRestartDelay = time.Minute
+)
 
 // containerMonitor monitors the execution of a container's main process.
 // If a restart policy is specified for the container the monitor will ensure that the
@@ -40,16 +48,19 @@ type containerMonitor struct {
 	startSignal chan struct{}
 
 	// stopChan is used to signal to the monitor whenever there is a wait for the
-	// next restart so that the timeIncrement is not honored and the user is not
+	// next restart so that the restartDelay is not honored and the user is not
 	// left waiting for nothing to happen during this time
 	stopChan chan struct{}
 
-	// timeIncrement is the amount of time to wait between restarts
-	// this is in milliseconds
-	timeIncrement int
+	// restartDelay is the amount of time to wait before the next restart
+	restartDelay time.Duration
 
 	// lastStartTime is the time which the monitor last exec'd the container's process
 	lastStartTime time.Time
+
+	// restoring is set until the container left running by the previous
+	// daemon in live restore mode exits
+	restoring bool
 }
 
 // newContainerMonitor returns an initialized containerMonitor for the provided container
@@ -58,7 +69,7 @@ func newContainerMonitor(container *Container, policy runconfig.RestartPolicy) *
 	return &containerMonitor{
 		container:     container,
 		restartPolicy: policy,
-		timeIncrement: defaultTimeIncrement,
+		restartDelay:  policy.Delay,
 		stopChan:      make(chan struct{}),
 		startSignal:   make(chan struct{}),
 	}
@@ -79,6 +90,14 @@ func (m *containerMonitor) ExitOnNext() {
 	m.mux.Unlock()
 }
 
+// setRestartPolicy replaces the restart policy applied the next time the
+// container exits
+func (m *containerMonitor) setRestartPolicy(policy runconfig.RestartPolicy) {
+	m.mux.Lock()
+	m.restartPolicy = policy
+	m.mux.Unlock()
+}
+
 // Close closes the container's resources such as networking allocations and
 // unmounts the contatiner's root filesystem
 func (m *containerMonitor) Close() error {
@@ -117,11 +136,18 @@ func (m *containerMonitor) Start() error {
 		m.Close()
 	}()
 
-	// reset the restart count
-	m.container.RestartCount = -1
+	// reset the restart count, unless the container is still running
+	if !m.restoring {
+		m.container.RestartCount = -1
+	}
 
 	for {
-		m.container.RestartCount++
+		if !m.restoring {
+			m.container.RestartCount++
+			if m.container.RestartCount > 0 {
+				m.container.LastRestartedAt = time.Now().UTC()
+			}
+		}
 
 		if err := m.container.startLogging(); err != nil {
 			m.resetContainer(false)
@@ -131,14 +157,24 @@ func (m *containerMonitor) Start() error {
 
 		pipes := execdriver.NewPipes(m.container.stdin, m.container.stdout, m.container.stderr, m.container.Config.OpenStdin)
 
-		m.container.LogEvent("start")
+		restored := m.restoring
+		if restored {
+			m.lastStartTime = m.container.StartedAt
+			exitStatus, err = m.container.daemon.Restore(m.container, pipes, m.callback)
+			m.restoring = false
+		} else {
+			m.container.LogEvent("start")
 
-		m.lastStartTime = time.Now()
+			m.lastStartTime = time.Now()
+			m.container.LiveRestore = m.container.command.LiveRestore
 
-		if exitStatus, err = m.container.daemon.Run(m.container, pipes, m.callback); err != nil {
+			exitStatus, err = m.container.daemon.Run(m.container, pipes, m.callback)
+		}
+		if err != nil {
 			// if we receive an internal error from the initial start of a container then lets
-			// return it instead of entering the restart loop
-			if m.container.RestartCount == 0 {
+			// return it instead of entering the restart loop. A container which could not
+			// be restored exited while the daemon was down: its restart policy applies.
+			if m.container.RestartCount == 0 && !restored {
 				m.container.ExitCode = -1
 				m.resetContainer(false)
 
@@ -151,6 +187,10 @@ func (m *containerMonitor) Start() error {
 		// here container.Lock is already lost
 		afterRun = true
 
+		m.container.Lock()
+		m.container.stopHealthMonitor()
+		m.container.Unlock()
+
 		m.resetMonitor(err == nil && exitStatus.ExitCode == 0)
 
 		if m.shouldRestart(exitStatus.ExitCode) {
@@ -158,7 +198,7 @@ func (m *containerMonitor) Start() error {
 			if exitStatus.OOMKilled {
 				m.container.LogEvent("oom")
 			}
-			m.container.LogEvent("die")
+			m.container.logDieEvent(exitStatus.ExitCode)
 			m.resetContainer(true)
 
 			// sleep with a small time increment between each restart to help avoid issues cased by quickly
@@ -175,25 +215,53 @@ func (m *containerMonitor) Start() error {
 		if exitStatus.OOMKilled {
 			m.container.LogEvent("oom")
 		}
-		m.container.LogEvent("die")
+		m.container.logDieEvent(exitStatus.ExitCode)
 		m.resetContainer(true)
 		return err
 	}
 }
 
+// restartDelays returns the delay before the first restart of a container
+// with policy and the ceiling of the delay between its restarts.
+func restartDelays(policy runconfig.RestartPolicy) (initial, max time.Duration) {
+	initial, max = policy.Delay, policy.MaximumDelay
+	if initial <= 0 {
+		initial = defaultRestartDelay
+	}
+	if max <= 0 {
+		max = defaultMaxRestartDelay
+	}
+	if max < initial {
+		max = initial
+	}
+	return initial, max
+}
+
+// nextRestartDelay returns the delay to wait before the next restart of a
+// container with policy, which waited for current before its last start and
+// ran for executionTime.
+func nextRestartDelay(policy runconfig.RestartPolicy, current, executionTime time.Duration) time.Duration {
+	initial, max := restartDelays(policy)
+	if executionTime > 10*time.Second || current < initial {
+		return initial
+	}
+	// otherwise we need to increment the amount of time we wait before restarting
+	// the process.  We will build up by multiplying the delay by 2
+	if next := current * 2; next < max {
+		return next
+	}
+	return max
+}
+
 // resetMonitor resets the stateful fields on the containerMonitor based on the
 // previous runs success or failure.  Regardless of success, if the container had
-// an execution time of more than 10s then reset the timer back to the default
+// an execution time of more than 10s then reset the delay back to the one of the
+// restart policy
 func (m *containerMonitor) resetMonitor(successful bool) {
-	executionTime := time.Now().Sub(m.lastStartTime).Seconds()
-
-	if executionTime > 10 {
-		m.timeIncrement = defaultTimeIncrement
-	} else {
-		// otherwise we need to increment the amount of time we wait before restarting
-		// the process.  We will build up by multiplying the increment by 2
-		m.timeIncrement *= 2
-	}
+	m.mux.Lock()
+	policy := m.restartPolicy
+	m.mux.Unlock()
+	m.restartDelay = nextRestartDelay(policy, m.restartDelay, time.Now().Sub(m.lastStartTime))
 
 	// the container exited successfully so we need to reset the failure counter
 	if successful {
@@ -203,11 +271,11 @@ func (m *containerMonitor) resetMonitor(successful bool) {
 	}
 }
 
-// waitForNextRestart waits with the default time increment to restart the container unless
+// waitForNextRestart waits for the restart delay to restart the container unless
 // a user or docker asks for the container to be stopped
 func (m *containerMonitor) waitForNextRestart() {
 	select {
-	case <-time.After(time.Duration(m.timeIncrement) * time.Millisecond):
+	case <-time.After(m.restartDelay):
 	case <-m.stopChan:
 	}
 }
@@ -224,7 +292,7 @@ func (m *containerMonitor) shouldRestart(exitCode int) bool {
 	}
 
 	switch {
-	case m.restartPolicy.IsAlways():
+	case m.restartPolicy.IsAlways(), m.restartPolicy.IsUnlessStopped():
 		return true
 	case m.restartPolicy.IsOnFailure():
 		// the default value of 0 for MaximumRetryCount means that we will not enforce a maximum count
@@ -252,7 +320,13 @@ func (m *containerMonitor) callback(processConfig *execdriver.ProcessConfig, pid
 		}
 	}
 
+	startedAt := m.container.StartedAt
 	m.container.setRunning(pid)
+	if m.restoring {
+		// the container kept running while the daemon was down
+		m.container.StartedAt = startedAt
+	}
+	m.container.initHealthMonitor()
 
 	// signal that the process has started
 	// close channel only if not closed
@@ -0,0 +1,47 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/runconfig"
+)
+
+func TestNextRestartDelay(t *testing.T) {
+	policy := runconfig.RestartPolicy{Name: "always"}
+	delay := time.Duration(0)
+	for _, expected := range []time.Duration{
+		defaultRestartDelay,
+		2 * defaultRestartDelay,
+		4 * defaultRestartDelay,
+	} {
+		if delay = nextRestartDelay(policy, delay, time.Second); delay != expected {
+			t.Fatalf("Expected a delay of %s, got %s", expected, delay)
+		}
+	}
+	if delay := nextRestartDelay(policy, 50*time.Second, time.Second); delay != defaultMaxRestartDelay {
+		t.Fatalf("Expected the default maximum delay, got %s", delay)
+	}
+	if delay := nextRestartDelay(policy, 30*time.Second, time.Minute); delay != defaultRestartDelay {
+		t.Fatalf("Expected the delay to be reset after a long run, got %s", delay)
+	}
+
+	policy = runconfig.RestartPolicy{Name: "always", Delay: time.Second, MaximumDelay: 3 * time.Second}
+	delay = 0
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		if delay = nextRestartDelay(policy, delay, time.Second); delay != expected {
+			t.Fatalf("Expected a delay of %s, got %s", expected, delay)
+		}
+	}
+}
+
+func TestShouldRestartUnlessStopped(t *testing.T) {
+	m := newContainerMonitor(&Container{}, runconfig.RestartPolicy{Name: "unless-stopped"})
+	if !m.shouldRestart(0) || !m.shouldRestart(1) {
+		t.Fatal("Expected the container to be restarted whatever its exit code")
+	}
+	m.ExitOnNext()
+	if m.shouldRestart(1) {
+		t.Fatal("Expected a stopped container not to be restarted")
+	}
+}
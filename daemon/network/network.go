@@ -0,0 +1,11 @@
+package network
+
+// Network is a user-defined network as persisted by the daemon.
+type Network struct {
+	ID      string
+	Name    string
+	Driver  string
+	Bridge  string
+	Subnet  string
+	Gateway string
+}
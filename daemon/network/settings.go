@@ -16,4 +16,16 @@ type Settings struct {
 	PortMapping            map[string]map[string]string // Deprecated
 	Ports                  nat.PortMap
 	HairpinMode            bool
+	Networks               map[string]*EndpointSettings `json:",omitempty"`
+}
+
+// EndpointSettings describes the attachment of a container to a
+// user-defined network.
+type EndpointSettings struct {
+	NetworkID   string
+	IPAddress   string
+	IPPrefixLen int
+	Gateway     string
+	MacAddress  string
+	Bridge      string
 }
@@ -29,6 +29,7 @@ import (
 
 const (
 	DefaultNetworkBridge     = "docker0"
+	DefaultPortForwarder     = portmapper.IptablesForwarderName
 	MaxAllocatedPortAttempts = 10
 )
 
@@ -113,6 +114,7 @@ type Config struct {
 	DefaultGatewayIPv4          string
 	DefaultGatewayIPv6          string
 	InterContainerCommunication bool
+	PortForwarder               string
 }
 
 func InitDriver(config *Config) error {
@@ -136,7 +138,16 @@ func InitDriver(config *Config) error {
 		defaultBindingIP = config.DefaultIp
 	}
 
-	hairpinMode = !config.EnableUserlandProxy
+	switch config.PortForwarder {
+	case "", portmapper.IptablesForwarderName, portmapper.UserlandForwarderName:
+	default:
+		return fmt.Errorf("Port forwarder %s is not supported on this platform", config.PortForwarder)
+	}
+	// Only the userland proxy forwards the ports without a backend
+	useIptablesForwarder := config.PortForwarder != portmapper.UserlandForwarderName
+	hairpinMode = !config.EnableUserlandProxy && useIptablesForwarder
+	iptablesEnabled = config.EnableIptables
+	ipMasqEnabled = config.EnableIpMasq
 
 	bridgeIface = config.Iface
 	usingDefaultBridge := false
@@ -293,7 +304,9 @@ func InitDriver(config *Config) error {
 		// call this on Firewalld reload
 		iptables.OnReloaded(func() { iptables.NewChain("DOCKER", bridgeIface, iptables.Filter, hairpinMode) })
 
-		portMapper.SetIptablesChain(chain)
+		if useIptablesForwarder {
+			portMapper.SetForwarder(portmapper.NewIptablesForwarder(chain))
+		}
 	}
 
 	bridgeIPv4Network = networkv4
@@ -21,14 +21,15 @@ import (
 	"github.com/docker/docker/daemon/networkdriver/ipallocator"
 	"github.com/docker/docker/daemon/networkdriver/portmapper"
 	"github.com/docker/docker/nat"
-	//"github.com/docker/docker/pkg/iptables"
 	"github.com/docker/docker/pkg/parsers/kernel"
+	"github.com/docker/docker/pkg/pf"
 	"github.com/docker/docker/pkg/resolvconf"
 	"github.com/docker/libcontainer/netlink"
 )
 
 const (
 	DefaultNetworkBridge     = "docker0"
+	DefaultPortForwarder     = portmapper.PfForwarderName
 	MaxAllocatedPortAttempts = 10
 )
 
@@ -85,6 +86,7 @@ var (
 	globalIPv6Network *net.IPNet
 	gatewayIPv6       net.IP
 	portMapper        *portmapper.PortMapper
+	pfAnchor          *pf.Anchor
 	once              sync.Once
 	hairpinMode       bool
 
@@ -113,6 +115,7 @@ type Config struct {
 	DefaultGatewayIPv4          string
 	DefaultGatewayIPv6          string
 	InterContainerCommunication bool
+	PortForwarder               string
 }
 
 func InitDriver(config *Config) error {
@@ -136,7 +139,16 @@ func InitDriver(config *Config) error {
 		defaultBindingIP = config.DefaultIp
 	}
 
-	hairpinMode = !config.EnableUserlandProxy
+	switch config.PortForwarder {
+	case "", portmapper.PfForwarderName, portmapper.UserlandForwarderName:
+	default:
+		return fmt.Errorf("Port forwarder %s is not supported on this platform", config.PortForwarder)
+	}
+	// Only the userland proxy forwards the ports without a backend
+	usePfForwarder := config.PortForwarder != portmapper.UserlandForwarderName
+	hairpinMode = !config.EnableUserlandProxy && usePfForwarder
+	iptablesEnabled = config.EnableIptables
+	ipMasqEnabled = config.EnableIpMasq
 
 	bridgeIface = config.Iface
 	usingDefaultBridge := false
@@ -233,35 +245,24 @@ func InitDriver(config *Config) error {
 		bridgeIPv6Addr = networkv6.IP
 	}
 
-	// if config.EnableIptables {
-	// 	if err := iptables.FirewalldInit(); err != nil {
-	// 		logrus.Debugf("Error initializing firewalld: %v", err)
-	// 	}
-	// }
-
-	// Configure iptables for link support
+	// Configure pf for NAT, port forwarding and link support
 	if config.EnableIptables {
-		if err := setupIPTables(addrv4, config.InterContainerCommunication, config.EnableIpMasq); err != nil {
-			logrus.Errorf("Error configuring iptables: %s", err)
+		if err := setupPf(addrv4, config.InterContainerCommunication, config.EnableIpMasq, usePfForwarder); err != nil {
+			logrus.Errorf("Error configuring pf: %s", err)
 			return err
 		}
-		// call this on Firewalld reload
-		//iptables.OnReloaded(func() { setupIPTables(addrv4, config.InterContainerCommunication, config.EnableIpMasq) })
 	}
 
 	if config.EnableIpForward {
 		// Enable IPv4 forwarding
-		if err := ioutil.WriteFile("/proc/sys/net/ipv4/ip_forward", []byte{'1', '\n'}, 0644); err != nil {
-			logrus.Warnf("Unable to enable IPv4 forwarding: %v", err)
+		if out, err := exec.Command("sysctl", "net.inet.ip.forwarding=1").CombinedOutput(); err != nil {
+			logrus.Warnf("Unable to enable IPv4 forwarding: %s (%v)", out, err)
 		}
 
 		if config.FixedCIDRv6 != "" {
 			// Enable IPv6 forwarding
-			if err := ioutil.WriteFile("/proc/sys/net/ipv6/conf/default/forwarding", []byte{'1', '\n'}, 0644); err != nil {
-				logrus.Warnf("Unable to enable IPv6 default forwarding: %v", err)
-			}
-			if err := ioutil.WriteFile("/proc/sys/net/ipv6/conf/all/forwarding", []byte{'1', '\n'}, 0644); err != nil {
-				logrus.Warnf("Unable to enable IPv6 all forwarding: %v", err)
+			if out, err := exec.Command("sysctl", "net.inet6.ip6.forwarding=1").CombinedOutput(); err != nil {
+				logrus.Warnf("Unable to enable IPv6 forwarding: %s (%v)", out, err)
 			}
 		}
 	}
@@ -274,28 +275,6 @@ func InitDriver(config *Config) error {
 		}
 	}
 
-	// We can always try removing the iptables
-	// if err := iptables.RemoveExistingChain("DOCKER", iptables.Nat); err != nil {
-	// 	return err
-	// }
-
-	// if config.EnableIptables {
-	// 	_, err := iptables.NewChain("DOCKER", bridgeIface, iptables.Nat, hairpinMode)
-	// 	if err != nil {
-	// 		return err
-	// 	}
-	// 	// call this on Firewalld reload
-	// 	iptables.OnReloaded(func() { iptables.NewChain("DOCKER", bridgeIface, iptables.Nat, hairpinMode) })
-	// 	chain, err := iptables.NewChain("DOCKER", bridgeIface, iptables.Filter, hairpinMode)
-	// 	if err != nil {
-	// 		return err
-	// 	}
-	// 	// call this on Firewalld reload
-	// 	iptables.OnReloaded(func() { iptables.NewChain("DOCKER", bridgeIface, iptables.Filter, hairpinMode) })
-
-	// 	portMapper.SetIptablesChain(chain)
-	// }
-
 	bridgeIPv4Network = networkv4
 	if config.FixedCIDR != "" {
 		_, subnet, err := net.ParseCIDR(config.FixedCIDR)
@@ -337,91 +316,44 @@ func InitDriver(config *Config) error {
 	// Block BridgeIP in IP allocator
 	ipAllocator.RequestIP(bridgeIPv4Network, bridgeIPv4Network.IP)
 
-	// if config.EnableIptables {
-	// 	iptables.OnReloaded(portMapper.ReMapAll) // call this on Firewalld reload
-	// }
-
 	return nil
 }
 
-func setupIPTables(addr net.Addr, icc, ipmasq bool) error {
-	// Enable NAT
-
-	// if ipmasq {
-	// 	natArgs := []string{"-s", addr.String(), "!", "-o", bridgeIface, "-j", "MASQUERADE"}
-
-	// 	if !iptables.Exists(iptables.Nat, "POSTROUTING", natArgs...) {
-	// 		if output, err := iptables.Raw(append([]string{
-	// 			"-t", string(iptables.Nat), "-I", "POSTROUTING"}, natArgs...)...); err != nil {
-	// 			return fmt.Errorf("Unable to enable network bridge NAT: %s", err)
-	// 		} else if len(output) != 0 {
-	// 			return iptables.ChainError{Chain: "POSTROUTING", Output: output}
-	// 		}
-	// 	}
-	// }
-
-	// var (
-	// 	args       = []string{"-i", bridgeIface, "-o", bridgeIface, "-j"}
-	// 	acceptArgs = append(args, "ACCEPT")
-	// 	dropArgs   = append(args, "DROP")
-	// )
-
-	// if !icc {
-	// 	iptables.Raw(append([]string{"-D", "FORWARD"}, acceptArgs...)...)
-
-	// 	if !iptables.Exists(iptables.Filter, "FORWARD", dropArgs...) {
-	// 		logrus.Debugf("Disable inter-container communication")
-	// 		if output, err := iptables.Raw(append([]string{"-A", "FORWARD"}, dropArgs...)...); err != nil {
-	// 			return fmt.Errorf("Unable to prevent intercontainer communication: %s", err)
-	// 		} else if len(output) != 0 {
-	// 			return fmt.Errorf("Error disabling intercontainer communication: %s", output)
-	// 		}
-	// 	}
-	// } else {
-	// 	iptables.Raw(append([]string{"-D", "FORWARD"}, dropArgs...)...)
-
-	// 	if !iptables.Exists(iptables.Filter, "FORWARD", acceptArgs...) {
-	// 		logrus.Debugf("Enable inter-container communication")
-	// 		if output, err := iptables.Raw(append([]string{"-A", "FORWARD"}, acceptArgs...)...); err != nil {
-	// 			return fmt.Errorf("Unable to allow intercontainer communication: %s", err)
-	// 		} else if len(output) != 0 {
-	// 			return fmt.Errorf("Error enabling intercontainer communication: %s", output)
-	// 		}
-	// 	}
-	// }
-
-	// // In hairpin mode, masquerade traffic from localhost
-	// if hairpinMode {
-	// 	masqueradeArgs := []string{"-t", "nat", "-m", "addrtype", "--src-type", "LOCAL", "-o", bridgeIface, "-j", "MASQUERADE"}
-	// 	if !iptables.Exists(iptables.Filter, "POSTROUTING", masqueradeArgs...) {
-	// 		if output, err := iptables.Raw(append([]string{"-I", "POSTROUTING"}, masqueradeArgs...)...); err != nil {
-	// 			return fmt.Errorf("Unable to masquerade local traffic: %s", err)
-	// 		} else if len(output) != 0 {
-	// 			return fmt.Errorf("Error iptables masquerade local traffic: %s", output)
-	// 		}
-	// 	}
-	// }
-
-	// // Accept all non-intercontainer outgoing packets
-	// outgoingArgs := []string{"-i", bridgeIface, "!", "-o", bridgeIface, "-j", "ACCEPT"}
-	// if !iptables.Exists(iptables.Filter, "FORWARD", outgoingArgs...) {
-	// 	if output, err := iptables.Raw(append([]string{"-I", "FORWARD"}, outgoingArgs...)...); err != nil {
-	// 		return fmt.Errorf("Unable to allow outgoing packets: %s", err)
-	// 	} else if len(output) != 0 {
-	// 		return iptables.ChainError{Chain: "FORWARD outgoing", Output: output}
-	// 	}
-	// }
-
-	// // Accept incoming packets for existing connections
-	// existingArgs := []string{"-o", bridgeIface, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"}
-
-	// if !iptables.Exists(iptables.Filter, "FORWARD", existingArgs...) {
-	// 	if output, err := iptables.Raw(append([]string{"-I", "FORWARD"}, existingArgs...)...); err != nil {
-	// 		return fmt.Errorf("Unable to allow incoming packets: %s", err)
-	// 	} else if len(output) != 0 {
-	// 		return iptables.ChainError{Chain: "FORWARD incoming", Output: output}
-	// 	}
-	// }
+// setupPf loads the pf rules of the bridge network addr: translation of
+// the outgoing traffic if ipmasq, and isolation of the containers unless
+// icc. The port mappings are forwarded with the same anchor if forward.
+func setupPf(addr net.Addr, icc, ipmasq, forward bool) error {
+	ipnet := addr.(*net.IPNet)
+	network := &pf.Network{
+		Bridge:  bridgeIface,
+		Subnet:  &net.IPNet{IP: ipnet.IP.Mask(ipnet.Mask), Mask: ipnet.Mask},
+		Gateway: ipnet.IP,
+		ICC:     icc,
+	}
+	if ipmasq {
+		external, err := pf.ExternalInterface()
+		if err != nil {
+			logrus.Warnf("Outgoing traffic of the containers will not be translated: %s", err)
+		}
+		network.External = external
+	}
+
+	// Start from an empty anchor, the rules of a previous daemon are stale
+	anchor := pf.NewAnchor(pf.DefaultAnchor)
+	if err := anchor.Flush(); err != nil {
+		return fmt.Errorf("Unable to flush the pf anchor %s, is pf enabled? %s", anchor.Name, err)
+	}
+	if err := anchor.SetNetwork(network); err != nil {
+		return fmt.Errorf("Unable to load the pf rules of the bridge: %s", err)
+	}
+	if !icc {
+		logrus.Debugf("Disable inter-container communication")
+	}
+
+	pfAnchor = anchor
+	if forward {
+		portMapper.SetForwarder(portmapper.NewPfForwarder(anchor))
+	}
 	return nil
 }
 
@@ -781,33 +713,40 @@ func AllocatePort(id string, port nat.Port, binding nat.PortBinding) (nat.PortBi
 
 //TODO: should it return something more than just an error?
 func LinkContainers(action, parentIP, childIP string, ports []nat.Port, ignoreErrors bool) error {
-	// var nfAction iptables.Action
-
-	// switch action {
-	// case "-A":
-	// 	nfAction = iptables.Append
-	// case "-I":
-	// 	nfAction = iptables.Insert
-	// case "-D":
-	// 	nfAction = iptables.Delete
-	// default:
-	// 	return fmt.Errorf("Invalid action '%s' specified", action)
-	// }
-
-	// ip1 := net.ParseIP(parentIP)
-	// if ip1 == nil {
-	// 	return fmt.Errorf("Parent IP '%s' is invalid", parentIP)
-	// }
-	// ip2 := net.ParseIP(childIP)
-	// if ip2 == nil {
-	// 	return fmt.Errorf("Child IP '%s' is invalid", childIP)
-	// }
-
-	// chain := iptables.Chain{Name: "DOCKER", Bridge: bridgeIface}
-	// for _, port := range ports {
-	// 	if err := chain.Link(nfAction, ip1, ip2, port.Int(), port.Proto()); !ignoreErrors && err != nil {
-	// 		return err
-	// 	}
-	// }
+	var add bool
+
+	switch action {
+	case "-A", "-I":
+		add = true
+	case "-D":
+		add = false
+	default:
+		return fmt.Errorf("Invalid action '%s' specified", action)
+	}
+
+	ip1 := net.ParseIP(parentIP)
+	if ip1 == nil {
+		return fmt.Errorf("Parent IP '%s' is invalid", parentIP)
+	}
+	ip2 := net.ParseIP(childIP)
+	if ip2 == nil {
+		return fmt.Errorf("Child IP '%s' is invalid", childIP)
+	}
+
+	if pfAnchor == nil {
+		return nil
+	}
+	for _, port := range ports {
+		link := pf.Link{Proto: port.Proto(), Parent: ip1, Child: ip2, Port: port.Int()}
+		var err error
+		if add {
+			err = pfAnchor.AddLink(link)
+		} else {
+			err = pfAnchor.RemoveLink(link)
+		}
+		if !ignoreErrors && err != nil {
+			return err
+		}
+	}
 	return nil
 }
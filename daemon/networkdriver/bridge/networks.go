@@ -0,0 +1,309 @@
+package bridge
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/network"
+	"github.com/docker/docker/daemon/networkdriver"
+	"github.com/docker/libcontainer/netlink"
+)
+
+// userNetworkPool lists the subnets handed out to user-defined networks
+// created without an explicit subnet, in order of preference.
+var userNetworkPool = []string{
+	"172.18.0.0/16",
+	"172.19.0.0/16",
+	"172.20.0.0/16",
+	"172.21.0.0/16",
+	"172.22.0.0/16",
+	"172.23.0.0/16",
+	"172.24.0.0/16",
+	"172.25.0.0/16",
+	"172.26.0.0/16",
+	"172.27.0.0/16",
+	"172.28.0.0/16",
+	"172.29.0.0/16",
+	"172.30.0.0/16",
+	"172.31.0.0/16",
+	"192.168.50.0/24",
+	"192.168.51.0/24",
+	"192.168.52.0/24",
+	"192.168.53.0/24",
+}
+
+// userNetwork is the state kept for a bridge created for a user-defined
+// network.
+type userNetwork struct {
+	iface     string
+	subnet    *net.IPNet
+	gateway   net.IP
+	endpoints map[string]net.IP // container ID -> address
+}
+
+var (
+	userNetworksLock sync.Mutex
+	userNetworks     = make(map[string]*userNetwork) // keyed by network ID
+
+	iptablesEnabled bool
+	ipMasqEnabled   bool
+)
+
+// CreateNetwork sets up the bridge interface iface for the user-defined
+// network id. If subnet is empty, a subnet which overlaps neither the
+// default bridge nor any other network is picked. If gateway is empty the
+// first address of the subnet is used. It returns the subnet and gateway
+// assigned to the bridge.
+func CreateNetwork(id, iface, subnet, gateway string) (*net.IPNet, net.IP, error) {
+	userNetworksLock.Lock()
+	defer userNetworksLock.Unlock()
+
+	if bridgeIPv4Network == nil {
+		return nil, nil, fmt.Errorf("Networking is disabled on this daemon")
+	}
+	if _, exists := userNetworks[id]; exists {
+		return nil, nil, fmt.Errorf("Conflict: network %s already exists", id)
+	}
+
+	inUse := []*net.IPNet{bridgeIPv4Network}
+	for _, n := range userNetworks {
+		inUse = append(inUse, n.subnet)
+	}
+
+	var (
+		ipNet *net.IPNet
+		err   error
+	)
+	if subnet == "" {
+		if ipNet, err = pickSubnet(userNetworkPool, inUse); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if _, ipNet, err = net.ParseCIDR(subnet); err != nil {
+			return nil, nil, fmt.Errorf("Bad parameter: invalid subnet %s", subnet)
+		}
+		if ipNet.IP.To4() == nil {
+			return nil, nil, fmt.Errorf("Bad parameter: subnet %s is not an IPv4 network, IPv6 networks are not supported", subnet)
+		}
+		for _, used := range inUse {
+			if networkdriver.NetworkOverlaps(ipNet, used) {
+				return nil, nil, fmt.Errorf("Conflict: subnet %s overlaps with %s", ipNet, used)
+			}
+		}
+	}
+
+	gw, err := networkGateway(ipNet, gateway)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Block the gateway in the allocator
+	if _, err := ipAllocator.RequestIP(ipNet, gw); err != nil {
+		return nil, nil, err
+	}
+
+	if err := setupNetworkBridge(iface, gw, ipNet); err != nil {
+		ipAllocator.ReleaseIP(ipNet, gw)
+		return nil, nil, err
+	}
+
+	var others []string
+	for _, n := range userNetworks {
+		others = append(others, n.iface)
+	}
+	if err := setupNetworkFirewall(iface, ipNet, append(others, bridgeIface)); err != nil {
+		removeNetworkBridge(iface)
+		ipAllocator.ReleaseIP(ipNet, gw)
+		return nil, nil, err
+	}
+
+	userNetworks[id] = &userNetwork{
+		iface:     iface,
+		subnet:    ipNet,
+		gateway:   gw,
+		endpoints: make(map[string]net.IP),
+	}
+	return ipNet, gw, nil
+}
+
+// DeleteNetwork tears down the bridge of the user-defined network id. It
+// fails if containers are still attached to the network.
+func DeleteNetwork(id string) error {
+	userNetworksLock.Lock()
+	defer userNetworksLock.Unlock()
+
+	n, exists := userNetworks[id]
+	if !exists {
+		return fmt.Errorf("no such network: %s", id)
+	}
+	if len(n.endpoints) > 0 {
+		return fmt.Errorf("Conflict: network %s has active endpoints", id)
+	}
+
+	var others []string
+	for otherID, other := range userNetworks {
+		if otherID != id {
+			others = append(others, other.iface)
+		}
+	}
+	if err := removeNetworkFirewall(n.iface, n.subnet, append(others, bridgeIface)); err != nil {
+		logrus.Warnf("Unable to remove firewall rules for network %s: %v", id, err)
+	}
+	if err := removeNetworkBridge(n.iface); err != nil {
+		return err
+	}
+
+	ipAllocator.ReleaseIP(n.subnet, n.gateway)
+	delete(userNetworks, id)
+	return nil
+}
+
+// AllocateEndpoint gives the container an address on the user-defined
+// network. When primary is set the endpoint becomes the container's main
+// interface, which published ports are mapped to.
+func AllocateEndpoint(networkID, containerID, requestedMac, requestedIP string, primary bool) (*network.EndpointSettings, error) {
+	userNetworksLock.Lock()
+	defer userNetworksLock.Unlock()
+
+	n, exists := userNetworks[networkID]
+	if !exists {
+		return nil, fmt.Errorf("no such network: %s", networkID)
+	}
+
+	ip, err := ipAllocator.RequestIP(n.subnet, net.ParseIP(requestedIP))
+	if err != nil {
+		return nil, err
+	}
+
+	mac, err := net.ParseMAC(requestedMac)
+	if err != nil {
+		mac = generateMacAddr(ip)
+	}
+
+	n.endpoints[containerID] = ip
+	if primary {
+		currentInterfaces.Set(containerID, &networkInterface{IP: ip})
+	}
+
+	maskSize, _ := n.subnet.Mask.Size()
+	return &network.EndpointSettings{
+		NetworkID:   networkID,
+		IPAddress:   ip.String(),
+		IPPrefixLen: maskSize,
+		Gateway:     n.gateway.String(),
+		MacAddress:  mac.String(),
+		Bridge:      n.iface,
+	}, nil
+}
+
+// ReleaseEndpoint returns the container's address on the user-defined
+// network to the pool, along with any ports mapped to it.
+func ReleaseEndpoint(networkID, containerID string) {
+	userNetworksLock.Lock()
+	defer userNetworksLock.Unlock()
+
+	n, exists := userNetworks[networkID]
+	if !exists {
+		logrus.Warnf("No network %s to release %s from", networkID, containerID)
+		return
+	}
+
+	ip, exists := n.endpoints[containerID]
+	if !exists {
+		return
+	}
+
+	if iface := currentInterfaces.Get(containerID); iface != nil && iface.IP.Equal(ip) {
+		for _, nat := range iface.PortMappings {
+			if err := portMapper.Unmap(nat); err != nil {
+				logrus.Infof("Unable to unmap port %s: %s", nat, err)
+			}
+		}
+		currentInterfaces.Set(containerID, nil)
+	}
+
+	if err := ipAllocator.ReleaseIP(n.subnet, ip); err != nil {
+		logrus.Infof("Unable to release IPv4 %s", err)
+	}
+	delete(n.endpoints, containerID)
+}
+
+// pickSubnet returns the first subnet of pool which overlaps neither the
+// networks in use nor the host's routes.
+func pickSubnet(pool []string, inUse []*net.IPNet) (*net.IPNet, error) {
+	for _, candidate := range pool {
+		_, subnet, err := net.ParseCIDR(candidate)
+		if err != nil {
+			return nil, err
+		}
+
+		overlaps := false
+		for _, used := range inUse {
+			if networkdriver.NetworkOverlaps(subnet, used) {
+				overlaps = true
+				break
+			}
+		}
+		if overlaps {
+			continue
+		}
+		if err := networkdriver.CheckRouteOverlaps(subnet); err == networkdriver.ErrNetworkOverlaps {
+			logrus.Debugf("%s %s", subnet, err)
+			continue
+		}
+		return subnet, nil
+	}
+	return nil, fmt.Errorf("Could not find a free subnet for the network, please specify one")
+}
+
+// networkGateway validates the requested gateway, defaulting to the first
+// address of the subnet. Only IPv4 gateways are supported.
+func networkGateway(subnet *net.IPNet, requested string) (net.IP, error) {
+	if requested == "" {
+		first, _ := networkdriver.NetworkRange(subnet)
+		gw := make(net.IP, len(first))
+		copy(gw, first)
+		gw[len(gw)-1]++
+		return gw, nil
+	}
+
+	gw := net.ParseIP(requested)
+	if gw == nil {
+		return nil, fmt.Errorf("Bad parameter: invalid gateway ip %s", requested)
+	}
+	if gw.To4() == nil {
+		return nil, fmt.Errorf("Bad parameter: gateway ip %s is not an IPv4 address", requested)
+	}
+	if !subnet.Contains(gw) {
+		return nil, fmt.Errorf("Bad parameter: gateway ip %s must be part of the network %s", requested, subnet)
+	}
+	return gw.To4(), nil
+}
+
+func setupNetworkBridge(iface string, gateway net.IP, subnet *net.IPNet) error {
+	if err := createBridgeIface(iface); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("Unable to create bridge %s: %v", iface, err)
+	}
+
+	i, err := net.InterfaceByName(iface)
+	if err != nil {
+		return err
+	}
+	if err := netlink.NetworkLinkAddIp(i, gateway, subnet); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("Unable to add address %s to bridge %s: %v", gateway, iface, err)
+	}
+	if err := netlink.NetworkLinkUp(i); err != nil {
+		return fmt.Errorf("Unable to start network bridge %s: %v", iface, err)
+	}
+	return nil
+}
+
+func removeNetworkBridge(iface string) error {
+	if err := netlink.DeleteBridge(iface); err != nil {
+		return fmt.Errorf("Unable to remove bridge %s: %v", iface, err)
+	}
+	return nil
+}
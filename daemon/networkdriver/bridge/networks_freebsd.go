@@ -0,0 +1,31 @@
+// +build freebsd
+
+package bridge
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/docker/docker/pkg/pf"
+)
+
+// The bridges of user-defined networks are isolated from each other and
+// from the default bridge by the pf anchor of the daemon, which knows about
+// every bridge: the others do not need to be passed to it.
+
+func setupNetworkFirewall(iface string, subnet *net.IPNet, others []string) error {
+	if pfAnchor == nil {
+		return fmt.Errorf("User-defined networks require the pf backend, enabled by --iptables")
+	}
+	if err := pfAnchor.AddBridge(pf.Bridge{Name: iface, Subnet: subnet}); err != nil {
+		return fmt.Errorf("Unable to set up network bridge %s: %s", iface, err)
+	}
+	return nil
+}
+
+func removeNetworkFirewall(iface string, subnet *net.IPNet, others []string) error {
+	if pfAnchor == nil {
+		return nil
+	}
+	return pfAnchor.RemoveBridge(iface)
+}
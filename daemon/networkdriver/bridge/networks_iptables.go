@@ -0,0 +1,72 @@
+// +build !freebsd
+
+package bridge
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/docker/docker/pkg/iptables"
+)
+
+// networkRules returns the iptables rules needed by the bridge iface of a
+// user-defined network. Traffic between iface and the bridges in others is
+// dropped so that networks are isolated from each other.
+func networkRules(iface string, subnet *net.IPNet, others []string) [][]string {
+	var rules [][]string
+	if ipMasqEnabled {
+		rules = append(rules, []string{"-t", string(iptables.Nat), "POSTROUTING", "-s", subnet.String(), "!", "-o", iface, "-j", "MASQUERADE"})
+	}
+	rules = append(rules,
+		[]string{"-t", string(iptables.Filter), "FORWARD", "-i", iface, "-o", iface, "-j", "ACCEPT"},
+		[]string{"-t", string(iptables.Filter), "FORWARD", "-i", iface, "!", "-o", iface, "-j", "ACCEPT"},
+		[]string{"-t", string(iptables.Filter), "FORWARD", "-o", iface, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
+		// DNS queries to the daemon's resolver on the gateway
+		[]string{"-t", string(iptables.Filter), "INPUT", "-i", iface, "-p", "udp", "--dport", "53", "-j", "ACCEPT"},
+	)
+	for _, other := range others {
+		rules = append(rules,
+			[]string{"-t", string(iptables.Filter), "FORWARD", "-i", iface, "-o", other, "-j", "DROP"},
+			[]string{"-t", string(iptables.Filter), "FORWARD", "-i", other, "-o", iface, "-j", "DROP"},
+		)
+	}
+	return rules
+}
+
+func setupNetworkFirewall(iface string, subnet *net.IPNet, others []string) error {
+	if !iptablesEnabled {
+		return nil
+	}
+	for _, rule := range networkRules(iface, subnet, others) {
+		table, chain, args := iptables.Table(rule[1]), rule[2], rule[3:]
+		if iptables.Exists(table, chain, args...) {
+			continue
+		}
+		// Insert so the isolation rules take precedence over the
+		// accept rules of the default bridge.
+		if output, err := iptables.Raw(append([]string{"-t", string(table), "-I", chain}, args...)...); err != nil {
+			return fmt.Errorf("Unable to set up network bridge %s: %s", iface, err)
+		} else if len(output) != 0 {
+			return iptables.ChainError{Chain: chain, Output: output}
+		}
+	}
+	return nil
+}
+
+func removeNetworkFirewall(iface string, subnet *net.IPNet, others []string) error {
+	if !iptablesEnabled {
+		return nil
+	}
+	for _, rule := range networkRules(iface, subnet, others) {
+		table, chain, args := iptables.Table(rule[1]), rule[2], rule[3:]
+		if !iptables.Exists(table, chain, args...) {
+			continue
+		}
+		if output, err := iptables.Raw(append([]string{"-t", string(table), "-D", chain}, args...)...); err != nil {
+			return err
+		} else if len(output) != 0 {
+			return iptables.ChainError{Chain: chain, Output: output}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package bridge
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPickSubnet(t *testing.T) {
+	pool := []string{"10.200.0.0/16", "10.201.0.0/16", "10.202.0.0/24"}
+	_, used, _ := net.ParseCIDR("10.200.0.0/16")
+
+	subnet, err := pickSubnet(pool, []*net.IPNet{used})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subnet.String() != "10.201.0.0/16" {
+		t.Fatalf("Expected 10.201.0.0/16, got %s", subnet)
+	}
+
+	_, wide, _ := net.ParseCIDR("10.0.0.0/8")
+	if _, err := pickSubnet(pool, []*net.IPNet{wide}); err == nil {
+		t.Fatal("Expected an error when every subnet of the pool is in use")
+	}
+}
+
+func TestNetworkGateway(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.201.0.0/16")
+
+	gw, err := networkGateway(subnet, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gw.String() != "10.201.0.1" {
+		t.Fatalf("Expected default gateway 10.201.0.1, got %s", gw)
+	}
+
+	if gw, err = networkGateway(subnet, "10.201.3.254"); err != nil {
+		t.Fatal(err)
+	}
+	if gw.String() != "10.201.3.254" {
+		t.Fatalf("Expected gateway 10.201.3.254, got %s", gw)
+	}
+
+	if _, err := networkGateway(subnet, "10.202.0.1"); err == nil {
+		t.Fatal("Expected an error for a gateway outside of the subnet")
+	}
+	if _, err := networkGateway(subnet, "foo"); err == nil {
+		t.Fatal("Expected an error for an invalid gateway")
+	}
+	if _, err := networkGateway(subnet, "fd00::1"); err == nil {
+		t.Fatal("Expected an error for an IPv6 gateway")
+	}
+}
@@ -0,0 +1,94 @@
+package portmapper
+
+import (
+	"net"
+
+	"github.com/docker/docker/pkg/pf"
+)
+
+// Names of the port forwarding backends, as given to the daemon.
+const (
+	IptablesForwarderName = "iptables"
+	PfForwarderName       = "pf"
+	UserlandForwarderName = "userland"
+)
+
+// Forward is a port of the host forwarded to a container.
+type Forward struct {
+	Proto         string
+	HostIP        net.IP
+	HostPort      int
+	ContainerIP   net.IP
+	ContainerPort int
+}
+
+// Forwarder forwards the mapped ports of the host to the containers in
+// the kernel, instead of, or next to, the userland proxy.
+type Forwarder interface {
+	// Add forwards the port of f.
+	Add(f Forward) error
+	// Remove stops forwarding the port of f.
+	Remove(f Forward) error
+	// Flush stops forwarding every port.
+	Flush() error
+	// ReapplyAll forwards the ports of forwards again, after the firewall
+	// was reloaded.
+	ReapplyAll(forwards []Forward) error
+}
+
+type userlandForwarder struct{}
+
+// NewUserlandForwarder returns a forwarder which leaves the forwarding of
+// the ports to the userland proxy.
+func NewUserlandForwarder() Forwarder {
+	return userlandForwarder{}
+}
+
+func (userlandForwarder) Add(f Forward) error                 { return nil }
+func (userlandForwarder) Remove(f Forward) error              { return nil }
+func (userlandForwarder) Flush() error                        { return nil }
+func (userlandForwarder) ReapplyAll(forwards []Forward) error { return nil }
+
+type pfForwarder struct {
+	anchor *pf.Anchor
+}
+
+// NewPfForwarder returns a forwarder loading rdr rules in the pf anchor.
+func NewPfForwarder(anchor *pf.Anchor) Forwarder {
+	return &pfForwarder{anchor: anchor}
+}
+
+func pfForward(f Forward) pf.Forward {
+	return pf.Forward{
+		Proto:         f.Proto,
+		HostIP:        f.HostIP,
+		HostPort:      f.HostPort,
+		ContainerIP:   f.ContainerIP,
+		ContainerPort: f.ContainerPort,
+	}
+}
+
+func (p *pfForwarder) Add(f Forward) error {
+	return p.anchor.AddForward(pfForward(f))
+}
+
+func (p *pfForwarder) Remove(f Forward) error {
+	return p.anchor.RemoveForward(pfForward(f))
+}
+
+func (p *pfForwarder) Flush() error {
+	return p.anchor.SetForwards(nil)
+}
+
+func (p *pfForwarder) ReapplyAll(forwards []Forward) error {
+	pfForwards := make([]pf.Forward, 0, len(forwards))
+	for _, f := range forwards {
+		pfForwards = append(pfForwards, pfForward(f))
+	}
+	return p.anchor.SetForwards(pfForwards)
+}
+
+func (f Forward) equal(o Forward) bool {
+	return f.Proto == o.Proto && f.HostIP.Equal(o.HostIP) && f.HostPort == o.HostPort &&
+		f.ContainerIP.Equal(o.ContainerIP) && f.ContainerPort == o.ContainerPort
+}
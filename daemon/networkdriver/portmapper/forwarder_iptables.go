@@ -0,0 +1,62 @@
+// +build !freebsd
+
+package portmapper
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/pkg/iptables"
+)
+
+type iptablesForwarder struct {
+	chain *iptables.Chain
+	// the ports forwarded, to be flushed
+	forwards []Forward
+}
+
+// NewIptablesForwarder returns a forwarder adding DNAT rules to the
+// iptables chain.
+func NewIptablesForwarder(chain *iptables.Chain) Forwarder {
+	return &iptablesForwarder{chain: chain}
+}
+
+func (i *iptablesForwarder) forward(action iptables.Action, f Forward) error {
+	return i.chain.Forward(action, f.HostIP, f.HostPort, f.Proto, f.ContainerIP.String(), f.ContainerPort)
+}
+
+func (i *iptablesForwarder) Add(f Forward) error {
+	if err := i.forward(iptables.Append, f); err != nil {
+		return err
+	}
+	i.forwards = append(i.forwards, f)
+	return nil
+}
+
+func (i *iptablesForwarder) Remove(f Forward) error {
+	for j, existing := range i.forwards {
+		if existing.equal(f) {
+			i.forwards = append(i.forwards[:j], i.forwards[j+1:]...)
+			break
+		}
+	}
+	return i.forward(iptables.Delete, f)
+}
+
+func (i *iptablesForwarder) Flush() error {
+	for _, f := range i.forwards {
+		if err := i.forward(iptables.Delete, f); err != nil {
+			logrus.Errorf("Error on iptables delete: %s", err)
+		}
+	}
+	i.forwards = nil
+	return nil
+}
+
+func (i *iptablesForwarder) ReapplyAll(forwards []Forward) error {
+	for _, f := range forwards {
+		if err := i.forward(iptables.Append, f); err != nil {
+			logrus.Errorf("Error on iptables add: %s", err)
+		}
+	}
+	i.forwards = append([]Forward(nil), forwards...)
+	return nil
+}
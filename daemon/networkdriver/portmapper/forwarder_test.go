@@ -0,0 +1,164 @@
+package portmapper
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/pkg/pf"
+)
+
+// fakeForwarder keeps the forwarded ports in memory.
+type fakeForwarder struct {
+	forwards []Forward
+	flushed  bool
+	err      error
+}
+
+func (f *fakeForwarder) Add(fw Forward) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.forwards = append(f.forwards, fw)
+	return nil
+}
+
+func (f *fakeForwarder) Remove(fw Forward) error {
+	for i, existing := range f.forwards {
+		if existing.equal(fw) {
+			f.forwards = append(f.forwards[:i], f.forwards[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("not forwarded")
+}
+
+func (f *fakeForwarder) Flush() error {
+	f.forwards = nil
+	f.flushed = true
+	return nil
+}
+
+func (f *fakeForwarder) ReapplyAll(forwards []Forward) error {
+	f.forwards = append([]Forward(nil), forwards...)
+	return nil
+}
+
+type failingProxy struct{}
+
+func (failingProxy) Start() error { return errors.New("proxy failed") }
+func (failingProxy) Stop() error  { return nil }
+
+func TestMapForwardsPort(t *testing.T) {
+	pm := New()
+	f := &fakeForwarder{}
+	pm.SetForwarder(f)
+
+	container := &net.TCPAddr{IP: net.ParseIP("172.17.0.2"), Port: 80}
+	hostIP := net.ParseIP("0.0.0.0")
+	host, err := pm.Map(container, hostIP, 8080, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Forward{{Proto: "tcp", HostIP: hostIP, HostPort: 8080, ContainerIP: container.IP, ContainerPort: 80}}
+	if !reflect.DeepEqual(f.forwards, expected) {
+		t.Fatalf("Expected %v, got %v", expected, f.forwards)
+	}
+
+	// Reapplying forwards the same ports
+	f.forwards = nil
+	pm.ReMapAll()
+	if !reflect.DeepEqual(f.forwards, expected) {
+		t.Fatalf("Expected %v, got %v", expected, f.forwards)
+	}
+
+	if err := pm.Unmap(host); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.forwards) != 0 {
+		t.Fatalf("Expected no forwarded port, got %v", f.forwards)
+	}
+	// The port can be mapped again
+	if _, err := pm.Map(container, hostIP, 8080, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMapForwarderError(t *testing.T) {
+	pm := New()
+	f := &fakeForwarder{err: errors.New("forward failed")}
+	pm.SetForwarder(f)
+
+	container := &net.UDPAddr{IP: net.ParseIP("172.17.0.2"), Port: 53}
+	hostIP := net.ParseIP("0.0.0.0")
+	if _, err := pm.Map(container, hostIP, 5353, false); err == nil {
+		t.Fatal("Expected the error of the forwarder")
+	}
+
+	// The port was released
+	f.err = nil
+	if _, err := pm.Map(container, hostIP, 5353, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMapProxyErrorRemovesForward(t *testing.T) {
+	defer func(newProxy func(string, net.IP, int, net.IP, int) UserlandProxy) {
+		NewProxy = newProxy
+	}(NewProxy)
+	NewProxy = func(string, net.IP, int, net.IP, int) UserlandProxy {
+		return failingProxy{}
+	}
+
+	pm := New()
+	f := &fakeForwarder{}
+	pm.SetForwarder(f)
+
+	container := &net.TCPAddr{IP: net.ParseIP("172.17.0.2"), Port: 80}
+	hostIP := net.ParseIP("0.0.0.0")
+	if _, err := pm.Map(container, hostIP, 8080, true); err == nil {
+		t.Fatal("Expected the error of the proxy")
+	}
+	if len(f.forwards) != 0 {
+		t.Fatalf("Expected no forwarded port, got %v", f.forwards)
+	}
+	if _, err := pm.Allocator.RequestPort(hostIP, "tcp", 8080); err != nil {
+		t.Fatalf("Expected the port to be released: %s", err)
+	}
+}
+
+type pfRunner struct {
+	rules []string
+}
+
+func (r *pfRunner) Run(stdin string, args ...string) ([]byte, error) {
+	r.rules = append(r.rules, stdin)
+	return nil, nil
+}
+
+func TestPfForwarder(t *testing.T) {
+	r := &pfRunner{}
+	f := NewPfForwarder(pf.NewAnchorWithRunner(pf.DefaultAnchor, r))
+
+	fw := Forward{Proto: "tcp", HostIP: net.ParseIP("0.0.0.0"), HostPort: 8080, ContainerIP: net.ParseIP("172.17.0.2"), ContainerPort: 80}
+	if err := f.Add(fw); err != nil {
+		t.Fatal(err)
+	}
+	rdr := "rdr pass inet proto tcp from any to self port 8080 -> 172.17.0.2 port 80\n"
+	if r.rules[0] != rdr {
+		t.Fatalf("Expected %q, got %q", rdr, r.rules[0])
+	}
+	if err := f.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if r.rules[1] != "" {
+		t.Fatalf("Expected no rules, got %q", r.rules[1])
+	}
+	if err := f.ReapplyAll([]Forward{fw}); err != nil {
+		t.Fatal(err)
+	}
+	if r.rules[2] != rdr {
+		t.Fatalf("Expected %q, got %q", rdr, r.rules[2])
+	}
+}
@@ -1,5 +1,3 @@
-// +build !freebsd
-
 package portmapper
 
 import (
@@ -10,7 +8,6 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/daemon/networkdriver/portallocator"
-	"github.com/docker/docker/pkg/iptables"
 )
 
 type mapping struct {
@@ -29,7 +26,7 @@ var (
 )
 
 type PortMapper struct {
-	chain *iptables.Chain
+	forwarder Forwarder
 
 	// udp:ip:port
 	currentMappings map[string]*mapping
@@ -44,13 +41,22 @@ func New() *PortMapper {
 
 func NewWithPortAllocator(allocator *portallocator.PortAllocator) *PortMapper {
 	return &PortMapper{
+		forwarder:       NewUserlandForwarder(),
 		currentMappings: make(map[string]*mapping),
 		Allocator:       allocator,
 	}
 }
 
-func (pm *PortMapper) SetIptablesChain(c *iptables.Chain) {
-	pm.chain = c
+// SetForwarder sets the backend the ports are forwarded with. The ports
+// forwarded by the previous backend are flushed.
+func (pm *PortMapper) SetForwarder(f Forwarder) {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+
+	if err := pm.forwarder.Flush(); err != nil {
+		logrus.Errorf("Error flushing forwarded ports: %s", err)
+	}
+	pm.forwarder = f
 }
 
 func (pm *PortMapper) Map(container net.Addr, hostIP net.IP, hostPort int, useProxy bool) (host net.Addr, err error) {
@@ -110,22 +116,17 @@ func (pm *PortMapper) Map(container net.Addr, hostIP net.IP, hostPort int, usePr
 		return nil, ErrPortMappedForIP
 	}
 
-	containerIP, containerPort := getIPAndPort(m.container)
-	if err := pm.forward(iptables.Append, m.proto, hostIP, allocatedHostPort, containerIP.String(), containerPort); err != nil {
+	if err := pm.forwarder.Add(m.forward()); err != nil {
 		return nil, err
 	}
 
 	cleanup := func() error {
-		// need to undo the iptables rules before we return
+		// need to undo the forwarding before we return, the port itself is
+		// released by the deferred function above
 		if m.userlandProxy != nil {
 			m.userlandProxy.Stop()
 		}
-		pm.forward(iptables.Delete, m.proto, hostIP, allocatedHostPort, containerIP.String(), containerPort)
-		if err := pm.Allocator.ReleasePort(hostIP, m.proto, allocatedHostPort); err != nil {
-			return err
-		}
-
-		return nil
+		return pm.forwarder.Remove(m.forward())
 	}
 
 	if m.userlandProxy != nil {
@@ -144,12 +145,15 @@ func (pm *PortMapper) Map(container net.Addr, hostIP net.IP, hostPort int, usePr
 // re-apply all port mappings
 func (pm *PortMapper) ReMapAll() {
 	logrus.Debugln("Re-applying all port mappings.")
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+
+	forwards := make([]Forward, 0, len(pm.currentMappings))
 	for _, data := range pm.currentMappings {
-		containerIP, containerPort := getIPAndPort(data.container)
-		hostIP, hostPort := getIPAndPort(data.host)
-		if err := pm.forward(iptables.Append, data.proto, hostIP, hostPort, containerIP.String(), containerPort); err != nil {
-			logrus.Errorf("Error on iptables add: %s", err)
-		}
+		forwards = append(forwards, data.forward())
+	}
+	if err := pm.forwarder.ReapplyAll(forwards); err != nil {
+		logrus.Errorf("Error re-applying port mappings: %s", err)
 	}
 }
 
@@ -169,10 +173,8 @@ func (pm *PortMapper) Unmap(host net.Addr) error {
 
 	delete(pm.currentMappings, key)
 
-	containerIP, containerPort := getIPAndPort(data.container)
-	hostIP, hostPort := getIPAndPort(data.host)
-	if err := pm.forward(iptables.Delete, data.proto, hostIP, hostPort, containerIP.String(), containerPort); err != nil {
-		logrus.Errorf("Error on iptables delete: %s", err)
+	if err := pm.forwarder.Remove(data.forward()); err != nil {
+		logrus.Errorf("Error removing forwarded port: %s", err)
 	}
 
 	switch a := host.(type) {
@@ -204,9 +206,15 @@ func getIPAndPort(a net.Addr) (net.IP, int) {
 	return nil, 0
 }
 
-func (pm *PortMapper) forward(action iptables.Action, proto string, sourceIP net.IP, sourcePort int, containerIP string, containerPort int) error {
-	if pm.chain == nil {
-		return nil
+// forward returns the port forwarded for the mapping.
+func (m *mapping) forward() Forward {
+	containerIP, containerPort := getIPAndPort(m.container)
+	hostIP, hostPort := getIPAndPort(m.host)
+	return Forward{
+		Proto:         m.proto,
+		HostIP:        hostIP,
+		HostPort:      hostPort,
+		ContainerIP:   containerIP,
+		ContainerPort: containerPort,
 	}
-	return pm.chain.Forward(action, sourceIP, sourcePort, proto, containerIP, containerPort)
 }
@@ -3,8 +3,6 @@ package portmapper
 import (
 	"net"
 	"testing"
-
-	"github.com/docker/docker/pkg/iptables"
 )
 
 func init() {
@@ -12,21 +10,18 @@ func init() {
 	NewProxy = NewMockProxyCommand
 }
 
-func TestSetIptablesChain(t *testing.T) {
+func TestSetForwarder(t *testing.T) {
 	pm := New()
 
-	c := &iptables.Chain{
-		Name:   "TEST",
-		Bridge: "192.168.1.1",
-	}
-
-	if pm.chain != nil {
-		t.Fatal("chain should be nil at init")
+	old := &fakeForwarder{}
+	pm.SetForwarder(old)
+	if pm.forwarder != old {
+		t.Fatal("forwarder should be set")
 	}
 
-	pm.SetIptablesChain(c)
-	if pm.chain == nil {
-		t.Fatal("chain should not be nil after set")
+	pm.SetForwarder(&fakeForwarder{})
+	if !old.flushed {
+		t.Fatal("the previous forwarder should be flushed")
 	}
 }
 
@@ -0,0 +1,448 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/daemon/network"
+	"github.com/docker/docker/daemon/networkdriver/bridge"
+	"github.com/docker/docker/pkg/dnsserver"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/runconfig"
+)
+
+// builtinNetworks are the network modes which always exist and cannot be
+// created or removed through the networks API.
+var builtinNetworks = []string{"bridge", "host", "none"}
+
+// networkStore keeps the user-defined networks, each persisted as a JSON
+// file under root, along with the DNS servers running for them.
+type networkStore struct {
+	sync.Mutex
+	root      string
+	networks  map[string]*network.Network  // keyed by ID
+	resolvers map[string]*dnsserver.Server // keyed by network ID
+}
+
+func newNetworkStore(root string) (*networkStore, error) {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, err
+	}
+
+	s := &networkStore{
+		root:      root,
+		networks:  make(map[string]*network.Network),
+		resolvers: make(map[string]*dnsserver.Server),
+	}
+	dir, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range dir {
+		if filepath.Ext(fi.Name()) != ".json" {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(root, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var n network.Network
+		if err := json.Unmarshal(data, &n); err != nil {
+			logrus.Errorf("Failed to load network %s: %v", fi.Name(), err)
+			continue
+		}
+		s.networks[n.ID] = &n
+	}
+	return s, nil
+}
+
+// Get looks up a network by name, full ID or unique ID prefix.
+func (s *networkStore) Get(nameOrID string) (*network.Network, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if n, exists := s.networks[nameOrID]; exists {
+		return n, nil
+	}
+	var found *network.Network
+	for _, n := range s.networks {
+		if n.Name == nameOrID {
+			return n, nil
+		}
+		if strings.HasPrefix(n.ID, nameOrID) {
+			if found != nil {
+				return nil, fmt.Errorf("network %s is ambiguous", nameOrID)
+			}
+			found = n
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no such network: %s", nameOrID)
+	}
+	return found, nil
+}
+
+// List returns the networks sorted by name.
+func (s *networkStore) List() []*network.Network {
+	s.Lock()
+	defer s.Unlock()
+
+	var networks []*network.Network
+	for _, n := range s.networks {
+		networks = append(networks, n)
+	}
+	sort.Sort(networksByName(networks))
+	return networks
+}
+
+func (s *networkStore) add(n *network.Network) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(s.root, n.ID+".json"), data, 0600); err != nil {
+		return err
+	}
+	s.Lock()
+	s.networks[n.ID] = n
+	s.Unlock()
+	return nil
+}
+
+func (s *networkStore) delete(id string) error {
+	if err := os.Remove(filepath.Join(s.root, id+".json")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.Lock()
+	delete(s.networks, id)
+	s.Unlock()
+	return nil
+}
+
+type networksByName []*network.Network
+
+func (n networksByName) Len() int           { return len(n) }
+func (n networksByName) Less(i, j int) bool { return n[i].Name < n[j].Name }
+func (n networksByName) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+
+// restoreNetworks recreates the bridges of the user-defined networks which
+// were created before the daemon was restarted.
+func (daemon *Daemon) restoreNetworks() {
+	for _, n := range daemon.networks.List() {
+		if _, _, err := bridge.CreateNetwork(n.ID, n.Bridge, n.Subnet, n.Gateway); err != nil {
+			logrus.Errorf("Failed to restore network %s: %v", n.Name, err)
+			continue
+		}
+		// The containers of the network fall back to the nameservers of
+		// the host without a DNS server
+		if err := daemon.startResolver(n); err != nil {
+			logrus.Error(err)
+		}
+	}
+}
+
+// NetworkCreate creates a user-defined network backed by a new bridge.
+func (daemon *Daemon) NetworkCreate(name, driver, subnet, gateway string) (string, error) {
+	if daemon.config.DisableNetwork {
+		return "", fmt.Errorf("Networking is disabled on this daemon")
+	}
+	if driver == "" {
+		driver = "bridge"
+	}
+	if driver != "bridge" {
+		return "", fmt.Errorf("Bad parameter: unsupported network driver %s", driver)
+	}
+	if !runconfig.ValidNetworkName(name) {
+		return "", fmt.Errorf("Bad parameter: invalid network name %q", name)
+	}
+	if _, err := daemon.networks.Get(name); err == nil {
+		return "", fmt.Errorf("Conflict: network with name %s already exists", name)
+	}
+
+	id := stringid.GenerateRandomID()
+	iface := "br-" + stringid.TruncateID(id)
+	ipNet, gw, err := bridge.CreateNetwork(id, iface, subnet, gateway)
+	if err != nil {
+		return "", err
+	}
+
+	n := &network.Network{
+		ID:      id,
+		Name:    name,
+		Driver:  driver,
+		Bridge:  iface,
+		Subnet:  ipNet.String(),
+		Gateway: gw.String(),
+	}
+	if err := daemon.networks.add(n); err != nil {
+		bridge.DeleteNetwork(id)
+		return "", err
+	}
+	if err := daemon.startResolver(n); err != nil {
+		daemon.networks.delete(id)
+		bridge.DeleteNetwork(id)
+		return "", err
+	}
+	daemon.logNetworkEvent(n, "create", nil)
+	return id, nil
+}
+
+// Networks lists the built-in networks followed by the user-defined ones.
+func (daemon *Daemon) Networks() []*types.NetworkResource {
+	var networks []*types.NetworkResource
+	for _, name := range builtinNetworks {
+		networks = append(networks, &types.NetworkResource{Name: name, ID: name, Driver: name})
+	}
+	for _, n := range daemon.networks.List() {
+		networks = append(networks, daemon.networkToAPIType(n))
+	}
+	return networks
+}
+
+// NetworkInspect returns the network and the containers attached to it.
+func (daemon *Daemon) NetworkInspect(nameOrID string) (*types.NetworkResource, error) {
+	for _, name := range builtinNetworks {
+		if nameOrID == name {
+			return &types.NetworkResource{Name: name, ID: name, Driver: name}, nil
+		}
+	}
+	n, err := daemon.networks.Get(nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	return daemon.networkToAPIType(n), nil
+}
+
+// NetworkRm removes a user-defined network. Networks which still have
+// containers attached cannot be removed.
+func (daemon *Daemon) NetworkRm(nameOrID string) error {
+	for _, name := range builtinNetworks {
+		if nameOrID == name {
+			return fmt.Errorf("%s is a pre-defined network and cannot be removed", name)
+		}
+	}
+	n, err := daemon.networks.Get(nameOrID)
+	if err != nil {
+		return err
+	}
+	if containers := daemon.networkContainers(n); len(containers) > 0 {
+		var names []string
+		for _, c := range containers {
+			names = append(names, strings.TrimPrefix(c.Name, "/"))
+		}
+		return fmt.Errorf("Conflict: network %s is in use by containers %v", n.Name, names)
+	}
+	daemon.stopResolver(n)
+	if err := bridge.DeleteNetwork(n.ID); err != nil && !daemon.config.DisableNetwork {
+		return err
+	}
+	if err := daemon.networks.delete(n.ID); err != nil {
+		return err
+	}
+	daemon.logNetworkEvent(n, "destroy", nil)
+	return nil
+}
+
+// NetworkConnect attaches a stopped container to an additional network.
+// The container gets an interface on the network when it is next started.
+func (daemon *Daemon) NetworkConnect(nameOrID, containerName string) error {
+	n, err := daemon.networks.Get(nameOrID)
+	if err != nil {
+		return err
+	}
+	container, err := daemon.Get(containerName)
+	if err != nil {
+		return err
+	}
+	if container.IsRunning() {
+		return fmt.Errorf("Conflict: cannot connect running container %s to a network, stop it first", containerName)
+	}
+	if !container.hostConfig.NetworkMode.IsPrivate() {
+		return fmt.Errorf("Conflict: container %s uses network mode %s and cannot be connected to a network", containerName, container.hostConfig.NetworkMode)
+	}
+	if container.isConnectedTo(n) {
+		return fmt.Errorf("Conflict: container %s is already connected to network %s", containerName, n.Name)
+	}
+
+	container.Lock()
+	defer container.Unlock()
+	if container.NetworkSettings.Networks == nil {
+		container.NetworkSettings.Networks = make(map[string]*network.EndpointSettings)
+	}
+	container.NetworkSettings.Networks[n.Name] = &network.EndpointSettings{NetworkID: n.ID}
+	if err := container.toDisk(); err != nil {
+		return err
+	}
+	daemon.logNetworkEvent(n, "connect", map[string]string{"container": container.ID})
+	return nil
+}
+
+// NetworkDisconnect detaches a stopped container from an additional
+// network. The network selected with --net cannot be disconnected.
+func (daemon *Daemon) NetworkDisconnect(nameOrID, containerName string) error {
+	n, err := daemon.networks.Get(nameOrID)
+	if err != nil {
+		return err
+	}
+	container, err := daemon.Get(containerName)
+	if err != nil {
+		return err
+	}
+	if container.IsRunning() {
+		return fmt.Errorf("Conflict: cannot disconnect running container %s from a network, stop it first", containerName)
+	}
+	if container.hostConfig.NetworkMode.NetworkName() == n.Name {
+		return fmt.Errorf("Conflict: network %s is the primary network of container %s", n.Name, containerName)
+	}
+	if !container.isConnectedTo(n) {
+		return fmt.Errorf("container %s is not connected to network %s", containerName, n.Name)
+	}
+
+	container.Lock()
+	defer container.Unlock()
+	delete(container.NetworkSettings.Networks, n.Name)
+	if err := container.toDisk(); err != nil {
+		return err
+	}
+	daemon.logNetworkEvent(n, "disconnect", map[string]string{"container": container.ID})
+	return nil
+}
+
+// logNetworkEvent logs the event action of the network, with its name and
+// driver and the extra attributes.
+func (daemon *Daemon) logNetworkEvent(n *network.Network, action string, extra map[string]string) {
+	attributes := map[string]string{"name": n.Name, "type": n.Driver}
+	for k, v := range extra {
+		attributes[k] = v
+	}
+	daemon.EventsService.Log(action, types.NetworkEventType, types.EventActor{
+		ID:         n.ID,
+		Attributes: attributes,
+	})
+}
+
+// networkContainers returns the containers attached to the network, either
+// through --net or through `docker network connect`.
+func (daemon *Daemon) networkContainers(n *network.Network) []*Container {
+	var containers []*Container
+	for _, c := range daemon.List() {
+		if c.hostConfig.NetworkMode.NetworkName() == n.Name || c.isConnectedTo(n) {
+			containers = append(containers, c)
+		}
+	}
+	return containers
+}
+
+func (daemon *Daemon) networkToAPIType(n *network.Network) *types.NetworkResource {
+	res := &types.NetworkResource{
+		Name:       n.Name,
+		ID:         n.ID,
+		Driver:     n.Driver,
+		Subnet:     n.Subnet,
+		Gateway:    n.Gateway,
+		Containers: make(map[string]types.EndpointResource),
+	}
+	for _, c := range daemon.networkContainers(n) {
+		ep := types.EndpointResource{Name: strings.TrimPrefix(c.Name, "/")}
+		if settings, exists := c.NetworkSettings.Networks[n.Name]; exists {
+			ep.IPAddress = settings.IPAddress
+			ep.IPPrefixLen = settings.IPPrefixLen
+			ep.MacAddress = settings.MacAddress
+		}
+		res.Containers[c.ID] = ep
+	}
+	return res
+}
+
+func (container *Container) isConnectedTo(n *network.Network) bool {
+	if container.NetworkSettings == nil {
+		return false
+	}
+	ep, exists := container.NetworkSettings.Networks[n.Name]
+	return exists && ep.NetworkID == n.ID
+}
+
+// allocateUserNetwork attaches the container to the user-defined network
+// it was started with, making it the container's primary interface.
+func (container *Container) allocateUserNetwork(name, requestedMac, requestedIP string) (*network.Settings, error) {
+	n, err := container.daemon.networks.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	ep, err := bridge.AllocateEndpoint(n.ID, container.ID, requestedMac, requestedIP, true)
+	if err != nil {
+		return nil, err
+	}
+	return &network.Settings{
+		IPAddress:   ep.IPAddress,
+		IPPrefixLen: ep.IPPrefixLen,
+		Gateway:     ep.Gateway,
+		MacAddress:  ep.MacAddress,
+		Bridge:      ep.Bridge,
+		Networks:    map[string]*network.EndpointSettings{n.Name: ep},
+	}, nil
+}
+
+// allocateEndpoints gives the container an interface on each additional
+// network it was connected to. Networks which no longer exist are skipped.
+func (container *Container) allocateEndpoints(settings *network.Settings, connected map[string]*network.EndpointSettings) error {
+	for name, prev := range connected {
+		if _, exists := settings.Networks[name]; exists {
+			continue
+		}
+		n, err := container.daemon.networks.Get(prev.NetworkID)
+		if err != nil {
+			logrus.Warnf("Not connecting %s to network %s: %v", container.ID, name, err)
+			continue
+		}
+		ep, err := bridge.AllocateEndpoint(n.ID, container.ID, "", prev.IPAddress, false)
+		if err != nil {
+			return err
+		}
+		if settings.Networks == nil {
+			settings.Networks = make(map[string]*network.EndpointSettings)
+		}
+		settings.Networks[name] = ep
+	}
+	return nil
+}
+
+// releaseInterfaces frees every address held by the container on the
+// default bridge and on user-defined networks.
+func (container *Container) releaseInterfaces(settings *network.Settings) {
+	if !container.hostConfig.NetworkMode.IsUserDefined() {
+		bridge.Release(container.ID)
+	}
+	for _, ep := range settings.Networks {
+		bridge.ReleaseEndpoint(ep.NetworkID, container.ID)
+	}
+}
+
+// extraInterfaces returns the interfaces of the additional networks, in a
+// stable order so they keep their names inside the container.
+func (container *Container) extraInterfaces() []*network.EndpointSettings {
+	var (
+		primary = container.hostConfig.NetworkMode.NetworkName()
+		names   []string
+	)
+	for name, ep := range container.NetworkSettings.Networks {
+		if name != primary && ep.IPAddress != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var eps []*network.EndpointSettings
+	for _, name := range names {
+		eps = append(eps, container.NetworkSettings.Networks[name])
+	}
+	return eps
+}
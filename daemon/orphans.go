@@ -0,0 +1,243 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/pkg/mount"
+)
+
+// validID matches the ids of the containers and images. The layers of the
+// graph driver are named after them, with an -init suffix for the init
+// layers of the containers.
+var validID = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// layerOwner returns the id of the container or image of the layer id, or
+// "" if it is not a layer of docker.
+func layerOwner(id string) string {
+	id = strings.TrimSuffix(id, "-init")
+	if !validID.MatchString(id) {
+		return ""
+	}
+	return id
+}
+
+// orphanNames returns the paths of the names of entities, the ids of the
+// container graph by path, whose container does not exist.
+func orphanNames(entities map[string]string, containers map[string]bool) []string {
+	var orphans []string
+	for p, id := range entities {
+		if !containers[id] {
+			orphans = append(orphans, p)
+		}
+	}
+	sort.Strings(orphans)
+
+	// Removing a name removes the links below it
+	var top []string
+	for _, p := range orphans {
+		if len(top) == 0 || !strings.HasPrefix(p, top[len(top)-1]+"/") {
+			top = append(top, p)
+		}
+	}
+	return top
+}
+
+// orphanContainers returns the containers of the exec driver ids which are
+// not running. The ones which are not named after a container are left
+// alone, they do not belong to docker.
+func orphanContainers(ids []string, running map[string]bool) []string {
+	var orphans []string
+	for _, id := range ids {
+		if validID.MatchString(id) && !running[id] {
+			orphans = append(orphans, id)
+		}
+	}
+	sort.Strings(orphans)
+	return orphans
+}
+
+// staleMounts returns the mountpoints under root of the layers of the
+// containers which are not running, the deepest first so that they can be
+// unmounted in order.
+func staleMounts(root string, mountpoints []string, running map[string]bool) []string {
+	var stale []string
+	for _, mp := range mountpoints {
+		rel, err := filepath.Rel(root, mp)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+			if owner := layerOwner(elem); owner != "" {
+				if !running[owner] {
+					stale = append(stale, mp)
+				}
+				break
+			}
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(stale)))
+	return stale
+}
+
+// orphanLayers returns the layers which belong to neither an image nor a
+// container.
+func orphanLayers(layers []string, images, containers map[string]bool) []string {
+	var orphans []string
+	for _, id := range layers {
+		owner := layerOwner(id)
+		if owner == "" || images[id] || containers[owner] {
+			continue
+		}
+		orphans = append(orphans, id)
+	}
+	sort.Strings(orphans)
+	return orphans
+}
+
+// cleanupOrphans finds what a crash of the daemon left behind: the names,
+// the containers of the exec driver, the mounts and the layers of the
+// containers and images which do not exist anymore, or which are not
+// running. They are removed unless dryRun is set, and only reported.
+func (daemon *Daemon) cleanupOrphans(dryRun bool) *types.OrphansReport {
+	report := &types.OrphansReport{DryRun: dryRun}
+	action := "Removing"
+	if dryRun {
+		action = "Found"
+	}
+	fail := func(format string, args ...interface{}) {
+		logrus.Errorf(format, args...)
+		report.Errors = append(report.Errors, fmt.Sprintf(format, args...))
+	}
+
+	// Containers which could not be loaded are known all the same, not to
+	// lose their layers.
+	containers := make(map[string]bool)
+	dir, err := ioutil.ReadDir(daemon.repository)
+	if err != nil {
+		fail("Unable to list the containers: %v", err)
+		return report
+	}
+	for _, fi := range dir {
+		containers[fi.Name()] = true
+	}
+	running := make(map[string]bool)
+	for _, c := range daemon.List() {
+		if c.IsRunning() {
+			running[c.ID] = true
+		}
+	}
+
+	entities := make(map[string]string)
+	for p, e := range daemon.containerGraph.List("/", -1) {
+		entities[p] = e.ID()
+	}
+	for _, p := range orphanNames(entities, containers) {
+		logrus.Infof("%s orphan name %s", action, p)
+		report.Names = append(report.Names, p)
+		if !dryRun {
+			if err := daemon.containerGraph.Delete(p); err != nil {
+				fail("Unable to remove the name %s: %v", p, err)
+			}
+		}
+	}
+
+	if lister, ok := daemon.execDriver.(execdriver.Lister); ok {
+		ids, err := lister.List()
+		if err != nil {
+			fail("Unable to list the containers of the %s exec driver: %v", daemon.execDriver.Name(), err)
+		}
+		for _, id := range orphanContainers(ids, running) {
+			logrus.Infof("%s orphan %s container %s", action, daemon.execDriver.Name(), id)
+			report.Containers = append(report.Containers, id)
+			if !dryRun {
+				if err := daemon.execDriver.Terminate(&execdriver.Command{ID: id}); err != nil {
+					fail("Unable to terminate the container %s: %v", id, err)
+				}
+				if err := daemon.execDriver.Clean(id); err != nil {
+					fail("Unable to clean the container %s: %v", id, err)
+				}
+			}
+		}
+	}
+
+	mounts, err := mount.GetMounts()
+	if err != nil {
+		fail("Unable to list the mounts: %v", err)
+	}
+	var mountpoints []string
+	for _, m := range mounts {
+		mountpoints = append(mountpoints, m.Mountpoint)
+	}
+	for _, mp := range staleMounts(daemon.config.Root, mountpoints, running) {
+		logrus.Infof("%s stale mount %s", action, mp)
+		report.Mounts = append(report.Mounts, mp)
+		if !dryRun {
+			if err := mount.Unmount(mp); err != nil {
+				fail("Unable to unmount %s: %v", mp, err)
+			}
+		}
+	}
+
+	if lister, ok := daemon.driver.(graphdriver.LayerLister); ok {
+		layers, err := lister.Layers()
+		if err != nil {
+			fail("Unable to list the layers of the %s storage driver: %v", daemon.driver, err)
+		}
+		images, err := daemon.graph.Map()
+		if err != nil {
+			fail("Unable to list the images: %v", err)
+			// Without the images, every image layer would be an orphan
+			return report
+		}
+		known := make(map[string]bool, len(images))
+		for id := range images {
+			known[id] = true
+		}
+		orphans := orphanLayers(layers, known, containers)
+		for _, id := range orphans {
+			logrus.Infof("%s orphan layer %s", action, id)
+		}
+		report.Layers = orphans
+		if !dryRun {
+			daemon.removeLayers(orphans, fail)
+		}
+	}
+	return report
+}
+
+// removeLayers removes the layers ids. Some drivers can not remove a layer
+// before its children, which may come after it: the layers which fail are
+// tried again as long as others are removed.
+func (daemon *Daemon) removeLayers(ids []string, fail func(string, ...interface{})) {
+	errs := make(map[string]error)
+	for len(ids) > 0 {
+		var left []string
+		for _, id := range ids {
+			if err := daemon.driver.Remove(id); err != nil {
+				errs[id] = err
+				left = append(left, id)
+			}
+		}
+		if len(left) == len(ids) {
+			break
+		}
+		ids = left
+	}
+	for _, id := range ids {
+		fail("Unable to remove the layer %s: %v", id, errs[id])
+	}
+}
+
+// Orphans returns the report of the orphans found when the daemon started.
+func (daemon *Daemon) Orphans() *types.OrphansReport {
+	return daemon.orphans
+}
@@ -0,0 +1,79 @@
+package daemon
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var (
+	orphanID    = strings.Repeat("a", 64)
+	stoppedID   = strings.Repeat("b", 64)
+	runningID   = strings.Repeat("c", 64)
+	imageID     = strings.Repeat("d", 64)
+	testRunning = map[string]bool{runningID: true}
+	testKnown   = map[string]bool{stoppedID: true, runningID: true}
+)
+
+func TestOrphanNames(t *testing.T) {
+	entities := map[string]string{
+		"/web":         stoppedID,
+		"/db":          orphanID,
+		"/db/cache":    runningID,
+		"/web/db":      orphanID,
+		"/dashboard":   runningID,
+		"/dashboard/x": orphanID,
+	}
+	expected := []string{"/dashboard/x", "/db", "/web/db"}
+	if names := orphanNames(entities, testKnown); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+}
+
+func TestOrphanContainers(t *testing.T) {
+	ids := []string{runningID, "www", stoppedID, orphanID}
+	expected := []string{orphanID, stoppedID}
+	if orphans := orphanContainers(ids, testRunning); !reflect.DeepEqual(orphans, expected) {
+		t.Fatalf("Expected %v, got %v", expected, orphans)
+	}
+}
+
+func TestStaleMounts(t *testing.T) {
+	mountpoints := []string{
+		"/",
+		"/dk",
+		"/dk/zfs/graph/" + orphanID,
+		"/dk/zfs/graph/" + orphanID + "/dev",
+		"/dk/zfs/graph/" + runningID,
+		"/dk/zfs/graph/" + runningID + "/dev",
+		"/dk/zfs/graph/" + stoppedID + "-init",
+		"/dk/zfs/graph/" + imageID,
+		"/dk/volumes",
+		"/dkother/" + orphanID,
+	}
+	expected := []string{
+		"/dk/zfs/graph/" + imageID,
+		"/dk/zfs/graph/" + stoppedID + "-init",
+		"/dk/zfs/graph/" + orphanID + "/dev",
+		"/dk/zfs/graph/" + orphanID,
+	}
+	if stale := staleMounts("/dk", mountpoints, testRunning); !reflect.DeepEqual(stale, expected) {
+		t.Fatalf("Expected %v, got %v", expected, stale)
+	}
+}
+
+func TestOrphanLayers(t *testing.T) {
+	layers := []string{
+		imageID,
+		stoppedID,
+		stoppedID + "-init",
+		orphanID + "-init",
+		orphanID,
+		"volumes",
+	}
+	expected := []string{orphanID, orphanID + "-init"}
+	images := map[string]bool{imageID: true}
+	if orphans := orphanLayers(layers, images, testKnown); !reflect.DeepEqual(orphans, expected) {
+		t.Fatalf("Expected %v, got %v", expected, orphans)
+	}
+}
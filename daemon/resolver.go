@@ -0,0 +1,147 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/network"
+	"github.com/docker/docker/pkg/dnsserver"
+	"github.com/docker/docker/pkg/resolvconf"
+	"github.com/docker/docker/pkg/stringid"
+)
+
+// startResolver runs the DNS server of a user-defined network on the
+// network's gateway. Containers on the network use it to look up each
+// other by name, so lookups keep working when a container gets a new
+// address after a restart.
+func (daemon *Daemon) startResolver(n *network.Network) error {
+	s, err := dnsserver.Listen(net.JoinHostPort(n.Gateway, "53"), daemon.resolverLookup(n), daemon.resolverUpstreams)
+	if err != nil {
+		return fmt.Errorf("Failed to start DNS server for network %s: %v", n.Name, err)
+	}
+
+	daemon.networks.Lock()
+	daemon.networks.resolvers[n.ID] = s
+	daemon.networks.Unlock()
+	return nil
+}
+
+// hasResolver returns true if the DNS server of the network is running.
+func (daemon *Daemon) hasResolver(n *network.Network) bool {
+	daemon.networks.Lock()
+	_, exists := daemon.networks.resolvers[n.ID]
+	daemon.networks.Unlock()
+	return exists
+}
+
+func (daemon *Daemon) stopResolver(n *network.Network) {
+	daemon.networks.Lock()
+	s, exists := daemon.networks.resolvers[n.ID]
+	delete(daemon.networks.resolvers, n.ID)
+	daemon.networks.Unlock()
+
+	if exists {
+		if err := s.Close(); err != nil {
+			logrus.Errorf("Failed to stop DNS server for network %s: %v", n.Name, err)
+		}
+	}
+}
+
+// resolverLookup answers the names of the running containers attached to
+// the network: their name, hostname and short ID. The aliases of the links
+// of the container sending the query are answered as well, as long as the
+// linked container is on the same network.
+func (daemon *Daemon) resolverLookup(n *network.Network) dnsserver.LookupFunc {
+	return func(client net.IP, name string) ([]net.IP, bool) {
+		containers := daemon.networkContainers(n)
+
+		for _, c := range containers {
+			if ip := endpointIP(c, n); ip == nil || !ip.Equal(client) {
+				continue
+			}
+			children, err := daemon.Children(c.Name)
+			if err != nil {
+				break
+			}
+			for p, child := range children {
+				if strings.ToLower(path.Base(p)) != name {
+					continue
+				}
+				if ip := endpointIP(child, n); ip != nil {
+					return []net.IP{ip}, true
+				}
+			}
+			break
+		}
+
+		var ips []net.IP
+		for _, c := range containers {
+			ip := endpointIP(c, n)
+			if ip == nil {
+				continue
+			}
+			if strings.ToLower(strings.TrimPrefix(c.Name, "/")) == name ||
+				strings.ToLower(c.Config.Hostname) == name ||
+				stringid.TruncateID(c.ID) == name {
+				ips = append(ips, ip)
+			}
+		}
+		return ips, len(ips) > 0
+	}
+}
+
+// resolverUpstreams returns the servers queries for other names are
+// forwarded to: the --dns servers of the container sending the query, or
+// of the daemon, or else the nameservers of the host.
+func (daemon *Daemon) resolverUpstreams(client net.IP) []string {
+	dns := daemon.config.Dns
+	for _, c := range daemon.List() {
+		if c.hostConfig == nil || len(c.hostConfig.Dns) == 0 || !c.hasAddress(client) {
+			continue
+		}
+		dns = c.hostConfig.Dns
+		break
+	}
+	if len(dns) == 0 {
+		resolvConf, err := resolvconf.Get()
+		if err != nil {
+			logrus.Errorf("Failed to read the host's resolv.conf: %v", err)
+			return nil
+		}
+		dns = resolvconf.GetNameservers(resolvConf)
+	}
+
+	var upstreams []string
+	for _, ns := range dns {
+		upstreams = append(upstreams, net.JoinHostPort(ns, "53"))
+	}
+	return upstreams
+}
+
+// endpointIP returns the address of the container on the network, or nil
+// if the container is not running.
+func endpointIP(c *Container, n *network.Network) net.IP {
+	if !c.IsRunning() || c.NetworkSettings == nil {
+		return nil
+	}
+	ep, exists := c.NetworkSettings.Networks[n.Name]
+	if !exists || ep.NetworkID != n.ID {
+		return nil
+	}
+	return net.ParseIP(ep.IPAddress)
+}
+
+func (container *Container) hasAddress(ip net.IP) bool {
+	if container.NetworkSettings == nil {
+		return false
+	}
+	for _, ep := range container.NetworkSettings.Networks {
+		if ip.Equal(net.ParseIP(ep.IPAddress)) {
+			return true
+		}
+	}
+	return false
+}
@@ -7,6 +7,9 @@ func (daemon *Daemon) ContainerRestart(name string, seconds int) error {
 	if err != nil {
 		return err
 	}
+	if seconds < 0 {
+		seconds = container.StopTimeout()
+	}
 	if err := container.Restart(seconds); err != nil {
 		return fmt.Errorf("Cannot restart container %s: %s\n", name, err)
 	}
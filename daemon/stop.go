@@ -2,6 +2,9 @@ package daemon
 
 import "fmt"
 
+// ContainerStop stops the container with its stop signal, and kills it if
+// it has not exited after seconds. A negative seconds stands for the stop
+// timeout of the container.
 func (daemon *Daemon) ContainerStop(name string, seconds int) error {
 	container, err := daemon.Get(name)
 	if err != nil {
@@ -10,9 +13,13 @@ func (daemon *Daemon) ContainerStop(name string, seconds int) error {
 	if !container.IsRunning() {
 		return fmt.Errorf("Container already stopped")
 	}
+	if seconds < 0 {
+		seconds = container.StopTimeout()
+	}
 	if err := container.Stop(seconds); err != nil {
 		return fmt.Errorf("Cannot stop container %s: %s\n", name, err)
 	}
+	container.setManuallyStopped()
 	container.LogEvent("stop")
 	return nil
 }
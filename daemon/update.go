@@ -0,0 +1,46 @@
+package daemon
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/runconfig"
+)
+
+// ContainerUpdate updates the resources and the restart policy of the
+// container name. The exec driver applies them to a running container, and
+// they are kept in its host config for its next start.
+func (daemon *Daemon) ContainerUpdate(name string, config *runconfig.UpdateConfig) ([]string, error) {
+	container, err := daemon.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings, err := container.Update(config)
+	if err != nil {
+		return warnings, fmt.Errorf("Cannot update container %s: %s", name, err)
+	}
+	container.LogEvent("update")
+
+	return warnings, nil
+}
+
+// verifyRestartPolicy returns an error if policy is not a restart policy
+// known to the daemon. An empty name stands for no policy.
+func verifyRestartPolicy(policy runconfig.RestartPolicy) error {
+	switch policy.Name {
+	case "", "no", "always", "unless-stopped":
+		if policy.MaximumRetryCount != 0 {
+			return fmt.Errorf("Maximum restart count not valid with restart policy of %q", policy.Name)
+		}
+	case "on-failure":
+		if policy.MaximumRetryCount < 0 {
+			return fmt.Errorf("Maximum restart count must be a positive integer")
+		}
+	default:
+		return fmt.Errorf("Invalid restart policy %s", policy.Name)
+	}
+	if policy.Delay < 0 || policy.MaximumDelay < 0 {
+		return fmt.Errorf("Restart delays cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/runconfig"
+)
+
+func TestVerifyRestartPolicy(t *testing.T) {
+	for _, policy := range []runconfig.RestartPolicy{
+		{},
+		{Name: "no"},
+		{Name: "always"},
+		{Name: "on-failure"},
+		{Name: "on-failure", MaximumRetryCount: 5},
+		{Name: "unless-stopped", Delay: time.Second, MaximumDelay: time.Minute},
+	} {
+		if err := verifyRestartPolicy(policy); err != nil {
+			t.Fatalf("Unexpected error for %+v: %v", policy, err)
+		}
+	}
+	for _, policy := range []runconfig.RestartPolicy{
+		{Name: "sometimes"},
+		{Name: "always", MaximumRetryCount: 5},
+		{Name: "on-failure", MaximumRetryCount: -1},
+		{Name: "unless-stopped", MaximumRetryCount: 5},
+		{Name: "always", Delay: -time.Second},
+	} {
+		if err := verifyRestartPolicy(policy); err == nil {
+			t.Fatalf("Expected an error for %+v", policy)
+		}
+	}
+}
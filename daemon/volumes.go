@@ -9,15 +9,19 @@ import (
 	"strings"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/daemon/execdriver"
 	"github.com/docker/docker/pkg/chrootarchive"
 	"github.com/docker/docker/pkg/mount"
+	"github.com/docker/docker/pkg/stringid"
 	"github.com/docker/docker/pkg/symlink"
+	"github.com/docker/docker/volumes"
 )
 
 type volumeMount struct {
 	containerPath string
 	hostPath      string
+	name          string
 	writable      bool
 	copyData      bool
 	from          string
@@ -112,7 +116,12 @@ func (container *Container) createVolumes() error {
 		}
 
 		// Create the actual volume
-		v, err := container.daemon.volumes.FindOrCreateVolume(mnt.hostPath, mnt.writable)
+		var v *volumes.Volume
+		if mnt.name != "" {
+			v, err = container.daemon.volumes.FindOrCreateNamedVolume(mnt.name, container.hostConfig.VolumeDriver)
+		} else {
+			v, err = container.daemon.volumes.FindOrCreateVolume(mnt.hostPath, mnt.writable)
+		}
 		if err != nil {
 			return err
 		}
@@ -124,7 +133,9 @@ func (container *Container) createVolumes() error {
 			container.AppliedVolumesFrom[mnt.from] = struct{}{}
 		}
 
-		if mnt.writable && mnt.copyData {
+		// Plugin volumes are only mounted when the container starts, so
+		// there is nothing to copy the image's content into yet.
+		if mnt.writable && mnt.copyData && v.IsLocal() {
 			// Copy whatever is in the container at the containerPath to the volume
 			copyExistingContents(containerMntPath, v.Path)
 		}
@@ -173,6 +184,17 @@ func (container *Container) registerVolumes() {
 	}
 }
 
+// releaseVolumes tells the drivers of the plugin volumes mounted by
+// setupMounts that the container no longer uses them.
+func (container *Container) releaseVolumes() {
+	for _, v := range container.pluginVolumes {
+		if err := v.Unmount(); err != nil {
+			logrus.Errorf("%v: Failed to unmount volume %s: %v", container.ID, v.Name, err)
+		}
+	}
+	container.pluginVolumes = nil
+}
+
 func (container *Container) derefVolumes() {
 	for path := range container.VolumePaths() {
 		vol := container.daemon.volumes.Get(path)
@@ -201,6 +223,16 @@ func parseBindMountSpec(spec string) (*volumeMount, error) {
 		return nil, fmt.Errorf("Invalid volume specification: %s", spec)
 	}
 
+	// A host part which is not a path refers to a named volume, which
+	// is created on first use and filled with the image's content.
+	if volumes.IsValidName(mnt.hostPath) {
+		mnt.name = mnt.hostPath
+		mnt.hostPath = ""
+		mnt.copyData = true
+		mnt.containerPath = filepath.Clean(mnt.containerPath)
+		return mnt, nil
+	}
+
 	if !filepath.IsAbs(mnt.hostPath) {
 		return nil, fmt.Errorf("cannot bind mount volume: %s volume paths must be absolute.", mnt.hostPath)
 	}
@@ -261,8 +293,19 @@ func (container *Container) setupMounts() error {
 	// want this new mount in the container
 	// These mounts must be ordered based on the length of the path that it is being mounted to (lexicographic)
 	for _, path := range container.sortedVolumeMounts() {
+		source := container.Volumes[path]
+		if v := container.daemon.volumes.Get(source); v != nil && !v.IsLocal() {
+			mountpoint, err := v.Mount()
+			if err != nil {
+				// Do not leave the volumes mounted so far behind
+				container.releaseVolumes()
+				return err
+			}
+			container.pluginVolumes = append(container.pluginVolumes, v)
+			source = mountpoint
+		}
 		mounts = append(mounts, execdriver.Mount{
-			Source:      container.Volumes[path],
+			Source:      source,
 			Destination: path,
 			Writable:    container.VolumesRW[path],
 		})
@@ -366,3 +409,91 @@ func (container *Container) unmountVolumes() {
 		}
 	}
 }
+
+// VolumeCreate creates a named volume with the given volume driver. If name
+// is empty a random name is generated.
+func (daemon *Daemon) VolumeCreate(name, driverName string) (*types.Volume, error) {
+	if name == "" {
+		name = stringid.GenerateRandomID()
+	}
+	v, err := daemon.volumes.Create(name, driverName)
+	if err != nil {
+		return nil, err
+	}
+	vol := volumeToAPIType(v)
+	daemon.logVolumeEvent(vol, "create")
+	return vol, nil
+}
+
+// VolumeInspect looks up a volume by name, or by ID for volumes created
+// implicitly by containers.
+func (daemon *Daemon) VolumeInspect(name string) (*types.Volume, error) {
+	v, err := daemon.getVolume(name)
+	if err != nil {
+		return nil, err
+	}
+	return volumeToAPIType(v), nil
+}
+
+// Volumes lists every volume managed by the daemon. Host directories
+// bind-mounted into containers are not included.
+func (daemon *Daemon) Volumes() []*types.Volume {
+	var vols []*types.Volume
+	for _, v := range daemon.volumes.List() {
+		if v.IsBindMount {
+			continue
+		}
+		vols = append(vols, volumeToAPIType(v))
+	}
+	return vols
+}
+
+// VolumeRm removes a volume. Volumes still in use by a container cannot be
+// removed.
+func (daemon *Daemon) VolumeRm(name string) error {
+	v, err := daemon.getVolume(name)
+	if err != nil {
+		return err
+	}
+	if containers := v.Containers(); len(containers) > 0 {
+		return fmt.Errorf("Conflict: volume %s is in use by containers %s", name, containers)
+	}
+	if err := daemon.volumes.Delete(v.Path); err != nil {
+		return err
+	}
+	daemon.logVolumeEvent(volumeToAPIType(v), "destroy")
+	return nil
+}
+
+// logVolumeEvent logs the event action of the volume, with its driver.
+func (daemon *Daemon) logVolumeEvent(v *types.Volume, action string) {
+	daemon.EventsService.Log(action, types.VolumeEventType, types.EventActor{
+		ID:         v.Name,
+		Attributes: map[string]string{"driver": v.Driver},
+	})
+}
+
+func (daemon *Daemon) getVolume(name string) (*volumes.Volume, error) {
+	if v := daemon.volumes.GetByName(name); v != nil {
+		return v, nil
+	}
+	for _, v := range daemon.volumes.List() {
+		if !v.IsBindMount && v.ID == name {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("no such volume: %s", name)
+}
+
+func volumeToAPIType(v *volumes.Volume) *types.Volume {
+	name := v.Name
+	if name == "" {
+		name = v.ID
+	}
+	return &types.Volume{
+		Name:       name,
+		Driver:     v.DriverName(),
+		Mountpoint: v.Path,
+		Containers: v.Containers(),
+	}
+}
@@ -11,3 +11,7 @@ const CanDaemon = false
 func mainDaemon() {
 	log.Fatal("This is a client-only binary - running the Docker daemon is not supported.")
 }
+
+func loadConfigFile() error {
+	return nil
+}
@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	gosignal "os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -27,8 +30,12 @@ import (
 const CanDaemon = true
 
 var (
-	daemonCfg   = &daemon.Config{}
-	registryCfg = &registry.Options{}
+	daemonCfg    = &daemon.Config{}
+	registryCfg  = &registry.Options{}
+	flConfigFile = flag.String([]string{"-config-file"}, "", "Daemon configuration file")
+
+	// configFileOptions are the options read from the configuration file
+	configFileOptions map[string]interface{}
 )
 
 func init() {
@@ -79,6 +86,65 @@ func migrateKey() (err error) {
 	return nil
 }
 
+// loadConfigFile sets the daemon flags which are not set on the command
+// line to the options of the configuration file, if any.
+func loadConfigFile() error {
+	if *flConfigFile == "" {
+		return nil
+	}
+	options, err := daemon.ReadConfigFile(*flConfigFile)
+	if err != nil {
+		return err
+	}
+	if err := daemon.MergeConfigFile(flag.CommandLine, options); err != nil {
+		return err
+	}
+	configFileOptions = options
+	return nil
+}
+
+// reloadConfigFile reads the configuration file again and applies the
+// options which can change while the daemon runs. It returns the new
+// configuration, or current if the file is invalid.
+func reloadConfigFile(d *daemon.Daemon, current *daemon.ReloadConfig) *daemon.ReloadConfig {
+	options, err := daemon.ReadConfigFile(*flConfigFile)
+	if err != nil {
+		logrus.Errorf("Unable to reload the configuration: %v", err)
+		return current
+	}
+	config, err := daemon.ParseReloadConfig(flag.CommandLine, options, current)
+	if err != nil {
+		logrus.Errorf("Unable to reload the configuration: %v", err)
+		return current
+	}
+	for _, name := range daemon.IgnoredOptions(configFileOptions, options) {
+		logrus.Warnf("The %s option of the configuration file changed, it is applied when the daemon restarts", name)
+	}
+	configFileOptions = options
+
+	if lvl, err := logrus.ParseLevel(config.LogLevel); err == nil {
+		setLogLevel(lvl)
+	}
+	d.Reload(config)
+	if changes := current.Changes(config); len(changes) > 0 {
+		logrus.Infof("Reloaded the configuration: %s", strings.Join(changes, ", "))
+	} else {
+		logrus.Info("Reloaded the configuration: nothing changed")
+	}
+	return config
+}
+
+// setupReloadTrap reloads the configuration file on SIGHUP.
+func setupReloadTrap(d *daemon.Daemon, current *daemon.ReloadConfig) {
+	c := make(chan os.Signal, 1)
+	gosignal.Notify(c, syscall.SIGHUP)
+	go func() {
+		for range c {
+			current = reloadConfigFile(d, current)
+		}
+	}()
+}
+
 func mainDaemon() {
 	if flag.NArg() != 0 {
 		flag.Usage()
@@ -134,6 +200,14 @@ func mainDaemon() {
 	}
 	daemonCfg.TrustKeyPath = *flTrustKey
 
+	// registry.NewService adds the default insecure registries to registryCfg
+	reloadConfig := &daemon.ReloadConfig{
+		Labels:             daemonCfg.Labels,
+		LogLevel:           logrus.GetLevel().String(),
+		LogConfig:          daemonCfg.LogConfig,
+		Mirrors:            registryCfg.Mirrors.GetAll(),
+		InsecureRegistries: registryCfg.InsecureRegistries.GetAll(),
+	}
 	registryService := registry.NewService(registryCfg)
 	d, err := daemon.NewDaemon(daemonCfg, registryService)
 	if err != nil {
@@ -165,6 +239,10 @@ func mainDaemon() {
 		}
 	})
 
+	if *flConfigFile != "" {
+		setupReloadTrap(d, reloadConfig)
+	}
+
 	// after the daemon is done setting up we can tell the api to start
 	// accepting connections with specified daemon
 	api.AcceptConnections(d)
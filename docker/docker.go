@@ -38,6 +38,13 @@ func main() {
 	flag.Parse()
 	// FIXME: validate daemon flags here
 
+	if *flDaemon {
+		if err := loadConfigFile(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+	}
+
 	if *flVersion {
 		showVersion()
 		return
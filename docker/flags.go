@@ -47,6 +47,7 @@ var (
 		{"login", "Register or log in to a Docker registry server"},
 		{"logout", "Log out from a Docker registry server"},
 		{"logs", "Fetch the logs of a container"},
+		{"network", "Manage Docker networks"},
 		{"port", "Lookup the public-facing port that is NAT-ed to PRIVATE_PORT"},
 		{"pause", "Pause all processes within a container"},
 		{"ps", "List containers"},
@@ -65,7 +66,9 @@ var (
 		{"tag", "Tag an image into a repository"},
 		{"top", "Lookup the running processes of a container"},
 		{"unpause", "Unpause a paused container"},
+		{"update", "Update the resources of one or more containers"},
 		{"version", "Show the Docker version information"},
+		{"volume", "Manage Docker volumes"},
 		{"wait", "Block until a container stops, then print its exit code"},
 	}
 )
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/httputils"
 	"github.com/docker/docker/pkg/progressreader"
@@ -73,6 +74,6 @@ func (s *TagStore) Import(src string, repo string, tag string, imageImportConfig
 		logID = utils.ImageReference(logID, tag)
 	}
 
-	s.eventsService.Log("import", logID, "")
+	s.eventsService.Log("import", types.ImageEventType, types.EventActor{ID: logID})
 	return nil
 }
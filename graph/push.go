@@ -12,6 +12,7 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/cliconfig"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/ioutils"
@@ -535,7 +536,7 @@ func (s *TagStore) Push(localName string, imagePushConfig *ImagePushConfig) erro
 	if repoInfo.Index.Official || endpoint.Version == registry.APIVersion2 {
 		err := s.pushV2Repository(r, localRepo, imagePushConfig.OutStream, repoInfo, imagePushConfig.Tag, sf)
 		if err == nil {
-			s.eventsService.Log("push", repoInfo.LocalName, "")
+			s.eventsService.Log("push", types.ImageEventType, types.EventActor{ID: repoInfo.LocalName})
 			return nil
 		}
 
@@ -547,7 +548,7 @@ func (s *TagStore) Push(localName string, imagePushConfig *ImagePushConfig) erro
 	if err := s.pushRepository(r, imagePushConfig.OutStream, repoInfo, localRepo, imagePushConfig.Tag, sf); err != nil {
 		return err
 	}
-	s.eventsService.Log("push", repoInfo.LocalName, "")
+	s.eventsService.Log("push", types.ImageEventType, types.EventActor{ID: repoInfo.LocalName})
 	return nil
 
 }
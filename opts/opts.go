@@ -172,6 +172,11 @@ func newMapOpt(values map[string]string, validator ValidatorFctType) *MapOpts {
 	}
 }
 
+// NewMapOpts returns the MapOpts setting values, for the flags of a FlagSet.
+func NewMapOpts(values map[string]string, validator ValidatorFctType) *MapOpts {
+	return newMapOpt(values, validator)
+}
+
 // Validators
 type ValidatorFctType func(val string) (string, error)
 type ValidatorFctListType func(val string) ([]string, error)
@@ -0,0 +1,238 @@
+// Package dnsserver implements a minimal DNS responder. It answers A queries
+// for the names it knows about and forwards every other query, unmodified,
+// to upstream servers. Only UDP is supported.
+package dnsserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	typeA    = 1
+	classIN  = 1
+	rcodeOK  = 0
+	rcodeErr = 2 // SERVFAIL
+
+	headerLen = 12
+	// answerTTL is kept short as container addresses change on restart
+	answerTTL = 10
+	// maxPacket is the largest UDP packet accepted from clients and
+	// upstream servers
+	maxPacket = 65535
+)
+
+var (
+	// ForwardTimeout is how long an upstream server is given to answer
+	// before the next one is tried.
+	ForwardTimeout = 2 * time.Second
+
+	errMalformed = errors.New("malformed DNS message")
+)
+
+// LookupFunc resolves name on behalf of the client which sent the query.
+// It returns false if the name is unknown, in which case the query is
+// forwarded upstream. name is lower case and has no trailing dot.
+type LookupFunc func(client net.IP, name string) ([]net.IP, bool)
+
+// UpstreamFunc returns the servers, as host:port, the queries of client
+// which cannot be answered locally are forwarded to.
+type UpstreamFunc func(client net.IP) []string
+
+// Server is a DNS responder listening on a UDP socket.
+type Server struct {
+	lookup    LookupFunc
+	upstreams UpstreamFunc
+	conn      *net.UDPConn
+}
+
+// Listen starts a server answering queries received on addr.
+func Listen(addr string, lookup LookupFunc, upstreams UpstreamFunc) (*Server, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{lookup: lookup, upstreams: upstreams, conn: conn}
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	return s.conn.Close()
+}
+
+func (s *Server) serve() {
+	buf := make([]byte, maxPacket)
+	for {
+		n, client, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		query := make([]byte, n)
+		copy(query, buf[:n])
+		go s.handle(client, query)
+	}
+}
+
+func (s *Server) handle(client *net.UDPAddr, query []byte) {
+	resp, err := s.answer(client.IP, query)
+	if err != nil {
+		logrus.Debugf("dns: dropping query from %s: %v", client, err)
+		return
+	}
+	if _, err := s.conn.WriteToUDP(resp, client); err != nil {
+		logrus.Debugf("dns: failed to reply to %s: %v", client, err)
+	}
+}
+
+// answer builds the response to query, either from the local names or
+// from the first upstream server which replies.
+func (s *Server) answer(client net.IP, query []byte) ([]byte, error) {
+	if len(query) < headerLen {
+		return nil, errMalformed
+	}
+
+	opcode := (query[2] >> 3) & 0xf
+	qdcount := binary.BigEndian.Uint16(query[4:6])
+	if opcode == 0 && qdcount == 1 {
+		name, qtype, qclass, end, err := parseQuestion(query, headerLen)
+		if err != nil {
+			return nil, err
+		}
+		if qclass == classIN {
+			if ips, ok := s.lookup(client, name); ok {
+				var answers []net.IP
+				if qtype == typeA {
+					for _, ip := range ips {
+						if ip4 := ip.To4(); ip4 != nil {
+							answers = append(answers, ip4)
+						}
+					}
+				}
+				// Known names get an empty answer for other record
+				// types so clients do not go looking upstream.
+				return buildResponse(query, end, rcodeOK, answers), nil
+			}
+		}
+	}
+
+	resp, err := s.forward(client, query)
+	if err == nil {
+		return resp, nil
+	}
+	logrus.Debugf("dns: failed to forward query: %v", err)
+
+	end := headerLen
+	if qdcount == 1 {
+		if _, _, _, e, err := parseQuestion(query, headerLen); err == nil {
+			end = e
+		}
+	}
+	return buildResponse(query, end, rcodeErr, nil), nil
+}
+
+func (s *Server) forward(client net.IP, query []byte) ([]byte, error) {
+	var (
+		buf     = make([]byte, maxPacket)
+		lastErr = errors.New("no upstream DNS servers")
+	)
+	for _, upstream := range s.upstreams(client) {
+		conn, err := net.DialTimeout("udp", upstream, ForwardTimeout)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		conn.SetDeadline(time.Now().Add(ForwardTimeout))
+		if _, err := conn.Write(query); err != nil {
+			conn.Close()
+			lastErr = err
+			continue
+		}
+		n, err := conn.Read(buf)
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return buf[:n], nil
+	}
+	return nil, lastErr
+}
+
+// parseQuestion decodes the question starting at off. It returns the
+// queried name and the offset of the end of the question.
+func parseQuestion(msg []byte, off int) (string, uint16, uint16, int, error) {
+	var labels []string
+	for {
+		if off >= len(msg) {
+			return "", 0, 0, 0, errMalformed
+		}
+		l := int(msg[off])
+		off++
+		if l == 0 {
+			break
+		}
+		// Compression pointers are never used by clients in the
+		// question of a query.
+		if l&0xc0 != 0 || off+l > len(msg) {
+			return "", 0, 0, 0, errMalformed
+		}
+		labels = append(labels, string(msg[off:off+l]))
+		off += l
+	}
+	if off+4 > len(msg) {
+		return "", 0, 0, 0, errMalformed
+	}
+	qtype := binary.BigEndian.Uint16(msg[off : off+2])
+	qclass := binary.BigEndian.Uint16(msg[off+2 : off+4])
+	return strings.ToLower(strings.Join(labels, ".")), qtype, qclass, off + 4, nil
+}
+
+// buildResponse creates the response to query, echoing its header and the
+// question which ends at questionEnd, followed by one A record per answer.
+func buildResponse(query []byte, questionEnd int, rcode byte, answers []net.IP) []byte {
+	resp := make([]byte, questionEnd, questionEnd+len(answers)*16)
+	copy(resp, query[:questionEnd])
+
+	// QR, AA and RA set; opcode and RD kept from the query.
+	resp[2] = 0x80 | (query[2] & 0x79) | 0x04
+	resp[3] = 0x80 | rcode
+	if questionEnd > headerLen {
+		binary.BigEndian.PutUint16(resp[4:6], 1)
+	} else {
+		binary.BigEndian.PutUint16(resp[4:6], 0)
+	}
+	binary.BigEndian.PutUint16(resp[6:8], uint16(len(answers)))
+	binary.BigEndian.PutUint16(resp[8:10], 0)
+	binary.BigEndian.PutUint16(resp[10:12], 0)
+
+	for _, ip := range answers {
+		rr := make([]byte, 16)
+		binary.BigEndian.PutUint16(rr[0:2], 0xc000|headerLen) // pointer to the question name
+		binary.BigEndian.PutUint16(rr[2:4], typeA)
+		binary.BigEndian.PutUint16(rr[4:6], classIN)
+		binary.BigEndian.PutUint32(rr[6:10], answerTTL)
+		binary.BigEndian.PutUint16(rr[10:12], 4)
+		copy(rr[12:16], ip)
+		resp = append(resp, rr...)
+	}
+	return resp
+}
@@ -0,0 +1,158 @@
+package dnsserver
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func buildQuery(id uint16, name string, qtype uint16) []byte {
+	msg := make([]byte, headerLen)
+	binary.BigEndian.PutUint16(msg[0:2], id)
+	msg[2] = 0x01 // RD
+	binary.BigEndian.PutUint16(msg[4:6], 1)
+	for _, label := range strings.Split(name, ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(msg[len(msg)-4:], qtype)
+	binary.BigEndian.PutUint16(msg[len(msg)-2:], classIN)
+	return msg
+}
+
+// parseAnswers returns the rcode and the addresses of the A records of a
+// response built by buildResponse or the fake upstream.
+func parseAnswers(t *testing.T, resp []byte) (byte, []string) {
+	if len(resp) < headerLen {
+		t.Fatalf("short response: %v", resp)
+	}
+	if resp[2]&0x80 == 0 {
+		t.Fatal("expected the QR bit to be set")
+	}
+	_, _, _, off, err := parseQuestion(resp, headerLen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ips []string
+	for i := 0; i < int(binary.BigEndian.Uint16(resp[6:8])); i++ {
+		rr := resp[off : off+16]
+		ips = append(ips, net.IP(rr[12:16]).String())
+		off += 16
+	}
+	return resp[3] & 0xf, ips
+}
+
+// startUpstream runs a stand-in upstream resolver on loopback answering
+// every A query with 10.0.0.1.
+func startUpstream(t *testing.T) (*net.UDPConn, chan string) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queried := make(chan string, 10)
+	go func() {
+		buf := make([]byte, maxPacket)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			name, _, _, end, err := parseQuestion(buf[:n], headerLen)
+			if err != nil {
+				continue
+			}
+			queried <- name
+			conn.WriteToUDP(buildResponse(buf[:n], end, rcodeOK, []net.IP{net.ParseIP("10.0.0.1").To4()}), addr)
+		}
+	}()
+	return conn, queried
+}
+
+func exchange(t *testing.T, server net.Addr, query []byte) []byte {
+	conn, err := net.Dial("udp", server.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write(query); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, maxPacket)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binary.BigEndian.Uint16(buf[0:2]) != binary.BigEndian.Uint16(query[0:2]) {
+		t.Fatal("response ID does not match the query")
+	}
+	return buf[:n]
+}
+
+func TestServer(t *testing.T) {
+	upstream, queried := startUpstream(t)
+	defer upstream.Close()
+
+	lookup := func(client net.IP, name string) ([]net.IP, bool) {
+		if name == "web" && client.IsLoopback() {
+			return []net.IP{net.ParseIP("172.18.0.2")}, true
+		}
+		return nil, false
+	}
+	upstreams := func(client net.IP) []string {
+		return []string{upstream.LocalAddr().String()}
+	}
+
+	s, err := Listen("127.0.0.1:0", lookup, upstreams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	rcode, ips := parseAnswers(t, exchange(t, s.Addr(), buildQuery(1, "WEB", typeA)))
+	if rcode != rcodeOK || len(ips) != 1 || ips[0] != "172.18.0.2" {
+		t.Fatalf("expected web to resolve to 172.18.0.2, got rcode %d %v", rcode, ips)
+	}
+
+	// AAAA for a known name is answered locally, without records
+	rcode, ips = parseAnswers(t, exchange(t, s.Addr(), buildQuery(2, "web", 28)))
+	if rcode != rcodeOK || len(ips) != 0 {
+		t.Fatalf("expected an empty answer, got rcode %d %v", rcode, ips)
+	}
+
+	rcode, ips = parseAnswers(t, exchange(t, s.Addr(), buildQuery(3, "docker.com", typeA)))
+	if rcode != rcodeOK || len(ips) != 1 || ips[0] != "10.0.0.1" {
+		t.Fatalf("expected the upstream answer, got rcode %d %v", rcode, ips)
+	}
+	select {
+	case name := <-queried:
+		if name != "docker.com" {
+			t.Fatalf("expected docker.com to be forwarded, got %s", name)
+		}
+	default:
+		t.Fatal("expected the query to be forwarded")
+	}
+}
+
+func TestServerNoUpstream(t *testing.T) {
+	lookup := func(client net.IP, name string) ([]net.IP, bool) {
+		return nil, false
+	}
+	upstreams := func(client net.IP) []string {
+		return nil
+	}
+
+	s, err := Listen("127.0.0.1:0", lookup, upstreams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	rcode, ips := parseAnswers(t, exchange(t, s.Addr(), buildQuery(4, "docker.com", typeA)))
+	if rcode != rcodeErr || len(ips) != 0 {
+		t.Fatalf("expected SERVFAIL, got rcode %d %v", rcode, ips)
+	}
+}
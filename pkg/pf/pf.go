@@ -0,0 +1,353 @@
+// Package pf manages the pf(4) rules of docker on FreeBSD.
+//
+// All the rules live in a single anchor, which is rewritten as a whole
+// with `pfctl -a <anchor> -f -` on every change. The main ruleset has to
+// reference it for the rules to apply:
+//
+//	nat-anchor "docker"
+//	rdr-anchor "docker"
+//	anchor "docker"
+package pf
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// DefaultAnchor is the name of the anchor of the rules of docker.
+const DefaultAnchor = "docker"
+
+// Runner runs pfctl(8) with stdin as standard input and returns its
+// output. It is replaced with a fake in the tests.
+type Runner interface {
+	Run(stdin string, args ...string) ([]byte, error)
+}
+
+type execRunner struct{}
+
+func (execRunner) Run(stdin string, args ...string) ([]byte, error) {
+	logrus.Debugf("pfctl, %v", args)
+
+	cmd := exec.Command("pfctl", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("pfctl failed: pfctl %v: %s (%s)", strings.Join(args, " "), output, err)
+	}
+	return output, nil
+}
+
+// Network is the bridge network of the containers.
+type Network struct {
+	// Bridge is the name of the bridge interface.
+	Bridge string
+	// Subnet is the network of the containers on the bridge.
+	Subnet *net.IPNet
+	// Gateway is the address of the host on the bridge.
+	Gateway net.IP
+	// External is the interface the outgoing traffic is translated on, or
+	// "" not to translate it.
+	External string
+	// ICC allows the containers to communicate with each other. Without
+	// it, only linked containers can.
+	ICC bool
+}
+
+// Bridge is the bridge of a user-defined network. The containers on it
+// cannot reach the other bridges, nor be reached from them.
+type Bridge struct {
+	Name   string
+	Subnet *net.IPNet
+}
+
+// Forward forwards a port of the host to a container.
+type Forward struct {
+	Proto         string
+	HostIP        net.IP
+	HostPort      int
+	ContainerIP   net.IP
+	ContainerPort int
+}
+
+// Link allows the container Parent to reach the port of the container
+// Child.
+type Link struct {
+	Proto  string
+	Parent net.IP
+	Child  net.IP
+	Port   int
+}
+
+// family returns the pf address family of ip.
+func family(ip net.IP) string {
+	if ip.To4() != nil {
+		return "inet"
+	}
+	return "inet6"
+}
+
+// Rules returns the pf.conf(5) rules of the network, the bridges of the
+// user-defined networks, the forwarded ports and the links, in the order pf
+// expects them: translation rules first, then filter rules. network may be
+// nil if the bridge is not managed.
+func Rules(network *Network, bridges []Bridge, forwards []Forward, links []Link) string {
+	var buf bytes.Buffer
+	rule := func(format string, args ...interface{}) {
+		fmt.Fprintf(&buf, format+"\n", args...)
+	}
+
+	if network != nil && network.External != "" {
+		af := family(network.Subnet.IP)
+		rule("nat on %s %s from %s to ! %s -> (%s)", network.External, af, network.Subnet, network.Subnet, network.External)
+		for _, b := range bridges {
+			af := family(b.Subnet.IP)
+			rule("nat on %s %s from %s to ! %s -> (%s)", network.External, af, b.Subnet, b.Subnet, network.External)
+		}
+	}
+	for _, f := range forwards {
+		// Without a host address, only the traffic to the addresses of the
+		// host is forwarded, and not the one of the containers going out.
+		to := "self"
+		if f.HostIP != nil && !f.HostIP.IsUnspecified() {
+			to = f.HostIP.String()
+		}
+		rule("rdr pass %s proto %s from any to %s port %d -> %s port %d", family(f.ContainerIP), f.Proto, to, f.HostPort, f.ContainerIP, f.ContainerPort)
+	}
+
+	// Block the traffic between every two networks of the same family
+	var subnets []*net.IPNet
+	if network != nil && len(bridges) > 0 {
+		subnets = append(subnets, network.Subnet)
+	}
+	for _, b := range bridges {
+		subnets = append(subnets, b.Subnet)
+	}
+	for i, s1 := range subnets {
+		for _, s2 := range subnets[i+1:] {
+			if af := family(s1.IP); af == family(s2.IP) {
+				rule("block drop quick %s from %s to %s", af, s1, s2)
+				rule("block drop quick %s from %s to %s", af, s2, s1)
+			}
+		}
+	}
+
+	if network == nil || network.ICC {
+		return buf.String()
+	}
+	for _, l := range links {
+		rule("pass quick %s proto %s from %s to %s port %d", family(l.Child), l.Proto, l.Parent, l.Child, l.Port)
+	}
+	af := family(network.Subnet.IP)
+	if network.Gateway != nil {
+		rule("pass quick %s from %s to %s", af, network.Subnet, network.Gateway)
+		rule("pass quick %s from %s to %s", af, network.Gateway, network.Subnet)
+	}
+	rule("block drop quick %s from %s to %s", af, network.Subnet, network.Subnet)
+	return buf.String()
+}
+
+// Anchor holds the rules of an anchor and loads them whenever they
+// change. A change is only kept once pf loaded the new rules: if pfctl
+// fails, the anchor keeps the rules pf still has.
+type Anchor struct {
+	Name string
+
+	runner   Runner
+	mu       sync.Mutex // protects network, bridges, forwards and links
+	network  *Network
+	bridges  []Bridge
+	forwards []Forward
+	links    []Link
+}
+
+// NewAnchor returns the anchor called name, loaded with pfctl(8).
+func NewAnchor(name string) *Anchor {
+	return NewAnchorWithRunner(name, execRunner{})
+}
+
+// NewAnchorWithRunner returns the anchor called name, loaded with runner.
+func NewAnchorWithRunner(name string, runner Runner) *Anchor {
+	return &Anchor{Name: name, runner: runner}
+}
+
+// SetNetwork sets the bridge network managed by the anchor.
+func (a *Anchor) SetNetwork(network *Network) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.loadRules(network, a.bridges, a.forwards, a.links); err != nil {
+		return err
+	}
+	a.network = network
+	return nil
+}
+
+// AddBridge adds the rules isolating the bridge b from the other ones.
+func (a *Anchor) AddBridge(b Bridge) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, existing := range a.bridges {
+		if existing.Name == b.Name {
+			return nil
+		}
+	}
+	bridges := append(append([]Bridge(nil), a.bridges...), b)
+	if err := a.loadRules(a.network, bridges, a.forwards, a.links); err != nil {
+		return err
+	}
+	a.bridges = bridges
+	return nil
+}
+
+// RemoveBridge removes the rules isolating the bridge called name.
+func (a *Anchor) RemoveBridge(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, existing := range a.bridges {
+		if existing.Name == name {
+			bridges := append(append([]Bridge(nil), a.bridges[:i]...), a.bridges[i+1:]...)
+			if err := a.loadRules(a.network, bridges, a.forwards, a.links); err != nil {
+				return err
+			}
+			a.bridges = bridges
+			return nil
+		}
+	}
+	return nil
+}
+
+// AddForward adds the rules forwarding the port of f.
+func (a *Anchor) AddForward(f Forward) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	forwards := append(append([]Forward(nil), a.forwards...), f)
+	if err := a.loadRules(a.network, a.bridges, forwards, a.links); err != nil {
+		return err
+	}
+	a.forwards = forwards
+	return nil
+}
+
+// RemoveForward removes the rules forwarding the port of f.
+func (a *Anchor) RemoveForward(f Forward) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, existing := range a.forwards {
+		if existing.equal(f) {
+			forwards := append(append([]Forward(nil), a.forwards[:i]...), a.forwards[i+1:]...)
+			if err := a.loadRules(a.network, a.bridges, forwards, a.links); err != nil {
+				return err
+			}
+			a.forwards = forwards
+			return nil
+		}
+	}
+	return nil
+}
+
+// SetForwards replaces the forwarded ports with forwards.
+func (a *Anchor) SetForwards(forwards []Forward) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	forwards = append([]Forward(nil), forwards...)
+	if err := a.loadRules(a.network, a.bridges, forwards, a.links); err != nil {
+		return err
+	}
+	a.forwards = forwards
+	return nil
+}
+
+// AddLink adds the rules allowing the link l.
+func (a *Anchor) AddLink(l Link) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	links := append(append([]Link(nil), a.links...), l)
+	if err := a.loadRules(a.network, a.bridges, a.forwards, links); err != nil {
+		return err
+	}
+	a.links = links
+	return nil
+}
+
+// RemoveLink removes the rules allowing the link l.
+func (a *Anchor) RemoveLink(l Link) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, existing := range a.links {
+		if existing.equal(l) {
+			links := append(append([]Link(nil), a.links[:i]...), a.links[i+1:]...)
+			if err := a.loadRules(a.network, a.bridges, a.forwards, links); err != nil {
+				return err
+			}
+			a.links = links
+			return nil
+		}
+	}
+	return nil
+}
+
+// Reload loads the rules of the anchor again, after pf was reloaded for
+// instance.
+func (a *Anchor) Reload() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.load()
+}
+
+// Flush removes all the rules of the anchor and forgets about them.
+func (a *Anchor) Flush() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.network = nil
+	a.bridges = nil
+	a.forwards = nil
+	a.links = nil
+	_, err := a.runner.Run("", "-a", a.Name, "-F", "all")
+	return err
+}
+
+func (a *Anchor) load() error {
+	return a.loadRules(a.network, a.bridges, a.forwards, a.links)
+}
+
+// loadRules loads the given rules in the anchor, without changing the rules
+// it holds.
+func (a *Anchor) loadRules(network *Network, bridges []Bridge, forwards []Forward, links []Link) error {
+	_, err := a.runner.Run(Rules(network, bridges, forwards, links), "-a", a.Name, "-f", "-")
+	return err
+}
+
+func (f Forward) equal(o Forward) bool {
+	return f.Proto == o.Proto && f.HostIP.Equal(o.HostIP) && f.HostPort == o.HostPort &&
+		f.ContainerIP.Equal(o.ContainerIP) && f.ContainerPort == o.ContainerPort
+}
+
+func (l Link) equal(o Link) bool {
+	return l.Proto == o.Proto && l.Parent.Equal(o.Parent) && l.Child.Equal(o.Child) && l.Port == o.Port
+}
+
+// ExternalInterface returns the interface of the default route, on which
+// the outgoing traffic of the containers is translated.
+func ExternalInterface() (string, error) {
+	out, err := exec.Command("route", "-n", "get", "default").Output()
+	if err != nil {
+		return "", fmt.Errorf("Unable to find the default route: %s", err)
+	}
+	return parseRouteInterface(out)
+}
+
+// parseRouteInterface parses the output of `route -n get default`.
+func parseRouteInterface(out []byte) (string, error) {
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "interface:" {
+			return fields[1], nil
+		}
+	}
+	return "", fmt.Errorf("no interface in route output %q", out)
+}
@@ -0,0 +1,303 @@
+package pf
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type call struct {
+	stdin string
+	args  string
+}
+
+// fakeRunner records the calls to pfctl instead of running it.
+type fakeRunner struct {
+	calls []call
+	err   error
+}
+
+func (r *fakeRunner) Run(stdin string, args ...string) ([]byte, error) {
+	r.calls = append(r.calls, call{stdin, strings.Join(args, " ")})
+	return nil, r.err
+}
+
+func newTestNetwork(icc bool) *Network {
+	_, subnet, _ := net.ParseCIDR("172.17.0.0/16")
+	return &Network{
+		Bridge:   "docker0",
+		Subnet:   subnet,
+		Gateway:  net.ParseIP("172.17.42.1"),
+		External: "em0",
+		ICC:      icc,
+	}
+}
+
+var (
+	testForwards = []Forward{
+		{Proto: "tcp", HostIP: net.ParseIP("0.0.0.0"), HostPort: 8080, ContainerIP: net.ParseIP("172.17.0.2"), ContainerPort: 80},
+		{Proto: "udp", HostIP: net.ParseIP("192.168.1.10"), HostPort: 5353, ContainerIP: net.ParseIP("172.17.0.3"), ContainerPort: 53},
+	}
+	testLinks = []Link{
+		{Proto: "tcp", Parent: net.ParseIP("172.17.0.3"), Child: net.ParseIP("172.17.0.2"), Port: 5432},
+	}
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		network  *Network
+		expected string
+	}{
+		{"icc", newTestNetwork(true), `nat on em0 inet from 172.17.0.0/16 to ! 172.17.0.0/16 -> (em0)
+rdr pass inet proto tcp from any to self port 8080 -> 172.17.0.2 port 80
+rdr pass inet proto udp from any to 192.168.1.10 port 5353 -> 172.17.0.3 port 53
+`},
+		{"no-icc", newTestNetwork(false), `nat on em0 inet from 172.17.0.0/16 to ! 172.17.0.0/16 -> (em0)
+rdr pass inet proto tcp from any to self port 8080 -> 172.17.0.2 port 80
+rdr pass inet proto udp from any to 192.168.1.10 port 5353 -> 172.17.0.3 port 53
+pass quick inet proto tcp from 172.17.0.3 to 172.17.0.2 port 5432
+pass quick inet from 172.17.0.0/16 to 172.17.42.1
+pass quick inet from 172.17.42.1 to 172.17.0.0/16
+block drop quick inet from 172.17.0.0/16 to 172.17.0.0/16
+`},
+		{"no-network", nil, `rdr pass inet proto tcp from any to self port 8080 -> 172.17.0.2 port 80
+rdr pass inet proto udp from any to 192.168.1.10 port 5353 -> 172.17.0.3 port 53
+`},
+	}
+	for _, test := range tests {
+		if rules := Rules(test.network, nil, testForwards, testLinks); rules != test.expected {
+			t.Errorf("%s: expected rules:\n%s\ngot:\n%s", test.name, test.expected, rules)
+		}
+	}
+
+	// Without an external interface, the outgoing traffic is not translated
+	network := newTestNetwork(true)
+	network.External = ""
+	if rules := Rules(network, nil, nil, nil); rules != "" {
+		t.Fatalf("Expected no rules, got:\n%s", rules)
+	}
+}
+
+func TestRulesBridges(t *testing.T) {
+	_, subnet1, _ := net.ParseCIDR("172.18.0.0/16")
+	_, subnet2, _ := net.ParseCIDR("172.19.0.0/16")
+	bridges := []Bridge{{Name: "br-1", Subnet: subnet1}, {Name: "br-2", Subnet: subnet2}}
+	network := newTestNetwork(true)
+	network.External = ""
+
+	expected := `block drop quick inet from 172.17.0.0/16 to 172.18.0.0/16
+block drop quick inet from 172.18.0.0/16 to 172.17.0.0/16
+block drop quick inet from 172.17.0.0/16 to 172.19.0.0/16
+block drop quick inet from 172.19.0.0/16 to 172.17.0.0/16
+block drop quick inet from 172.18.0.0/16 to 172.19.0.0/16
+block drop quick inet from 172.19.0.0/16 to 172.18.0.0/16
+`
+	if rules := Rules(network, bridges, nil, nil); rules != expected {
+		t.Fatalf("Expected rules:\n%s\ngot:\n%s", expected, rules)
+	}
+
+	// The default bridge alone needs no isolation
+	if rules := Rules(network, nil, nil, nil); rules != "" {
+		t.Fatalf("Expected no rules, got:\n%s", rules)
+	}
+}
+
+func TestRulesBridgesNat(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("172.18.0.0/16")
+	bridges := []Bridge{{Name: "br-1", Subnet: subnet}}
+	network := newTestNetwork(true)
+
+	expected := `nat on em0 inet from 172.17.0.0/16 to ! 172.17.0.0/16 -> (em0)
+nat on em0 inet from 172.18.0.0/16 to ! 172.18.0.0/16 -> (em0)
+block drop quick inet from 172.17.0.0/16 to 172.18.0.0/16
+block drop quick inet from 172.18.0.0/16 to 172.17.0.0/16
+`
+	if rules := Rules(network, bridges, nil, nil); rules != expected {
+		t.Fatalf("Expected rules:\n%s\ngot:\n%s", expected, rules)
+	}
+}
+
+func TestAnchorBridges(t *testing.T) {
+	r := &fakeRunner{}
+	a := NewAnchorWithRunner(DefaultAnchor, r)
+	network := newTestNetwork(true)
+	_, subnet, _ := net.ParseCIDR("172.18.0.0/16")
+	bridge := Bridge{Name: "br-1", Subnet: subnet}
+	if err := a.SetNetwork(network); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddBridge(bridge); err != nil {
+		t.Fatal(err)
+	}
+	if expected := Rules(network, []Bridge{bridge}, nil, nil); r.calls[1].stdin != expected {
+		t.Fatalf("Expected rules:\n%s\ngot:\n%s", expected, r.calls[1].stdin)
+	}
+	if err := a.RemoveBridge(bridge.Name); err != nil {
+		t.Fatal(err)
+	}
+	if expected := Rules(network, nil, nil, nil); r.calls[2].stdin != expected {
+		t.Fatalf("Expected rules:\n%s\ngot:\n%s", expected, r.calls[2].stdin)
+	}
+
+	// A bridge which failed to load is not kept
+	r.err = errors.New("pfctl failed")
+	if err := a.AddBridge(bridge); err == nil {
+		t.Fatal("Expected the error of pfctl")
+	}
+	r.err = nil
+	if err := a.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := Rules(network, nil, nil, nil); r.calls[4].stdin != expected {
+		t.Fatalf("Expected rules:\n%s\ngot:\n%s", expected, r.calls[4].stdin)
+	}
+}
+
+func TestAnchorLoadsRules(t *testing.T) {
+	r := &fakeRunner{}
+	a := NewAnchorWithRunner(DefaultAnchor, r)
+	network := newTestNetwork(false)
+	if err := a.SetNetwork(network); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddForward(testForwards[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddLink(testLinks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.calls) != 3 {
+		t.Fatalf("Expected the rules to be loaded 3 times, got %v", r.calls)
+	}
+	last := r.calls[2]
+	if last.args != "-a docker -f -" {
+		t.Fatalf("Expected the anchor to be loaded from stdin, got %q", last.args)
+	}
+	if expected := Rules(network, nil, testForwards[:1], testLinks); last.stdin != expected {
+		t.Fatalf("Expected rules:\n%s\ngot:\n%s", expected, last.stdin)
+	}
+
+	// Equal addresses of a different length still match
+	f := testForwards[0]
+	f.ContainerIP = net.ParseIP("172.17.0.2").To4()
+	if err := a.RemoveForward(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RemoveLink(testLinks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if expected := Rules(network, nil, nil, nil); r.calls[4].stdin != expected {
+		t.Fatalf("Expected rules:\n%s\ngot:\n%s", expected, r.calls[4].stdin)
+	}
+
+	// Removing an unknown forward does not reload the rules
+	if err := a.RemoveForward(testForwards[1]); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.calls) != 5 {
+		t.Fatalf("Expected no reload, got %v", r.calls[5:])
+	}
+}
+
+func TestAnchorSetForwards(t *testing.T) {
+	r := &fakeRunner{}
+	a := NewAnchorWithRunner(DefaultAnchor, r)
+	network := newTestNetwork(true)
+	if err := a.SetNetwork(network); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddForward(testForwards[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetForwards(testForwards[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if expected := Rules(network, nil, testForwards[1:], nil); r.calls[2].stdin != expected {
+		t.Fatalf("Expected rules:\n%s\ngot:\n%s", expected, r.calls[2].stdin)
+	}
+	if err := a.SetForwards(nil); err != nil {
+		t.Fatal(err)
+	}
+	if expected := Rules(network, nil, nil, nil); r.calls[3].stdin != expected {
+		t.Fatalf("Expected rules:\n%s\ngot:\n%s", expected, r.calls[3].stdin)
+	}
+}
+
+func TestAnchorFlush(t *testing.T) {
+	r := &fakeRunner{}
+	a := NewAnchorWithRunner(DefaultAnchor, r)
+	if err := a.AddForward(testForwards[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (call{"", "-a docker -F all"}); !reflect.DeepEqual(r.calls[1], expected) {
+		t.Fatalf("Expected %v, got %v", expected, r.calls[1])
+	}
+	if err := a.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if r.calls[2].stdin != "" {
+		t.Fatalf("Expected no rules after a flush, got:\n%s", r.calls[2].stdin)
+	}
+}
+
+func TestAnchorError(t *testing.T) {
+	r := &fakeRunner{err: errors.New("pfctl failed")}
+	a := NewAnchorWithRunner(DefaultAnchor, r)
+	if err := a.AddForward(testForwards[0]); err == nil {
+		t.Fatal("Expected the error of pfctl")
+	}
+	if err := a.AddLink(testLinks[0]); err == nil {
+		t.Fatal("Expected the error of pfctl")
+	}
+	if err := a.SetNetwork(newTestNetwork(false)); err == nil {
+		t.Fatal("Expected the error of pfctl")
+	}
+
+	// The rules which failed to load are not kept
+	r.err = nil
+	if err := a.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if last := r.calls[len(r.calls)-1]; last.stdin != "" {
+		t.Fatalf("Expected no rules, got:\n%s", last.stdin)
+	}
+
+	// Nor are the removals which failed
+	if err := a.AddForward(testForwards[0]); err != nil {
+		t.Fatal(err)
+	}
+	r.err = errors.New("pfctl failed")
+	if err := a.RemoveForward(testForwards[0]); err == nil {
+		t.Fatal("Expected the error of pfctl")
+	}
+	r.err = nil
+	if err := a.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if expected, last := Rules(nil, nil, testForwards[:1], nil), r.calls[len(r.calls)-1]; last.stdin != expected {
+		t.Fatalf("Expected rules:\n%s\ngot:\n%s", expected, last.stdin)
+	}
+}
+
+func TestParseRouteInterface(t *testing.T) {
+	out := `   route to: default
+destination: default
+       mask: default
+    gateway: 192.168.1.1
+        fib: 0
+  interface: em0
+      flags: <UP,GATEWAY,DONE,STATIC>
+`
+	if iface, err := parseRouteInterface([]byte(out)); err != nil || iface != "em0" {
+		t.Fatalf("Expected em0, got %q, %v", iface, err)
+	}
+	if _, err := parseRouteInterface([]byte("route: route has not been found\n")); err == nil {
+		t.Fatal("Expected an error without an interface")
+	}
+}
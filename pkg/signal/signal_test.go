@@ -0,0 +1,31 @@
+// +build linux freebsd
+
+package signal
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	for raw, expected := range map[string]syscall.Signal{
+		"9":       syscall.SIGKILL,
+		"QUIT":    syscall.SIGQUIT,
+		"SIGINT":  syscall.SIGINT,
+		"sigterm": syscall.SIGTERM,
+	} {
+		s, err := ParseSignal(raw)
+		if err != nil {
+			t.Fatalf("%s: %v", raw, err)
+		}
+		if s != expected {
+			t.Fatalf("%s: expected %d, got %d", raw, expected, s)
+		}
+	}
+
+	for _, raw := range []string{"", "0", "-1", "SIGNOPE"} {
+		if _, err := ParseSignal(raw); err == nil {
+			t.Fatalf("expected an error for %q", raw)
+		}
+	}
+}
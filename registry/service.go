@@ -1,19 +1,39 @@
 package registry
 
-import "github.com/docker/docker/cliconfig"
+import (
+	"sync"
+
+	"github.com/docker/docker/cliconfig"
+)
 
 type Service struct {
-	Config *ServiceConfig
+	mu     sync.Mutex // protects config
+	config *ServiceConfig
 }
 
 // NewService returns a new instance of Service ready to be
 // installed no an engine.
 func NewService(options *Options) *Service {
 	return &Service{
-		Config: NewServiceConfig(options),
+		config: NewServiceConfig(options),
 	}
 }
 
+// Config returns the configuration of the service.
+func (s *Service) Config() *ServiceConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config
+}
+
+// SetConfig replaces the configuration of the service, when the daemon
+// reloads its own.
+func (s *Service) SetConfig(config *ServiceConfig) {
+	s.mu.Lock()
+	s.config = config
+	s.mu.Unlock()
+}
+
 // Auth contacts the public registry with the provided credentials,
 // and returns OK if authentication was sucessful.
 // It can be used to verify the validity of a client's credentials.
@@ -57,10 +77,10 @@ func (s *Service) Search(term string, authConfig *cliconfig.AuthConfig, headers
 // ResolveRepository splits a repository name into its components
 // and configuration of the associated registry.
 func (s *Service) ResolveRepository(name string) (*RepositoryInfo, error) {
-	return s.Config.NewRepositoryInfo(name)
+	return s.Config().NewRepositoryInfo(name)
 }
 
 // ResolveIndex takes indexName and returns index info
 func (s *Service) ResolveIndex(name string) (*IndexInfo, error) {
-	return s.Config.NewIndexInfo(name)
+	return s.Config().NewIndexInfo(name)
 }
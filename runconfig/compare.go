@@ -11,7 +11,8 @@ func Compare(a, b *Config) bool {
 		a.AttachStderr != b.AttachStderr ||
 		a.User != b.User ||
 		a.OpenStdin != b.OpenStdin ||
-		a.Tty != b.Tty {
+		a.Tty != b.Tty ||
+		a.StopSignal != b.StopSignal {
 		return false
 	}
 
@@ -65,5 +66,23 @@ func Compare(a, b *Config) bool {
 			return false
 		}
 	}
+	return compareHealthConfig(a.Healthcheck, b.Healthcheck)
+}
+
+func compareHealthConfig(a, b *HealthConfig) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Interval != b.Interval ||
+		a.Timeout != b.Timeout ||
+		a.Retries != b.Retries ||
+		len(a.Test) != len(b.Test) {
+		return false
+	}
+	for i := range a.Test {
+		if a.Test[i] != b.Test[i] {
+			return false
+		}
+	}
 	return true
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/nat"
 )
@@ -103,6 +104,27 @@ func NewCommand(parts ...string) *Command {
 	return &Command{parts}
 }
 
+// HealthConfig holds the configuration of the probe run in a container to
+// check that it is healthy.
+type HealthConfig struct {
+	// Test is the probe to run:
+	// {} inherits the healthcheck of the image,
+	// {"NONE"} disables the healthcheck,
+	// {"CMD", args...} runs args directly,
+	// {"CMD-SHELL", command} runs command with the container's shell.
+	Test []string `json:",omitempty"`
+
+	// Zero means to use the default, or to inherit the image's value.
+	Interval time.Duration `json:",omitempty"` // Time between probes
+	Timeout  time.Duration `json:",omitempty"` // Time before a probe is considered to have hung
+	Retries  int           `json:",omitempty"` // Consecutive failures needed to be reported unhealthy
+}
+
+// IsDisabled returns true if the healthcheck was turned off with NONE.
+func (h *HealthConfig) IsDisabled() bool {
+	return h == nil || len(h.Test) == 0 || h.Test[0] == "NONE"
+}
+
 // Note: the Config structure should hold only portable information about the container.
 // Here, "portable" means "independent from the host we are running on".
 // Non-portable information *should* appear in HostConfig.
@@ -128,6 +150,9 @@ type Config struct {
 	MacAddress      string
 	OnBuild         []string
 	Labels          map[string]string
+	Healthcheck     *HealthConfig
+	StopSignal      string `json:",omitempty"` // Signal to stop the container, SIGTERM if empty
+	StopTimeout     *int   `json:",omitempty"` // Seconds to wait for the container to stop before killing it
 }
 
 type ContainerConfigWrapper struct {
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/nat"
 )
@@ -257,6 +258,44 @@ func TestMerge(t *testing.T) {
 	}
 }
 
+func TestMergeHealthcheck(t *testing.T) {
+	configImage := &Config{
+		Healthcheck: &HealthConfig{
+			Test:     []string{"CMD-SHELL", "true"},
+			Interval: 5 * time.Second,
+			Retries:  2,
+		},
+	}
+	configUser := &Config{
+		Healthcheck: &HealthConfig{
+			Timeout: time.Second,
+			Retries: 4,
+		},
+	}
+
+	if err := Merge(configUser, configImage); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &HealthConfig{
+		Test:     []string{"CMD-SHELL", "true"},
+		Interval: 5 * time.Second,
+		Timeout:  time.Second,
+		Retries:  4,
+	}
+	if !compareHealthConfig(configUser.Healthcheck, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, configUser.Healthcheck)
+	}
+
+	configUser = &Config{}
+	if err := Merge(configUser, configImage); err != nil {
+		t.Fatal(err)
+	}
+	if !compareHealthConfig(configUser.Healthcheck, configImage.Healthcheck) {
+		t.Fatalf("Expected the healthcheck of the image, got %+v", configUser.Healthcheck)
+	}
+}
+
 func TestDecodeContainerConfig(t *testing.T) {
 	fixtures := []struct {
 		file       string
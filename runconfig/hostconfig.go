@@ -3,7 +3,9 @@ package runconfig
 import (
 	"encoding/json"
 	"io"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/nat"
 	"github.com/docker/docker/pkg/ulimit"
@@ -16,6 +18,19 @@ type KeyValuePair struct {
 
 type NetworkMode string
 
+// validNetworkName matches the names allowed for user-defined networks
+var validNetworkName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// ValidNetworkName returns true if name can be used for a user-defined
+// network. The names of the built-in network modes are reserved.
+func ValidNetworkName(name string) bool {
+	switch name {
+	case "bridge", "host", "none", "default":
+		return false
+	}
+	return validNetworkName.MatchString(name)
+}
+
 // IsPrivate indicates whether container use it's private network stack
 func (n NetworkMode) IsPrivate() bool {
 	return !(n.IsHost() || n.IsContainer() || n.IsNone())
@@ -38,6 +53,28 @@ func (n NetworkMode) IsNone() bool {
 	return n == "none"
 }
 
+// IsDefault indicates whether the container uses the daemon's default bridge
+func (n NetworkMode) IsDefault() bool {
+	return n == "" || n.IsBridge()
+}
+
+// IsUserDefined indicates whether the container is attached to a network
+// created with `docker network create`
+func (n NetworkMode) IsUserDefined() bool {
+	return !(n.IsDefault() || n.IsHost() || n.IsContainer() || n.IsNone())
+}
+
+// NetworkName returns the name of the network the container is attached to
+func (n NetworkMode) NetworkName() string {
+	if n.IsDefault() {
+		return "bridge"
+	}
+	if n.IsContainer() {
+		return ""
+	}
+	return string(n)
+}
+
 type IpcMode string
 
 // IsPrivate indicates whether container use it's private ipc stack
@@ -127,6 +164,11 @@ type DeviceMapping struct {
 type RestartPolicy struct {
 	Name              string
 	MaximumRetryCount int
+
+	// Zero means to use the default. The delay before a restart doubles
+	// each time the container exits quickly, up to MaximumDelay.
+	Delay        time.Duration `json:",omitempty"` // Delay before the first restart
+	MaximumDelay time.Duration `json:",omitempty"` // Maximum delay between restarts
 }
 
 func (rp *RestartPolicy) IsNone() bool {
@@ -141,6 +183,10 @@ func (rp *RestartPolicy) IsOnFailure() bool {
 	return rp.Name == "on-failure"
 }
 
+func (rp *RestartPolicy) IsUnlessStopped() bool {
+	return rp.Name == "unless-stopped"
+}
+
 type LogConfig struct {
 	Type   string
 	Config map[string]string
@@ -228,7 +274,9 @@ type HostConfig struct {
 	ReadonlyRootfs  bool
 	Ulimits         []*ulimit.Ulimit
 	LogConfig       LogConfig
-	CgroupParent    string // Parent cgroup.
+	CgroupParent    string            // Parent cgroup.
+	VolumeDriver    string            // Driver for named volumes given in Binds
+	StorageOpt      map[string]string // Storage driver options, e.g. size
 }
 
 func MergeConfigs(config *Config, hostConfig *HostConfig) *ContainerConfigWrapper {
@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/nat"
 	"github.com/docker/docker/opts"
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/pkg/parsers"
+	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/pkg/ulimit"
 	"github.com/docker/docker/pkg/units"
 )
@@ -20,6 +22,7 @@ var (
 	ErrConflictHostNetworkAndLinks      = fmt.Errorf("Conflicting options: --net=host can't be used with links. This would result in undefined behavior")
 	ErrConflictContainerNetworkAndMac   = fmt.Errorf("Conflicting options: --mac-address and the network mode (--net)")
 	ErrConflictNetworkHosts             = fmt.Errorf("Conflicting options: --add-host and the network mode (--net)")
+	ErrConflictNoHealthcheck            = fmt.Errorf("Conflicting options: --no-healthcheck and the --health-* options")
 )
 
 func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSet, error) {
@@ -48,6 +51,7 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		flSecurityOpt = opts.NewListOpts(nil)
 		flLabelsFile  = opts.NewListOpts(nil)
 		flLoggingOpts = opts.NewListOpts(nil)
+		flStorageOpt  = opts.NewListOpts(nil)
 
 		flNetwork         = cmd.Bool([]string{"#n", "#-networking"}, true, "Enable networking for this container")
 		flPrivileged      = cmd.Bool([]string{"#privileged", "-privileged"}, false, "Give extended privileges to this container")
@@ -74,9 +78,19 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		flMacAddress      = cmd.String([]string{"-mac-address"}, "", "Container MAC address (e.g. 92:d0:c6:0a:29:33)")
 		flIpcMode         = cmd.String([]string{"-ipc"}, "", "IPC namespace to use")
 		flRestartPolicy   = cmd.String([]string{"-restart"}, "no", "Restart policy to apply when a container exits")
+		flRestartDelay    = cmd.Duration([]string{"-restart-delay"}, 0, "Delay before the first restart of the container")
+		flRestartMaxDelay = cmd.Duration([]string{"-restart-max-delay"}, 0, "Maximum delay between restarts of the container")
 		flReadonlyRootfs  = cmd.Bool([]string{"-read-only"}, false, "Mount the container's root filesystem as read only")
 		flLoggingDriver   = cmd.String([]string{"-log-driver"}, "", "Logging driver for container")
 		flCgroupParent    = cmd.String([]string{"-cgroup-parent"}, "", "Optional parent cgroup for the container")
+		flVolumeDriver    = cmd.String([]string{"-volume-driver"}, "", "Optional volume driver for the container")
+		flHealthCmd       = cmd.String([]string{"-health-cmd"}, "", "Command to run to check health")
+		flHealthInterval  = cmd.Duration([]string{"-health-interval"}, 0, "Time between running the check")
+		flHealthTimeout   = cmd.Duration([]string{"-health-timeout"}, 0, "Maximum time to allow one check to run")
+		flHealthRetries   = cmd.Int([]string{"-health-retries"}, 0, "Consecutive failures needed to report unhealthy")
+		flNoHealthcheck   = cmd.Bool([]string{"-no-healthcheck"}, false, "Disable any container-specified HEALTHCHECK")
+		flStopSignal      = cmd.String([]string{"-stop-signal"}, "", "Signal to stop the container, SIGTERM by default")
+		flStopTimeout     = cmd.Int([]string{"-stop-timeout"}, 0, "Seconds to wait for the container to stop before killing it")
 	)
 
 	cmd.Var(&flAttach, []string{"a", "-attach"}, "Attach to STDIN, STDOUT or STDERR")
@@ -99,6 +113,7 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 	cmd.Var(&flSecurityOpt, []string{"-security-opt"}, "Security Options")
 	cmd.Var(flUlimits, []string{"-ulimit"}, "Ulimit options")
 	cmd.Var(&flLoggingOpts, []string{"-log-opt"}, "Log driver options")
+	cmd.Var(&flStorageOpt, []string{"-storage-opt"}, "Storage driver options for the container")
 
 	cmd.Require(flag.Min, 1)
 
@@ -291,12 +306,39 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 	if err != nil {
 		return nil, nil, cmd, err
 	}
+	if err := setRestartDelays(&restartPolicy, *flRestartDelay, *flRestartMaxDelay); err != nil {
+		return nil, nil, cmd, err
+	}
 
 	loggingOpts, err := parseLoggingOpts(*flLoggingDriver, flLoggingOpts.GetAll())
 	if err != nil {
 		return nil, nil, cmd, err
 	}
 
+	storageOpts, err := parseStorageOpts(flStorageOpt.GetAll())
+	if err != nil {
+		return nil, nil, cmd, err
+	}
+
+	healthConfig, err := parseHealthConfig(*flHealthCmd, *flHealthInterval, *flHealthTimeout, *flHealthRetries, *flNoHealthcheck)
+	if err != nil {
+		return nil, nil, cmd, err
+	}
+
+	if *flStopSignal != "" {
+		if _, err := signal.ParseSignal(*flStopSignal); err != nil {
+			return nil, nil, cmd, err
+		}
+	}
+
+	var stopTimeout *int
+	if cmd.IsSet("-stop-timeout") {
+		if *flStopTimeout < 0 {
+			return nil, nil, cmd, fmt.Errorf("--stop-timeout cannot be negative")
+		}
+		stopTimeout = flStopTimeout
+	}
+
 	config := &Config{
 		Hostname:        hostname,
 		Domainname:      domainname,
@@ -317,6 +359,9 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		Entrypoint:      entrypoint,
 		WorkingDir:      *flWorkingDir,
 		Labels:          convertKVStringsToMap(labels),
+		Healthcheck:     healthConfig,
+		StopSignal:      *flStopSignal,
+		StopTimeout:     stopTimeout,
 	}
 
 	hostConfig := &HostConfig{
@@ -353,6 +398,8 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		Ulimits:         flUlimits.GetList(),
 		LogConfig:       LogConfig{Type: *flLoggingDriver, Config: loggingOpts},
 		CgroupParent:    *flCgroupParent,
+		VolumeDriver:    *flVolumeDriver,
+		StorageOpt:      storageOpts,
 	}
 
 	// When allocating stdin in attached mode, close stdin at client disconnect
@@ -393,6 +440,41 @@ func convertKVStringsToMap(values []string) map[string]string {
 	return result
 }
 
+// parseHealthConfig builds the healthcheck of the container from the
+// --health-* flags. nil is returned if none was set so that the
+// healthcheck of the image is used unchanged.
+func parseHealthConfig(cmd string, interval, timeout time.Duration, retries int, disable bool) (*HealthConfig, error) {
+	haveOptions := cmd != "" || interval != 0 || timeout != 0 || retries != 0
+	if disable {
+		if haveOptions {
+			return nil, ErrConflictNoHealthcheck
+		}
+		return &HealthConfig{Test: []string{"NONE"}}, nil
+	}
+	if !haveOptions {
+		return nil, nil
+	}
+	if interval < 0 {
+		return nil, fmt.Errorf("--health-interval cannot be negative")
+	}
+	if timeout < 0 {
+		return nil, fmt.Errorf("--health-timeout cannot be negative")
+	}
+	if retries < 0 {
+		return nil, fmt.Errorf("--health-retries cannot be negative")
+	}
+
+	healthConfig := &HealthConfig{
+		Interval: interval,
+		Timeout:  timeout,
+		Retries:  retries,
+	}
+	if cmd != "" {
+		healthConfig.Test = []string{"CMD-SHELL", cmd}
+	}
+	return healthConfig, nil
+}
+
 func parseLoggingOpts(loggingDriver string, loggingOpts []string) (map[string]string, error) {
 	loggingOptsMap := convertKVStringsToMap(loggingOpts)
 	if loggingDriver == "none" && len(loggingOpts) > 0 {
@@ -402,6 +484,23 @@ func parseLoggingOpts(loggingDriver string, loggingOpts []string) (map[string]st
 	return loggingOptsMap, nil
 }
 
+// parseStorageOpts returns the storage options of the container, given
+// as key=value.
+func parseStorageOpts(storageOpts []string) (map[string]string, error) {
+	if len(storageOpts) == 0 {
+		return nil, nil
+	}
+	m := make(map[string]string, len(storageOpts))
+	for _, opt := range storageOpts {
+		parts := strings.SplitN(opt, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid storage option %s, expected key=value", opt)
+		}
+		m[parts[0]] = parts[1]
+	}
+	return m, nil
+}
+
 // ParseRestartPolicy returns the parsed policy or an error indicating what is incorrect
 func ParseRestartPolicy(policy string) (RestartPolicy, error) {
 	p := RestartPolicy{}
@@ -417,9 +516,9 @@ func ParseRestartPolicy(policy string) (RestartPolicy, error) {
 
 	p.Name = name
 	switch name {
-	case "always":
+	case "always", "unless-stopped":
 		if len(parts) == 2 {
-			return p, fmt.Errorf("maximum restart count not valid with restart policy of \"always\"")
+			return p, fmt.Errorf("maximum restart count not valid with restart policy of %q", name)
 		}
 	case "no":
 		// do nothing
@@ -439,6 +538,25 @@ func ParseRestartPolicy(policy string) (RestartPolicy, error) {
 	return p, nil
 }
 
+// setRestartDelays sets the delays between the restarts of policy.
+func setRestartDelays(policy *RestartPolicy, delay, maxDelay time.Duration) error {
+	if delay == 0 && maxDelay == 0 {
+		return nil
+	}
+	if policy.Name == "" || policy.IsNone() {
+		return fmt.Errorf("--restart-delay and --restart-max-delay need a restart policy")
+	}
+	if delay < 0 || maxDelay < 0 {
+		return fmt.Errorf("--restart-delay and --restart-max-delay cannot be negative")
+	}
+	if maxDelay != 0 && delay > maxDelay {
+		return fmt.Errorf("--restart-delay cannot be longer than --restart-max-delay")
+	}
+	policy.Delay = delay
+	policy.MaximumDelay = maxDelay
+	return nil
+}
+
 // options will come in the format of name.key=value or name.option
 func parseDriverOpts(opts opts.ListOpts) (map[string][]string, error) {
 	out := make(map[string][]string, len(opts.GetAll()))
@@ -479,7 +597,11 @@ func parseNetMode(netMode string) (NetworkMode, error) {
 			return "", fmt.Errorf("invalid container format container:<name|id>")
 		}
 	default:
-		return "", fmt.Errorf("invalid --net: %s", netMode)
+		// Any other name refers to a user-defined network, which is
+		// resolved by the daemon.
+		if len(parts) > 1 || !validNetworkName.MatchString(netMode) {
+			return "", fmt.Errorf("invalid --net: %s", netMode)
+		}
 	}
 	return NetworkMode(netMode), nil
 }
@@ -3,6 +3,7 @@ package runconfig
 import (
 	"io/ioutil"
 	"testing"
+	"time"
 
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/pkg/parsers"
@@ -58,8 +59,130 @@ func TestNetHostname(t *testing.T) {
 	}
 }
 
+func TestUserDefinedNetwork(t *testing.T) {
+	_, hostConfig, _, err := parseRun([]string{"--net=frontend", "img", "cmd"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !hostConfig.NetworkMode.IsUserDefined() || hostConfig.NetworkMode.NetworkName() != "frontend" {
+		t.Fatalf("Expected user-defined network frontend, got %q", hostConfig.NetworkMode)
+	}
+
+	if _, _, _, err := parseRun([]string{"--net=front:end", "img", "cmd"}); err == nil {
+		t.Fatalf("Expected error for an invalid network name")
+	}
+}
+
 func TestConflictContainerNetworkAndLinks(t *testing.T) {
 	if _, _, _, err := parseRun([]string{"--net=container:other", "--link=zip:zap", "img", "cmd"}); err != ErrConflictContainerNetworkAndLinks {
 		t.Fatalf("Expected error ErrConflictContainerNetworkAndLinks, got: %s", err)
 	}
 }
+
+func TestParseHealth(t *testing.T) {
+	config, _, _, err := parseRun([]string{"img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Healthcheck != nil {
+		t.Fatalf("Expected no healthcheck, got %+v", config.Healthcheck)
+	}
+
+	config, _, _, err = parseRun([]string{"--no-healthcheck", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.Healthcheck.IsDisabled() {
+		t.Fatalf("Expected the healthcheck to be disabled, got %+v", config.Healthcheck)
+	}
+
+	config, _, _, err = parseRun([]string{"--health-cmd=/check.sh -q", "--health-interval=2s", "--health-retries=5", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	health := config.Healthcheck
+	if len(health.Test) != 2 || health.Test[0] != "CMD-SHELL" || health.Test[1] != "/check.sh -q" {
+		t.Fatalf("Unexpected healthcheck test %q", health.Test)
+	}
+	if health.Interval != 2*time.Second || health.Timeout != 0 || health.Retries != 5 {
+		t.Fatalf("Unexpected healthcheck options %+v", health)
+	}
+
+	if _, _, _, err := parseRun([]string{"--no-healthcheck", "--health-cmd=/check.sh", "img", "cmd"}); err != ErrConflictNoHealthcheck {
+		t.Fatalf("Expected error ErrConflictNoHealthcheck, got: %s", err)
+	}
+}
+
+func TestParseStopSignalAndTimeout(t *testing.T) {
+	config, _, _, err := parseRun([]string{"img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.StopSignal != "" || config.StopTimeout != nil {
+		t.Fatalf("Expected no stop signal nor timeout, got %q %v", config.StopSignal, config.StopTimeout)
+	}
+
+	config, _, _, err = parseRun([]string{"--stop-signal=SIGQUIT", "--stop-timeout=0", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.StopSignal != "SIGQUIT" {
+		t.Fatalf("Expected stop signal SIGQUIT, got %q", config.StopSignal)
+	}
+	if config.StopTimeout == nil || *config.StopTimeout != 0 {
+		t.Fatalf("Expected a stop timeout of 0, got %v", config.StopTimeout)
+	}
+
+	if _, _, _, err := parseRun([]string{"--stop-signal=SIGNOPE", "img", "cmd"}); err == nil {
+		t.Fatal("Expected an error for an invalid stop signal")
+	}
+	if _, _, _, err := parseRun([]string{"--stop-timeout=-1", "img", "cmd"}); err == nil {
+		t.Fatal("Expected an error for a negative stop timeout")
+	}
+}
+
+func TestParseStorageOpt(t *testing.T) {
+	_, hostConfig, _, err := parseRun([]string{"img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hostConfig.StorageOpt != nil {
+		t.Fatalf("Expected no storage options, got %v", hostConfig.StorageOpt)
+	}
+
+	_, hostConfig, _, err = parseRun([]string{"--storage-opt", "size=10G", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hostConfig.StorageOpt) != 1 || hostConfig.StorageOpt["size"] != "10G" {
+		t.Fatalf("Expected a size of 10G, got %v", hostConfig.StorageOpt)
+	}
+
+	for _, opt := range []string{"size", "=10G"} {
+		if _, _, _, err := parseRun([]string{"--storage-opt", opt, "img", "cmd"}); err == nil {
+			t.Fatalf("Expected an error for the storage option %q", opt)
+		}
+	}
+}
+
+func TestParseRestartPolicy(t *testing.T) {
+	_, hostConfig, _, err := parseRun([]string{"--restart", "unless-stopped", "--restart-delay", "2s", "--restart-max-delay", "1m", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := RestartPolicy{Name: "unless-stopped", Delay: 2 * time.Second, MaximumDelay: time.Minute}
+	if hostConfig.RestartPolicy != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, hostConfig.RestartPolicy)
+	}
+
+	for _, args := range [][]string{
+		{"--restart", "unless-stopped:3"},
+		{"--restart-delay", "2s"},
+		{"--restart", "always", "--restart-delay", "-2s"},
+		{"--restart", "always", "--restart-delay", "2m", "--restart-max-delay", "1m"},
+	} {
+		if _, _, _, err := parseRun(append(args, "img", "cmd")); err == nil {
+			t.Fatalf("Expected an error parsing %v", args)
+		}
+	}
+}
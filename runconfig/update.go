@@ -0,0 +1,106 @@
+package runconfig
+
+import (
+	"fmt"
+
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/units"
+)
+
+// UpdateConfig holds the settings of a container which can be changed
+// while it exists, through `docker update`. A zero value leaves the
+// setting of the container unchanged.
+type UpdateConfig struct {
+	Memory        int64 // Memory limit (in bytes)
+	MemorySwap    int64 // Total memory usage (memory + swap); set `-1` to disable swap
+	CpuShares     int64 // CPU shares (relative weight vs. other containers)
+	CpuQuota      int64
+	CpusetCpus    string // CpusetCpus 0-2, 0,1
+	BlkioWeight   int64  // Block IO weight (relative weight vs. other containers)
+	RestartPolicy RestartPolicy
+}
+
+// Apply returns a copy of hostConfig with the settings of the update.
+func (u *UpdateConfig) Apply(hostConfig *HostConfig) *HostConfig {
+	updated := *hostConfig
+	if u.Memory != 0 {
+		updated.Memory = u.Memory
+	}
+	if u.MemorySwap != 0 {
+		updated.MemorySwap = u.MemorySwap
+	}
+	if u.CpuShares != 0 {
+		updated.CpuShares = u.CpuShares
+	}
+	if u.CpuQuota != 0 {
+		updated.CpuQuota = u.CpuQuota
+	}
+	if u.CpusetCpus != "" {
+		updated.CpusetCpus = u.CpusetCpus
+	}
+	if u.BlkioWeight != 0 {
+		updated.BlkioWeight = u.BlkioWeight
+	}
+	if u.RestartPolicy.Name != "" {
+		updated.RestartPolicy = u.RestartPolicy
+	}
+	return &updated
+}
+
+// ParseUpdate parses the flags of `docker update`. The containers to update
+// are the remaining arguments of cmd.
+func ParseUpdate(cmd *flag.FlagSet, args []string) (*UpdateConfig, error) {
+	var (
+		flMemoryString    = cmd.String([]string{"m", "-memory"}, "", "Memory limit")
+		flMemorySwap      = cmd.String([]string{"-memory-swap"}, "", "Total memory (memory + swap), '-1' to disable swap")
+		flCpuShares       = cmd.Int64([]string{"c", "-cpu-shares"}, 0, "CPU shares (relative weight)")
+		flCpuQuota        = cmd.Int64([]string{"-cpu-quota"}, 0, "Limit the CPU CFS quota")
+		flCpusetCpus      = cmd.String([]string{"-cpuset-cpus"}, "", "CPUs in which to allow execution (0-3, 0,1)")
+		flBlkioWeight     = cmd.Int64([]string{"-blkio-weight"}, 0, "Block IO (relative weight), between 10 and 1000")
+		flRestart         = cmd.String([]string{"-restart"}, "", "Restart policy to apply when a container exits")
+		flRestartDelay    = cmd.Duration([]string{"-restart-delay"}, 0, "Delay before the first restart of the container")
+		flRestartMaxDelay = cmd.Duration([]string{"-restart-max-delay"}, 0, "Maximum delay between restarts of the container")
+	)
+	cmd.Require(flag.Min, 1)
+	if err := cmd.ParseFlags(args, true); err != nil {
+		return nil, err
+	}
+
+	config := &UpdateConfig{
+		CpuShares:   *flCpuShares,
+		CpuQuota:    *flCpuQuota,
+		CpusetCpus:  *flCpusetCpus,
+		BlkioWeight: *flBlkioWeight,
+	}
+	if *flMemoryString != "" {
+		memory, err := units.RAMInBytes(*flMemoryString)
+		if err != nil {
+			return nil, err
+		}
+		config.Memory = memory
+	}
+	if *flMemorySwap != "" {
+		if *flMemorySwap == "-1" {
+			config.MemorySwap = -1
+		} else {
+			memorySwap, err := units.RAMInBytes(*flMemorySwap)
+			if err != nil {
+				return nil, err
+			}
+			config.MemorySwap = memorySwap
+		}
+	}
+	restartPolicy, err := ParseRestartPolicy(*flRestart)
+	if err != nil {
+		return nil, err
+	}
+	if err := setRestartDelays(&restartPolicy, *flRestartDelay, *flRestartMaxDelay); err != nil {
+		return nil, err
+	}
+	config.RestartPolicy = restartPolicy
+
+	if *config == (UpdateConfig{}) {
+		return nil, fmt.Errorf("You must provide one or more flags when using this command.")
+	}
+	return config, nil
+}
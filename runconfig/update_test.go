@@ -0,0 +1,69 @@
+package runconfig
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	flag "github.com/docker/docker/pkg/mflag"
+)
+
+func parseUpdate(args []string) (*UpdateConfig, error) {
+	cmd := flag.NewFlagSet("update", flag.ContinueOnError)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.Usage = nil
+	return ParseUpdate(cmd, args)
+}
+
+func TestParseUpdate(t *testing.T) {
+	config, err := parseUpdate([]string{"-m", "512m", "--memory-swap", "-1", "--cpuset-cpus", "0,1", "--restart", "on-failure:3", "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &UpdateConfig{
+		Memory:        512 * 1024 * 1024,
+		MemorySwap:    -1,
+		CpusetCpus:    "0,1",
+		RestartPolicy: RestartPolicy{Name: "on-failure", MaximumRetryCount: 3},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, config)
+	}
+
+	for _, args := range [][]string{
+		{"web"},
+		{"-m", "lots", "web"},
+		{"--restart", "sometimes", "web"},
+	} {
+		if _, err := parseUpdate(args); err == nil {
+			t.Fatalf("Expected an error parsing %v", args)
+		}
+	}
+}
+
+func TestUpdateConfigApply(t *testing.T) {
+	hostConfig := &HostConfig{
+		Memory:        512 * 1024 * 1024,
+		CpuShares:     512,
+		CpusetCpus:    "0",
+		RestartPolicy: RestartPolicy{Name: "always"},
+		Privileged:    true,
+	}
+	update := &UpdateConfig{CpuShares: 1024, CpusetCpus: "0-3"}
+	updated := update.Apply(hostConfig)
+
+	expected := *hostConfig
+	expected.CpuShares = 1024
+	expected.CpusetCpus = "0-3"
+	if !reflect.DeepEqual(updated, &expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, updated)
+	}
+	if hostConfig.CpuShares != 512 {
+		t.Fatal("Expected the host config to be left unchanged")
+	}
+
+	update = &UpdateConfig{RestartPolicy: RestartPolicy{Name: "no"}}
+	if updated := update.Apply(hostConfig); !updated.RestartPolicy.IsNone() || updated.Memory != hostConfig.Memory {
+		t.Fatalf("Expected only the restart policy to change, got %+v", updated)
+	}
+}
@@ -0,0 +1,119 @@
+package volumes
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/pkg/plugins"
+)
+
+// DefaultDriverName is the name of the built-in driver which stores volumes
+// on the host under the daemon's root.
+const DefaultDriverName = "local"
+
+// VolumeDriver is the interface implemented by volume backends. Every
+// method is keyed by the volume's name as seen by the driver.
+type VolumeDriver interface {
+	// Create provisions a new volume. Creating a volume which already
+	// exists is not an error.
+	Create(name string) error
+	// Remove deletes the volume and its data.
+	Remove(name string) error
+	// Path returns the host path where the volume is, or will be, made
+	// available.
+	Path(name string) (string, error)
+	// Mount makes the volume available on the host and returns its path.
+	// It is called every time a container using the volume is started.
+	Mount(name string) (string, error)
+	// Unmount is called when a container using the volume stops.
+	Unmount(name string) error
+}
+
+var (
+	driversLock sync.Mutex
+	drivers     = make(map[string]VolumeDriver)
+)
+
+func init() {
+	plugins.Handle("VolumeDriver", func(name string, client *plugins.Client) {
+		RegisterDriver(name, NewPluginDriver(name, client))
+	})
+}
+
+// RegisterDriver makes a volume driver available under the given name.
+// It returns false if a driver is already registered with that name.
+func RegisterDriver(name string, d VolumeDriver) bool {
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	if _, exists := drivers[name]; exists {
+		return false
+	}
+	drivers[name] = d
+	return true
+}
+
+// UnregisterDriver removes a driver registered with RegisterDriver.
+func UnregisterDriver(name string) {
+	driversLock.Lock()
+	delete(drivers, name)
+	driversLock.Unlock()
+}
+
+// lookupDriver returns the driver registered under name, activating the
+// volume plugin of that name if no driver was registered yet.
+func lookupDriver(name string) (VolumeDriver, error) {
+	driversLock.Lock()
+	d, exists := drivers[name]
+	driversLock.Unlock()
+	if exists {
+		return d, nil
+	}
+
+	pl, err := plugins.Get(name, "VolumeDriver")
+	if err != nil {
+		return nil, fmt.Errorf("Error looking up volume plugin %s: %v", name, err)
+	}
+
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	if d, exists := drivers[name]; exists {
+		return d, nil
+	}
+	d = NewPluginDriver(name, pl.Client)
+	drivers[name] = d
+	return d, nil
+}
+
+// localDriver stores volumes as directories of a graphdriver, which is the
+// way volumes have always been stored by the daemon.
+type localDriver struct {
+	driver graphdriver.Driver
+}
+
+func (d *localDriver) Create(name string) error {
+	if d.driver.Exists(name) {
+		return nil
+	}
+	return d.driver.Create(name, "")
+}
+
+func (d *localDriver) Remove(name string) error {
+	return d.driver.Remove(name)
+}
+
+func (d *localDriver) Path(name string) (string, error) {
+	path, err := d.driver.Get(name, "")
+	if err != nil {
+		return "", fmt.Errorf("Driver %s failed to get volume rootfs %s: %v", d.driver, name, err)
+	}
+	return path, nil
+}
+
+func (d *localDriver) Mount(name string) (string, error) {
+	return d.Path(name)
+}
+
+func (d *localDriver) Unmount(name string) error {
+	return nil
+}
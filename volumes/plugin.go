@@ -0,0 +1,67 @@
+package volumes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/pkg/plugins"
+)
+
+// volumeDriverRequest is the body of every call made to a volume plugin.
+type volumeDriverRequest struct {
+	Name string
+}
+
+// volumeDriverResponse is the reply of a volume plugin. Err is set when the
+// plugin failed to carry out the request.
+type volumeDriverResponse struct {
+	Mountpoint string `json:",omitempty"`
+	Err        string `json:",omitempty"`
+}
+
+// pluginDriver is a VolumeDriver backed by an out-of-process plugin speaking
+// the JSON-over-HTTP plugin protocol.
+type pluginDriver struct {
+	name   string
+	client *plugins.Client
+}
+
+// NewPluginDriver returns a VolumeDriver which forwards every call to the
+// plugin reachable through client.
+func NewPluginDriver(name string, client *plugins.Client) VolumeDriver {
+	return &pluginDriver{name: name, client: client}
+}
+
+func (d *pluginDriver) call(method, name string) (string, error) {
+	var ret volumeDriverResponse
+	if err := d.client.Call("VolumeDriver."+method, &volumeDriverRequest{Name: name}, &ret); err != nil {
+		return "", fmt.Errorf("Volume plugin %s failed to %s volume %s: %v", d.name, strings.ToLower(method), name, err)
+	}
+	if ret.Err != "" {
+		return "", fmt.Errorf("Volume plugin %s failed to %s volume %s: %s", d.name, strings.ToLower(method), name, ret.Err)
+	}
+	return ret.Mountpoint, nil
+}
+
+func (d *pluginDriver) Create(name string) error {
+	_, err := d.call("Create", name)
+	return err
+}
+
+func (d *pluginDriver) Remove(name string) error {
+	_, err := d.call("Remove", name)
+	return err
+}
+
+func (d *pluginDriver) Path(name string) (string, error) {
+	return d.call("Path", name)
+}
+
+func (d *pluginDriver) Mount(name string) (string, error) {
+	return d.call("Mount", name)
+}
+
+func (d *pluginDriver) Unmount(name string) error {
+	_, err := d.call("Unmount", name)
+	return err
+}
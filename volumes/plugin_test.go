@@ -0,0 +1,150 @@
+package volumes
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/pkg/plugins"
+)
+
+// fakePlugin is a stand-in for an out-of-process volume plugin which keeps
+// its volumes as directories under root.
+type fakePlugin struct {
+	sync.Mutex
+	root    string
+	volumes map[string]int // volume name -> mount count
+	server  *httptest.Server
+}
+
+func newFakePlugin(t *testing.T, root string) *fakePlugin {
+	p := &fakePlugin{root: root, volumes: make(map[string]int)}
+	mux := http.NewServeMux()
+
+	handle := func(method string, fn func(name string) (string, string)) {
+		mux.HandleFunc("/VolumeDriver."+method, func(w http.ResponseWriter, r *http.Request) {
+			var req volumeDriverRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Fatal(err)
+			}
+			p.Lock()
+			mountpoint, errStr := fn(req.Name)
+			p.Unlock()
+			w.Header().Set("Content-Type", "application/vnd.docker.plugins.v1+json")
+			json.NewEncoder(w).Encode(&volumeDriverResponse{Mountpoint: mountpoint, Err: errStr})
+		})
+	}
+
+	handle("Create", func(name string) (string, string) {
+		if err := os.MkdirAll(filepath.Join(p.root, name), 0755); err != nil {
+			return "", err.Error()
+		}
+		p.volumes[name] = 0
+		return "", ""
+	})
+	handle("Remove", func(name string) (string, string) {
+		if p.volumes[name] > 0 {
+			return "", "volume is mounted"
+		}
+		delete(p.volumes, name)
+		return "", ""
+	})
+	handle("Path", func(name string) (string, string) {
+		return filepath.Join(p.root, name), ""
+	})
+	handle("Mount", func(name string) (string, string) {
+		if _, exists := p.volumes[name]; !exists {
+			return "", "no such volume"
+		}
+		p.volumes[name]++
+		return filepath.Join(p.root, name), ""
+	})
+	handle("Unmount", func(name string) (string, string) {
+		p.volumes[name]--
+		return "", ""
+	})
+
+	p.server = httptest.NewServer(mux)
+	return p
+}
+
+func TestRepositoryPluginVolumes(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	plugin := newFakePlugin(t, filepath.Join(root, "plugin"))
+	defer plugin.server.Close()
+
+	if !RegisterDriver("fake", NewPluginDriver("fake", plugins.NewClient(plugin.server.URL))) {
+		t.Fatal("expected driver to be registered")
+	}
+	defer UnregisterDriver("fake")
+
+	repo, err := newRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := repo.Create("nfsdata", "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.DriverName() != "fake" || v.IsLocal() {
+		t.Fatalf("expected volume to use the fake driver, got %s", v.DriverName())
+	}
+	if expected := filepath.Join(root, "plugin", "nfsdata"); v.Path != expected {
+		t.Fatalf("expected volume path %s, got %s", expected, v.Path)
+	}
+	if _, exists := plugin.volumes["nfsdata"]; !exists {
+		t.Fatal("expected the plugin to have created the volume")
+	}
+
+	if _, err := repo.FindOrCreateNamedVolume("nfsdata", "local"); err == nil {
+		t.Fatal("expected a conflict when reusing the name with another driver")
+	}
+
+	mountpoint, err := v.Mount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mountpoint != v.Path {
+		t.Fatalf("expected mountpoint %s, got %s", v.Path, mountpoint)
+	}
+	if err := repo.Delete(v.Path); err == nil {
+		t.Fatal("expected delete to fail while the volume is mounted")
+	}
+	if err := v.Unmount(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Delete(v.Path); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := plugin.volumes["nfsdata"]; exists {
+		t.Fatal("expected the plugin to have removed the volume")
+	}
+}
+
+func TestRepositoryUnknownDriver(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	repo, err := newRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Create("data", "doesnotexist"); err == nil {
+		t.Fatal("expected create to fail with an unknown driver")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"sync"
 
 	"github.com/Sirupsen/logrus"
@@ -12,11 +14,26 @@ import (
 	"github.com/docker/docker/pkg/stringid"
 )
 
+// validName matches the names users may give to named volumes. Names are
+// restricted so they can never be confused with a host path in a bind spec.
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// IsValidName returns true if name can be used as the name of a volume.
+func IsValidName(name string) bool {
+	return validName.MatchString(name)
+}
+
 type Repository struct {
 	configPath string
 	driver     graphdriver.Driver
+	local      VolumeDriver
 	volumes    map[string]*Volume
-	lock       sync.Mutex
+	names      map[string]*Volume
+	// removing holds the volumes being removed by their driver, by path.
+	// They are neither listed nor found, but their path and name cannot be
+	// used by a new volume until the removal is over.
+	removing map[string]*Volume
+	lock     sync.Mutex
 }
 
 func NewRepository(configPath string, driver graphdriver.Driver) (*Repository, error) {
@@ -32,28 +49,57 @@ func NewRepository(configPath string, driver graphdriver.Driver) (*Repository, e
 
 	repo := &Repository{
 		driver:     driver,
+		local:      &localDriver{driver},
 		configPath: abspath,
 		volumes:    make(map[string]*Volume),
+		names:      make(map[string]*Volume),
+		removing:   make(map[string]*Volume),
 	}
 
 	return repo, repo.restore()
 }
 
-func (r *Repository) newVolume(path string, writable bool) (*Volume, error) {
+// newVolume creates a volume with its driver and writes its configuration.
+// It is called without the lock of the repository, as a volume plugin may
+// be slow to create the volume: the caller then adds it with register.
+func (r *Repository) newVolume(path, name, driverName string, writable bool) (*Volume, error) {
 	var (
 		isBindMount bool
-		err         error
 		id          = stringid.GenerateRandomID()
 	)
 	if path != "" {
 		isBindMount = true
 	}
+	if driverName == DefaultDriverName {
+		driverName = ""
+	}
+
+	v := &Volume{
+		ID:          id,
+		Name:        name,
+		Driver:      driverName,
+		repository:  r,
+		Writable:    writable,
+		containers:  make(map[string]struct{}),
+		configPath:  r.configPath + "/" + id,
+		IsBindMount: isBindMount,
+	}
 
 	if path == "" {
-		path, err = r.createNewVolumePath(id)
+		d, err := r.volumeDriver(driverName)
 		if err != nil {
 			return nil, err
 		}
+		if err := d.Create(v.driverKey()); err != nil {
+			return nil, err
+		}
+		path, err = d.Path(v.driverKey())
+		if err != nil {
+			return nil, err
+		}
+		if path == "" {
+			return nil, fmt.Errorf("Volume driver %s returned no path for volume %s", driverName, v.driverKey())
+		}
 	}
 	path = filepath.Clean(path)
 
@@ -62,25 +108,78 @@ func (r *Repository) newVolume(path string, writable bool) (*Volume, error) {
 	if cleanPath, err := filepath.EvalSymlinks(path); err == nil {
 		path = cleanPath
 	}
-
-	v := &Volume{
-		ID:          id,
-		Path:        path,
-		repository:  r,
-		Writable:    writable,
-		containers:  make(map[string]struct{}),
-		configPath:  r.configPath + "/" + id,
-		IsBindMount: isBindMount,
-	}
+	v.Path = path
 
 	if err := v.initialize(); err != nil {
 		return nil, err
 	}
-
-	r.add(v)
 	return v, nil
 }
 
+// register adds the volume v made by newVolume to the repository, unless a
+// volume with the same path or name was added while v was being created.
+// That volume is returned instead, and v is discarded. It is an error if
+// such a volume is being removed. It must be called with the lock of the
+// repository held.
+func (r *Repository) register(v *Volume) (*Volume, error) {
+	existing := r.get(v.Path)
+	if existing == nil && v.Name != "" {
+		existing = r.names[v.Name]
+	}
+	if existing == nil {
+		if removed := r.getRemoving(v.Path, v.Name); removed != nil {
+			r.discard(v)
+			return nil, fmt.Errorf("Volume %s is being removed", removed.Path)
+		}
+		r.add(v)
+		return v, nil
+	}
+	r.discard(v)
+	return existing, nil
+}
+
+// discard removes the data and the configuration of the volume v made by
+// newVolume and not added to the repository.
+func (r *Repository) discard(v *Volume) {
+
+	// A plugin knows the volume by its name, which the other volume still
+	// uses: only the data of a local volume is its own
+	if v.IsLocal() && !v.IsBindMount {
+		if err := r.local.Remove(v.driverKey()); err != nil && !os.IsNotExist(err) {
+			logrus.Debugf("Error removing volume %s: %v", v.ID, err)
+		}
+	}
+	if err := os.RemoveAll(v.configPath); err != nil {
+		logrus.Debugf("Error removing the configuration of volume %s: %v", v.ID, err)
+	}
+}
+
+// getRemoving returns the volume being removed with the given path, or with
+// the given name if it is not empty.
+func (r *Repository) getRemoving(path, name string) *Volume {
+	if v := r.removing[path]; v != nil {
+		return v
+	}
+	if name == "" {
+		return nil
+	}
+	for _, v := range r.removing {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// volumeDriver returns the driver responsible for volumes created with the
+// given driver name. The empty name refers to the built-in local driver.
+func (r *Repository) volumeDriver(name string) (VolumeDriver, error) {
+	if name == "" || name == DefaultDriverName {
+		return r.local, nil
+	}
+	return lookupDriver(name)
+}
+
 func (r *Repository) restore() error {
 	dir, err := ioutil.ReadDir(r.configPath)
 	if err != nil {
@@ -93,6 +192,7 @@ func (r *Repository) restore() error {
 			ID:         id,
 			configPath: r.configPath + "/" + id,
 			containers: make(map[string]struct{}),
+			repository: r,
 		}
 		if err := vol.FromDisk(); err != nil {
 			if !os.IsNotExist(err) {
@@ -124,70 +224,181 @@ func (r *Repository) get(path string) *Volume {
 	return r.volumes[filepath.Clean(path)]
 }
 
+// GetByName returns the volume created with the given name, or nil if there
+// is no such volume.
+func (r *Repository) GetByName(name string) *Volume {
+	r.lock.Lock()
+	vol := r.names[name]
+	r.lock.Unlock()
+	return vol
+}
+
+// List returns every volume known to the repository, sorted by path.
+func (r *Repository) List() []*Volume {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	paths := make([]string, 0, len(r.volumes))
+	for path := range r.volumes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	vols := make([]*Volume, 0, len(paths))
+	for _, path := range paths {
+		vols = append(vols, r.volumes[path])
+	}
+	return vols
+}
+
 func (r *Repository) add(volume *Volume) {
 	if vol := r.get(volume.Path); vol != nil {
 		return
 	}
 	r.volumes[volume.Path] = volume
+	if volume.Name != "" {
+		r.names[volume.Name] = volume
+	}
 }
 
+// Delete removes the volume at path, unless a container uses it. The volume
+// is taken out of the repository before its driver removes it, without the
+// lock of the repository, and is put back if the removal fails.
 func (r *Repository) Delete(path string) error {
+	volume, err := r.startRemoval(path)
+	if err != nil {
+		return err
+	}
+
+	err = r.remove(volume)
+
+	r.lock.Lock()
+	delete(r.removing, volume.Path)
+	if err != nil {
+		r.add(volume)
+	}
+	r.lock.Unlock()
+	return err
+}
+
+// startRemoval takes the unused volume at path out of the repository and
+// marks it as being removed.
+func (r *Repository) startRemoval(path string) (*Volume, error) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 	path, err := filepath.EvalSymlinks(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	volume := r.get(filepath.Clean(path))
+	path = filepath.Clean(path)
+	volume := r.volumes[path]
 	if volume == nil {
-		return fmt.Errorf("Volume %s does not exist", path)
+		if r.removing[path] != nil {
+			return nil, fmt.Errorf("Volume %s is already being removed", path)
+		}
+		return nil, fmt.Errorf("Volume %s does not exist", path)
 	}
 
 	containers := volume.Containers()
 	if len(containers) > 0 {
-		return fmt.Errorf("Volume %s is being used and cannot be removed: used by containers %s", volume.Path, containers)
+		return nil, fmt.Errorf("Volume %s is being used and cannot be removed: used by containers %s", volume.Path, containers)
 	}
 
-	if err := os.RemoveAll(volume.configPath); err != nil {
-		return err
+	delete(r.volumes, volume.Path)
+	if volume.Name != "" {
+		delete(r.names, volume.Name)
 	}
+	r.removing[volume.Path] = volume
+	return volume, nil
+}
 
+// remove removes the data of the volume with its driver, and then its
+// configuration.
+func (r *Repository) remove(volume *Volume) error {
 	if !volume.IsBindMount {
-		if err := r.driver.Remove(volume.ID); err != nil {
+		d, err := r.volumeDriver(volume.Driver)
+		if err != nil {
+			return err
+		}
+		if err := d.Remove(volume.driverKey()); err != nil {
 			if !os.IsNotExist(err) {
 				return err
 			}
 		}
 	}
-
-	delete(r.volumes, volume.Path)
-	return nil
+	return os.RemoveAll(volume.configPath)
 }
 
-func (r *Repository) createNewVolumePath(id string) (string, error) {
-	if err := r.driver.Create(id, ""); err != nil {
-		return "", err
+func (r *Repository) FindOrCreateVolume(path string, writable bool) (*Volume, error) {
+	if path != "" {
+		if v := r.Get(path); v != nil {
+			return v, nil
+		}
 	}
 
-	path, err := r.driver.Get(id, "")
+	v, err := r.newVolume(path, "", "", writable)
 	if err != nil {
-		return "", fmt.Errorf("Driver %s failed to get volume rootfs %s: %v", r.driver, id, err)
+		return nil, err
 	}
-
-	return path, nil
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.register(v)
 }
 
-func (r *Repository) FindOrCreateVolume(path string, writable bool) (*Volume, error) {
+// Create makes a new named volume using the given volume driver. It is an
+// error to create a volume with a name that is already in use.
+func (r *Repository) Create(name, driverName string) (*Volume, error) {
+	if !IsValidName(name) {
+		return nil, fmt.Errorf("Invalid volume name %q: only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+	}
+	if r.GetByName(name) != nil {
+		return nil, fmt.Errorf("Conflict: volume %s already exists", name)
+	}
+
+	v, err := r.newVolume("", name, driverName, true)
+	if err != nil {
+		return nil, err
+	}
 	r.lock.Lock()
 	defer r.lock.Unlock()
+	registered, err := r.register(v)
+	if err != nil {
+		return nil, err
+	}
+	if registered != v {
+		return nil, fmt.Errorf("Conflict: volume %s already exists", name)
+	}
+	return v, nil
+}
 
-	if path == "" {
-		return r.newVolume(path, writable)
+// FindOrCreateNamedVolume returns the volume with the given name, creating it
+// with the given volume driver if it does not exist yet.
+func (r *Repository) FindOrCreateNamedVolume(name, driverName string) (*Volume, error) {
+	if v := r.GetByName(name); v != nil {
+		return checkDriver(v, driverName)
+	}
+	if !IsValidName(name) {
+		return nil, fmt.Errorf("Invalid volume name %q: only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
 	}
 
-	if v := r.get(path); v != nil {
-		return v, nil
+	v, err := r.newVolume("", name, driverName, true)
+	if err != nil {
+		return nil, err
 	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	registered, err := r.register(v)
+	if err != nil {
+		return nil, err
+	}
+	return checkDriver(registered, driverName)
+}
 
-	return r.newVolume(path, writable)
+// checkDriver returns the existing volume v, unless it was not created with
+// the volume driver driverName.
+func checkDriver(v *Volume, driverName string) (*Volume, error) {
+	if driverName != "" && driverName != v.DriverName() {
+		return nil, fmt.Errorf("Conflict: volume %s already exists with driver %s", v.Name, v.DriverName())
+	}
+	return v, nil
 }
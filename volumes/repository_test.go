@@ -1,6 +1,7 @@
 package volumes
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -152,6 +153,194 @@ func TestRepositoryDelete(t *testing.T) {
 
 }
 
+func TestRepositoryNamedVolumes(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	repo, err := newRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Create("/not/a/name", ""); err == nil {
+		t.Fatalf("expected create to fail with an invalid name")
+	}
+
+	v, err := repo.Create("data", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "data" || !v.IsNamed() {
+		t.Fatalf("expected volume to be named data, got %q", v.Name)
+	}
+	if _, err := repo.Create("data", ""); err == nil {
+		t.Fatalf("expected create to fail for a duplicate name")
+	}
+
+	v2, err := repo.FindOrCreateNamedVolume("data", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2 != v {
+		t.Fatalf("expected FindOrCreateNamedVolume to return the existing volume")
+	}
+	if v := repo.GetByName("data"); v != v2 {
+		t.Fatalf("expected GetByName to return the named volume")
+	}
+	if l := len(repo.List()); l != 1 {
+		t.Fatalf("expected 1 volume, got %d", l)
+	}
+
+	// named volumes survive a reload of the repository
+	repo, err = newRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v = repo.GetByName("data")
+	if v == nil {
+		t.Fatalf("expected named volume to be restored")
+	}
+
+	if err := repo.Delete(v.Path); err != nil {
+		t.Fatal(err)
+	}
+	if v := repo.GetByName("data"); v != nil {
+		t.Fatalf("expected named volume to be removed")
+	}
+}
+
+func TestRepositoryRegisterDuplicate(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	repo, err := newRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two creations of the same name racing outside of the lock
+	v1, err := repo.newVolume("", "data", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := repo.newVolume("", "data", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := repo.register(v1); err != nil || v != v1 {
+		t.Fatalf("expected the first volume to be registered")
+	}
+	if v, err := repo.register(v2); err != nil || v != v1 {
+		t.Fatalf("expected the volume registered first to be returned")
+	}
+	for _, path := range []string{v2.Path, v2.configPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected %s of the discarded volume to be removed, got %v", path, err)
+		}
+	}
+	if l := len(repo.List()); l != 1 {
+		t.Fatalf("expected 1 volume, got %d", l)
+	}
+}
+
+// blockingDriver is a volume driver whose Remove waits for the test to
+// release it, with the error Remove returns.
+type blockingDriver struct {
+	root     string
+	removing chan struct{}
+	release  chan error
+}
+
+func (d *blockingDriver) Create(name string) error {
+	return os.MkdirAll(filepath.Join(d.root, name), 0755)
+}
+
+func (d *blockingDriver) Remove(name string) error {
+	d.removing <- struct{}{}
+	return <-d.release
+}
+
+func (d *blockingDriver) Path(name string) (string, error) {
+	return filepath.Join(d.root, name), nil
+}
+
+func (d *blockingDriver) Mount(name string) (string, error) {
+	return d.Path(name)
+}
+
+func (d *blockingDriver) Unmount(name string) error {
+	return nil
+}
+
+func TestRepositoryDeleteUnlocked(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	repo, err := newRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &blockingDriver{
+		root:     filepath.Join(root, "blocking"),
+		removing: make(chan struct{}),
+		release:  make(chan error),
+	}
+	RegisterDriver("blocking", d)
+	defer UnregisterDriver("blocking")
+
+	v, err := repo.Create("data", "blocking")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remove := func() chan error {
+		errc := make(chan error)
+		go func() { errc <- repo.Delete(v.Path) }()
+		<-d.removing
+		return errc
+	}
+
+	// The repository stays usable while the driver removes the volume, but
+	// the volume can neither be found nor created again
+	errc := remove()
+	if l := len(repo.List()); l != 0 {
+		t.Fatalf("expected no volume while it is removed, got %d", l)
+	}
+	if _, err := repo.Create("data", "blocking"); err == nil {
+		t.Fatalf("expected create to fail while the volume is removed")
+	}
+	if err := repo.Delete(v.Path); err == nil {
+		t.Fatalf("expected delete to fail while the volume is removed")
+	}
+
+	// A failed removal puts the volume back
+	d.release <- errors.New("volume is busy")
+	if err := <-errc; err == nil {
+		t.Fatalf("expected delete to fail with the error of the driver")
+	}
+	if repo.GetByName("data") != v {
+		t.Fatalf("expected the volume to be restored")
+	}
+
+	errc = remove()
+	d.release <- nil
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if repo.GetByName("data") != nil {
+		t.Fatalf("expected the volume to be removed")
+	}
+	if _, err := repo.Create("data", "blocking"); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func newRepo(root string) (*Repository, error) {
 	configPath := filepath.Join(root, "repo-config")
 	graphDir := filepath.Join(root, "repo-graph")
@@ -11,6 +11,8 @@ import (
 
 type Volume struct {
 	ID          string
+	Name        string `json:",omitempty"`
+	Driver      string `json:",omitempty"`
 	Path        string
 	IsBindMount bool
 	Writable    bool
@@ -20,6 +22,61 @@ type Volume struct {
 	lock        sync.Mutex
 }
 
+// IsNamed returns true if the volume was created with a user-supplied name
+// and should outlive the containers which use it.
+func (v *Volume) IsNamed() bool {
+	return v.Name != ""
+}
+
+// DriverName returns the name of the volume driver backing the volume.
+func (v *Volume) DriverName() string {
+	if v.Driver == "" {
+		return DefaultDriverName
+	}
+	return v.Driver
+}
+
+// IsLocal returns true if the volume's data is stored by the daemon itself
+// rather than by a volume plugin.
+func (v *Volume) IsLocal() bool {
+	return v.Driver == ""
+}
+
+// driverKey is the name under which the volume is known to its driver.
+// Local volumes are stored by ID, plugins only ever see the volume's name.
+func (v *Volume) driverKey() string {
+	if v.IsLocal() {
+		return v.ID
+	}
+	return v.Name
+}
+
+// Mount asks the volume's driver to make the volume available and returns
+// the host path to bind into containers.
+func (v *Volume) Mount() (string, error) {
+	if v.IsBindMount {
+		return v.Path, nil
+	}
+	d, err := v.repository.volumeDriver(v.Driver)
+	if err != nil {
+		return "", err
+	}
+	return d.Mount(v.driverKey())
+}
+
+// Unmount tells the volume's driver that a container stopped using the
+// volume.
+func (v *Volume) Unmount() error {
+	if v.IsBindMount {
+		return nil
+	}
+	d, err := v.repository.volumeDriver(v.Driver)
+	if err != nil {
+		return err
+	}
+	return d.Unmount(v.driverKey())
+}
+
 func (v *Volume) IsDir() (bool, error) {
 	stat, err := os.Stat(v.Path)
 	if err != nil {